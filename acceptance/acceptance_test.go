@@ -1,13 +1,17 @@
 package acceptance_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
@@ -15,28 +19,68 @@ import (
 	"github.com/onsi/gomega/gexec"
 )
 
+// mockDaemon serves the ducati daemon's container API and remembers the
+// requests it received.  Like the daemon, it lists every container
+// whatever the query parameters.
+type mockDaemon struct {
+	containers []models.Container
+
+	mutex    sync.Mutex
+	requests []string
+}
+
+func (d *mockDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mutex.Lock()
+	d.requests = append(d.requests, r.URL.RequestURI())
+	d.mutex.Unlock()
+
+	w.Header().Set("content-type", "application/json")
+
+	if id := strings.TrimPrefix(r.URL.Path, "/containers/"); id != r.URL.Path {
+		for _, c := range d.containers {
+			if c.ID == id {
+				json.NewEncoder(w).Encode(c)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if r.URL.Path != "/containers" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(d.containers)
+}
+
+func (d *mockDaemon) Requests() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]string{}, d.requests...)
+}
+
 var _ = Describe("AcceptanceTests", func() {
 	var serverSession *gexec.Session
 	var listenPort string
+	var daemon *mockDaemon
 	var mockDucatiAPIServer *httptest.Server
 	var happyPathArgs []string
 
 	BeforeEach(func() {
 		listenPort = strconv.Itoa(11999 + GinkgoParallelNode())
-		mockDucatiAPIServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path == "/containers" {
-				w.Header().Set("content-type", "application/json")
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte(`[{"app": "my-app-guid", "IP": "10.11.12.13"}]`))
-				return
-			}
-			w.WriteHeader(http.StatusNotFound)
-		}))
+		daemon = &mockDaemon{containers: []models.Container{
+			{ID: "my-container", App: "my-app-guid", IP: "10.11.12.13"},
+			{ID: "other-container", App: "other-app-guid", IP: "10.11.12.14"},
+		}}
+		mockDucatiAPIServer = httptest.NewServer(daemon)
 		happyPathArgs = []string{
 			"--listenAddress=127.0.0.1:" + listenPort,
 			"--server=8.8.8.8:53",
 			"--ducatiSuffix=potato",
 			"--ducatiAPI=" + mockDucatiAPIServer.URL,
+			"--adminListenAddress=127.0.0.1:" + strconv.Itoa(12999+GinkgoParallelNode()),
 		}
 	})
 
@@ -100,6 +144,26 @@ var _ = Describe("AcceptanceTests", func() {
 					// verify client works
 					Expect(clientSession.Out).To(gbytes.Say("ANSWER SECTION:\nmy-app-guid.potato"))
 					Expect(clientSession.Out).To(gbytes.Say("10.11.12.13"))
+					Expect(clientSession.Out).NotTo(gbytes.Say("10.11.12.14"))
+				})
+
+				It("answers with only that app's containers from one shared listing", func() {
+					Consistently(serverSession).ShouldNot(gexec.Exit())
+					before := len(daemon.Requests())
+
+					clientCmd := exec.Command("dig", "@127.0.0.1", "-p", listenPort, "my-app-guid.potato")
+					clientSession, err := gexec.Start(clientCmd, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(clientSession).Should(gexec.Exit(0))
+
+					Expect(clientSession.Out).To(gbytes.Say("10.11.12.13"))
+					Expect(clientSession.Out).NotTo(gbytes.Say("10.11.12.14"))
+
+					requests := daemon.Requests()
+					for _, request := range requests {
+						Expect(request).To(Equal("/containers"))
+					}
+					Expect(len(requests) - before).To(BeNumerically("<=", 1))
 				})
 			})
 		})
@@ -141,6 +205,49 @@ var _ = Describe("AcceptanceTests", func() {
 
 	})
 
+	Context("when the ducati api requires a UAA token", func() {
+		var uaaServer *fakes.UAAServer
+
+		BeforeEach(func() {
+			uaaServer = fakes.NewUAAServer("ducati_dns", "some-secret")
+			mockDucatiAPIServer.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !uaaServer.Authorized(r) {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				daemon.ServeHTTP(w, r)
+			})
+
+			var err error
+			serverCmd := exec.Command(pathToBinary, append(happyPathArgs,
+				"--uaaURL="+uaaServer.URL,
+				"--uaaClientSecret=some-secret",
+			)...)
+			serverSession, err = gexec.Start(serverCmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			if serverSession != nil {
+				serverSession.Interrupt()
+				Eventually(serverSession).Should(gexec.Exit())
+			}
+			uaaServer.Close()
+		})
+
+		It("authenticates to the api server with a token from UAA", func() {
+			Consistently(serverSession).ShouldNot(gexec.Exit())
+
+			clientCmd := exec.Command("dig", "@127.0.0.1", "-p", listenPort, "my-app-guid.potato")
+			clientSession, err := gexec.Start(clientCmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(clientSession).Should(gexec.Exit(0))
+			Expect(clientSession.Out).To(gbytes.Say("ANSWER SECTION:\nmy-app-guid.potato"))
+			Expect(uaaServer.TokenRequests()).To(Equal(1))
+		})
+	})
+
 	Context("when the server for forwarding is unavailable", func() {
 		BeforeEach(func() {
 			var err error
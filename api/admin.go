@@ -0,0 +1,208 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager"
+)
+
+//go:generate counterfeiter -o ../fakes/record_lister.go --fake-name RecordLister . recordLister
+type recordLister interface {
+	Records() ([]dns.RR, error)
+}
+
+//go:generate counterfeiter -o ../fakes/cache.go --fake-name Cache . Cache
+type Cache interface {
+	Entries() []resolver.CacheEntry
+	Flush(name string) int
+}
+
+//go:generate counterfeiter -o ../fakes/upstream_status.go --fake-name UpstreamStatus . upstreamStatus
+type upstreamStatus interface {
+	Status() []resolver.UpstreamStatus
+}
+
+//go:generate counterfeiter -o ../fakes/change_history.go --fake-name ChangeHistory . changeHistory
+type changeHistory interface {
+	Changes() []resolver.RecordChange
+}
+
+//go:generate counterfeiter -o ../fakes/log_level.go --fake-name LogLevel . logLevel
+type logLevel interface {
+	GetMinLevel() lager.LogLevel
+	SetMinLevel(lager.LogLevel)
+}
+
+type Record struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	TTL  uint32 `json:"ttl"`
+	Data string `json:"data"`
+}
+
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+var logLevels = map[string]lager.LogLevel{
+	"debug": lager.DEBUG,
+	"info":  lager.INFO,
+	"error": lager.ERROR,
+	"fatal": lager.FATAL,
+}
+
+// AdminHandler serves operator endpoints for inspecting the overlay
+// records and their recent changes, caches and upstreams, and for changing
+// the log level.
+type AdminHandler struct {
+	Logger    lager.Logger
+	Records   recordLister
+	Changes   changeHistory
+	Caches    map[string]Cache
+	Upstreams upstreamStatus
+	LogLevel  logLevel
+	Token     string
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.Logger.Session("admin-api", lager.Data{"method": r.Method, "path": r.URL.Path})
+
+	if !authorized(r, h.Token) {
+		logger.Info("unauthorized")
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case path == "/records" && r.Method == "GET":
+		h.records(logger, w)
+	case path == "/changes" && r.Method == "GET":
+		h.changes(w, r)
+	case path == "/upstreams" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, h.Upstreams.Status())
+	case path == "/log-level" && r.Method == "GET":
+		h.getLogLevel(w)
+	case path == "/log-level" && r.Method == "PUT":
+		h.setLogLevel(logger, w, r)
+	case path == "/caches" || strings.HasPrefix(path, "/caches/"):
+		h.caches(logger, w, r, strings.TrimPrefix(strings.TrimPrefix(path, "/caches"), "/"))
+	case path == "/records" || path == "/changes" || path == "/upstreams" || path == "/log-level":
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *AdminHandler) records(logger lager.Logger, w http.ResponseWriter) {
+	rrs, err := h.Records.Records()
+	if err != nil {
+		logger.Error("list-records-failed", err)
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	records := []Record{}
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		records = append(records, Record{
+			Name: hdr.Name,
+			Type: dns.TypeToString[hdr.Rrtype],
+			TTL:  hdr.Ttl,
+			Data: strings.TrimPrefix(rr.String(), hdr.String()),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, records)
+}
+
+// changes lists the recent record changes, oldest first, optionally only
+// those of the IP given with the ip query parameter.
+func (h *AdminHandler) changes(w http.ResponseWriter, r *http.Request) {
+	ip := r.URL.Query().Get("ip")
+
+	changes := []resolver.RecordChange{}
+	for _, change := range h.Changes.Changes() {
+		if ip == "" || change.Instance.IP == ip {
+			changes = append(changes, change)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, changes)
+}
+
+// caches lists or flushes every cache, or only the named one.  Flushes are
+// limited to a single name with the name query parameter.
+func (h *AdminHandler) caches(logger lager.Logger, w http.ResponseWriter, r *http.Request, cacheName string) {
+	selected := h.Caches
+	if cacheName != "" {
+		cache, ok := h.Caches[cacheName]
+		if !ok {
+			writeError(w, http.StatusNotFound, "cache not found")
+			return
+		}
+		selected = map[string]Cache{cacheName: cache}
+	}
+
+	switch r.Method {
+	case "GET":
+		entries := map[string][]resolver.CacheEntry{}
+		for name, cache := range selected {
+			entries[name] = cache.Entries()
+		}
+		writeJSON(w, http.StatusOK, entries)
+
+	case "DELETE":
+		name := r.URL.Query().Get("name")
+		flushed := map[string]int{}
+		for cacheName, cache := range selected {
+			flushed[cacheName] = cache.Flush(name)
+		}
+		logger.Info("caches-flushed", lager.Data{"name": name, "flushed": flushed})
+		writeJSON(w, http.StatusOK, map[string]map[string]int{"flushed": flushed})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *AdminHandler) getLogLevel(w http.ResponseWriter) {
+	current := h.LogLevel.GetMinLevel()
+	for name, level := range logLevels {
+		if level == current {
+			writeJSON(w, http.StatusOK, LogLevel{Level: name})
+			return
+		}
+	}
+	writeError(w, http.StatusInternalServerError, "unknown log level")
+}
+
+func (h *AdminHandler) setLogLevel(logger lager.Logger, w http.ResponseWriter, r *http.Request) {
+	var requested LogLevel
+	if err := json.NewDecoder(r.Body).Decode(&requested); err != nil {
+		logger.Error("decode-failed", err)
+		writeError(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	level, ok := logLevels[strings.ToLower(requested.Level)]
+	if !ok {
+		names := []string{}
+		for name := range logLevels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		writeError(w, http.StatusBadRequest, "level must be one of "+strings.Join(names, ", "))
+		return
+	}
+
+	h.LogLevel.SetMinLevel(level)
+	logger.Info("log-level-changed", lager.Data{"level": strings.ToLower(requested.Level)})
+
+	writeJSON(w, http.StatusOK, LogLevel{Level: strings.ToLower(requested.Level)})
+}
@@ -0,0 +1,263 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/api"
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("AdminHandler", func() {
+	var (
+		handler       *api.AdminHandler
+		fakeRecords   *fakes.RecordLister
+		healthCache   *fakes.Cache
+		rrsigCache    *fakes.Cache
+		fakeChanges   *fakes.ChangeHistory
+		fakeUpstreams *fakes.UpstreamStatus
+		fakeLogLevel  *fakes.LogLevel
+		fakeLogger    *lagertest.TestLogger
+		recorder      *httptest.ResponseRecorder
+		expires       time.Time
+	)
+
+	serve := func(method, path, body string) {
+		request, err := http.NewRequest(method, path, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		request.Header.Set("Authorization", "Bearer some-token")
+		handler.ServeHTTP(recorder, request)
+	}
+
+	BeforeEach(func() {
+		expires = time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC)
+		fakeLogger = lagertest.NewTestLogger("test")
+		fakeRecords = &fakes.RecordLister{}
+		healthCache = &fakes.Cache{}
+		rrsigCache = &fakes.Cache{}
+		fakeChanges = &fakes.ChangeHistory{}
+		fakeUpstreams = &fakes.UpstreamStatus{}
+		fakeLogLevel = &fakes.LogLevel{}
+
+		handler = &api.AdminHandler{
+			Logger:  fakeLogger,
+			Records: fakeRecords,
+			Changes: fakeChanges,
+			Caches: map[string]api.Cache{
+				"health_check": healthCache,
+				"rrsig":        rrsigCache,
+			},
+			Upstreams: fakeUpstreams,
+			LogLevel:  fakeLogLevel,
+			Token:     "some-token",
+		}
+		recorder = httptest.NewRecorder()
+	})
+
+	It("requires the bearer token", func() {
+		request, err := http.NewRequest("GET", "/records", nil)
+		Expect(err).NotTo(HaveOccurred())
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+		Expect(fakeRecords.RecordsCallCount()).To(Equal(0))
+	})
+
+	Describe("GET /records", func() {
+		BeforeEach(func() {
+			rr, err := dns.NewRR("app-a.potato. 42 IN A 10.0.0.1")
+			Expect(err).NotTo(HaveOccurred())
+			fakeRecords.RecordsReturns([]dns.RR{rr}, nil)
+		})
+
+		It("lists the overlay records", func() {
+			serve("GET", "/records", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`[
+				{"name": "app-a.potato.", "type": "A", "ttl": 42, "data": "10.0.0.1"}
+			]`))
+		})
+
+		Context("when the records cannot be listed", func() {
+			BeforeEach(func() {
+				fakeRecords.RecordsReturns(nil, errors.New("connection refused"))
+			})
+
+			It("responds with a bad gateway error", func() {
+				serve("GET", "/records", "")
+
+				Expect(recorder.Code).To(Equal(http.StatusBadGateway))
+				Expect(recorder.Body.String()).To(MatchJSON(`{"error": "connection refused"}`))
+			})
+		})
+	})
+
+	Describe("caches", func() {
+		BeforeEach(func() {
+			healthCache.EntriesReturns([]resolver.CacheEntry{{Key: "10.0.0.1:8080", Value: "healthy", Expires: expires}})
+			rrsigCache.EntriesReturns([]resolver.CacheEntry{})
+			healthCache.FlushReturns(1)
+			rrsigCache.FlushReturns(3)
+		})
+
+		It("shows every cache", func() {
+			serve("GET", "/caches", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{
+				"health_check": [{"key": "10.0.0.1:8080", "value": "healthy", "expires": "2016-05-01T12:00:00Z"}],
+				"rrsig": []
+			}`))
+		})
+
+		It("shows a single cache", func() {
+			serve("GET", "/caches/rrsig", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"rrsig": []}`))
+			Expect(healthCache.EntriesCallCount()).To(Equal(0))
+		})
+
+		It("flushes every cache", func() {
+			serve("DELETE", "/caches", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"flushed": {"health_check": 1, "rrsig": 3}}`))
+			Expect(healthCache.FlushArgsForCall(0)).To(Equal(""))
+			Expect(fakeLogger).To(gbytes.Say("caches-flushed"))
+		})
+
+		It("flushes a single name from a single cache", func() {
+			serve("DELETE", "/caches/rrsig?name=app-a.potato.", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"flushed": {"rrsig": 3}}`))
+			Expect(rrsigCache.FlushArgsForCall(0)).To(Equal("app-a.potato."))
+			Expect(healthCache.FlushCallCount()).To(Equal(0))
+		})
+
+		It("returns 404 for unknown caches", func() {
+			serve("GET", "/caches/potato", "")
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("GET /changes", func() {
+		BeforeEach(func() {
+			fakeChanges.ChangesReturns([]resolver.RecordChange{
+				{
+					Time:     expires,
+					Type:     resolver.ChangeAdded,
+					Name:     "some-app.potato.",
+					Instance: resolver.Instance{App: "some-app", IP: "10.0.0.1"},
+				},
+				{
+					Time:     expires.Add(time.Minute),
+					Type:     resolver.ChangeChanged,
+					Name:     "some-app.potato.",
+					Instance: resolver.Instance{App: "some-app", IP: "10.0.0.2", HostIP: "10.244.0.3"},
+					Previous: &resolver.Instance{App: "some-app", IP: "10.0.0.2", HostIP: "10.244.0.2"},
+				},
+			})
+		})
+
+		It("lists the recent record changes", func() {
+			serve("GET", "/changes", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`[
+				{
+					"time": "2016-05-01T12:00:00Z",
+					"type": "added",
+					"name": "some-app.potato.",
+					"instance": {"app": "some-app", "ip": "10.0.0.1", "host_ip": ""}
+				},
+				{
+					"time": "2016-05-01T12:01:00Z",
+					"type": "changed",
+					"name": "some-app.potato.",
+					"instance": {"app": "some-app", "ip": "10.0.0.2", "host_ip": "10.244.0.3"},
+					"previous": {"app": "some-app", "ip": "10.0.0.2", "host_ip": "10.244.0.2"}
+				}
+			]`))
+		})
+
+		It("lists only the changes of the given IP", func() {
+			serve("GET", "/changes?ip=10.0.0.1", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(ContainSubstring("10.0.0.1"))
+			Expect(recorder.Body.String()).NotTo(ContainSubstring("10.0.0.2"))
+		})
+	})
+
+	Describe("GET /upstreams", func() {
+		BeforeEach(func() {
+			fakeUpstreams.StatusReturns([]resolver.UpstreamStatus{
+				{Server: "1.2.3.4:53", Healthy: true, LastSuccess: expires},
+			})
+		})
+
+		It("shows upstream health", func() {
+			serve("GET", "/upstreams", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`[
+				{"server": "1.2.3.4:53", "healthy": true, "last_success": "2016-05-01T12:00:00Z"}
+			]`))
+		})
+	})
+
+	Describe("log level", func() {
+		BeforeEach(func() {
+			fakeLogLevel.GetMinLevelReturns(lager.INFO)
+		})
+
+		It("shows the current level", func() {
+			serve("GET", "/log-level", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"level": "info"}`))
+		})
+
+		It("changes the level", func() {
+			serve("PUT", "/log-level", `{"level": "DEBUG"}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"level": "debug"}`))
+			Expect(fakeLogLevel.SetMinLevelCallCount()).To(Equal(1))
+			Expect(fakeLogLevel.SetMinLevelArgsForCall(0)).To(Equal(lager.DEBUG))
+			Expect(fakeLogger).To(gbytes.Say("log-level-changed.*debug"))
+		})
+
+		It("rejects unknown levels", func() {
+			serve("PUT", "/log-level", `{"level": "loud"}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"error": "level must be one of debug, error, fatal, info"}`))
+			Expect(fakeLogLevel.SetMinLevelCallCount()).To(Equal(0))
+		})
+	})
+
+	It("returns 404 for unknown paths", func() {
+		serve("GET", "/potato", "")
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("rejects unsupported methods", func() {
+		serve("POST", "/records", "")
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
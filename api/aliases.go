@@ -0,0 +1,164 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager"
+)
+
+//go:generate counterfeiter -o ../fakes/alias_store.go --fake-name AliasStore . aliasStore
+type aliasStore interface {
+	Set(alias, target string) (bool, error)
+	Delete(alias string) (bool, error)
+	Get(alias string) (string, bool)
+	List() map[string]string
+}
+
+type Alias struct {
+	Name   string `json:"name"`
+	Target string `json:"target"`
+}
+
+type AliasHandler struct {
+	Logger lager.Logger
+	Store  aliasStore
+	Token  string
+}
+
+func (h *AliasHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.Logger.Session("alias-api", lager.Data{"method": r.Method, "path": r.URL.Path})
+
+	if !authorized(r, h.Token) {
+		logger.Info("unauthorized")
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	if r.URL.Path != "/aliases" && !strings.HasPrefix(r.URL.Path, "/aliases/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/aliases"), "/")
+
+	switch {
+	case name == "" && r.Method == "GET":
+		h.list(w)
+	case name == "":
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	case strings.Contains(name, ".") || !validLabel(name):
+		writeError(w, http.StatusBadRequest, "invalid alias name")
+	case r.Method == "GET":
+		h.get(w, name)
+	case r.Method == "PUT":
+		h.put(logger, w, r, name)
+	case r.Method == "DELETE":
+		h.delete(logger, w, name)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *AliasHandler) list(w http.ResponseWriter) {
+	aliases := []Alias{}
+	for name, target := range h.Store.List() {
+		aliases = append(aliases, Alias{Name: name, Target: target})
+	}
+	sort.Sort(byName(aliases))
+
+	writeJSON(w, http.StatusOK, aliases)
+}
+
+func (h *AliasHandler) get(w http.ResponseWriter, name string) {
+	target, ok := h.Store.Get(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "alias not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Alias{Name: name, Target: target})
+}
+
+func (h *AliasHandler) put(logger lager.Logger, w http.ResponseWriter, r *http.Request, name string) {
+	var alias Alias
+	if err := json.NewDecoder(r.Body).Decode(&alias); err != nil {
+		logger.Error("decode-failed", err)
+		writeError(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	if alias.Target == "" || strings.Contains(alias.Target, ".") || !validLabel(alias.Target) {
+		writeError(w, http.StatusBadRequest, "invalid target")
+		return
+	}
+
+	created, err := h.Store.Set(name, alias.Target)
+	if err != nil {
+		logger.Error("set-failed", err)
+		writeError(w, http.StatusInternalServerError, "failed to save alias")
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	logger.Info("alias-set", lager.Data{"alias": name, "target": alias.Target})
+
+	writeJSON(w, status, Alias{Name: name, Target: alias.Target})
+}
+
+func (h *AliasHandler) delete(logger lager.Logger, w http.ResponseWriter, name string) {
+	deleted, err := h.Store.Delete(name)
+	if err != nil {
+		logger.Error("delete-failed", err)
+		writeError(w, http.StatusInternalServerError, "failed to save aliases")
+		return
+	}
+	if !deleted {
+		writeError(w, http.StatusNotFound, "alias not found")
+		return
+	}
+	logger.Info("alias-deleted", lager.Data{"alias": name})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func validLabel(label string) bool {
+	_, ok := dns.IsDomainName(label)
+	return ok && len(label) <= 63
+}
+
+func authorized(r *http.Request, token string) bool {
+	if token == "" {
+		return true
+	}
+
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	provided := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+type byName []Alias
+
+func (b byName) Len() int           { return len(b) }
+func (b byName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byName) Less(i, j int) bool { return b[i].Name < b[j].Name }
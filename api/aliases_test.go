@@ -0,0 +1,191 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/api"
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("AliasHandler", func() {
+	var (
+		handler    *api.AliasHandler
+		fakeStore  *fakes.AliasStore
+		fakeLogger *lagertest.TestLogger
+		recorder   *httptest.ResponseRecorder
+	)
+
+	serve := func(method, path, body string) {
+		request, err := http.NewRequest(method, path, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		request.Header.Set("Authorization", "Bearer some-token")
+		handler.ServeHTTP(recorder, request)
+	}
+
+	BeforeEach(func() {
+		fakeLogger = lagertest.NewTestLogger("test")
+		fakeStore = &fakes.AliasStore{}
+		handler = &api.AliasHandler{
+			Logger: fakeLogger,
+			Store:  fakeStore,
+			Token:  "some-token",
+		}
+		recorder = httptest.NewRecorder()
+	})
+
+	Describe("listing aliases", func() {
+		BeforeEach(func() {
+			fakeStore.ListReturns(map[string]string{
+				"payments": "payments-green-guid",
+				"billing":  "billing-guid",
+			})
+		})
+
+		It("returns every alias sorted by name", func() {
+			serve("GET", "/aliases", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`[
+				{"name": "billing", "target": "billing-guid"},
+				{"name": "payments", "target": "payments-green-guid"}
+			]`))
+		})
+	})
+
+	Describe("getting an alias", func() {
+		It("returns the alias", func() {
+			fakeStore.GetReturns("payments-green-guid", true)
+			serve("GET", "/aliases/payments", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(fakeStore.GetArgsForCall(0)).To(Equal("payments"))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"name": "payments", "target": "payments-green-guid"}`))
+		})
+
+		It("returns 404 when the alias does not exist", func() {
+			serve("GET", "/aliases/payments", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("setting an alias", func() {
+		It("creates the alias", func() {
+			fakeStore.SetReturns(true, nil)
+			serve("PUT", "/aliases/payments", `{"target": "payments-blue-guid"}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+			alias, target := fakeStore.SetArgsForCall(0)
+			Expect(alias).To(Equal("payments"))
+			Expect(target).To(Equal("payments-blue-guid"))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"name": "payments", "target": "payments-blue-guid"}`))
+		})
+
+		It("responds with 200 when the alias is flipped to a new target", func() {
+			fakeStore.SetReturns(false, nil)
+			serve("PUT", "/aliases/payments", `{"target": "payments-green-guid"}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+
+		It("logs the change", func() {
+			serve("PUT", "/aliases/payments", `{"target": "payments-green-guid"}`)
+
+			Expect(fakeLogger).To(gbytes.Say("alias-api.alias-set.*payments.*payments-green-guid"))
+		})
+
+		It("rejects invalid json", func() {
+			serve("PUT", "/aliases/payments", `{`)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(fakeStore.SetCallCount()).To(Equal(0))
+		})
+
+		It("rejects missing targets", func() {
+			serve("PUT", "/aliases/payments", `{}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"error": "invalid target"}`))
+		})
+
+		It("rejects alias names that are not a single label", func() {
+			serve("PUT", "/aliases/pay.ments", `{"target": "payments-green-guid"}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(fakeStore.SetCallCount()).To(Equal(0))
+		})
+
+		It("responds with 500 when the alias cannot be saved", func() {
+			fakeStore.SetReturns(false, errors.New("disk full"))
+			serve("PUT", "/aliases/payments", `{"target": "payments-green-guid"}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+			Expect(fakeLogger).To(gbytes.Say("alias-api.set-failed.*disk full"))
+		})
+	})
+
+	Describe("deleting an alias", func() {
+		It("deletes the alias", func() {
+			fakeStore.DeleteReturns(true, nil)
+			serve("DELETE", "/aliases/payments", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusNoContent))
+			Expect(fakeStore.DeleteArgsForCall(0)).To(Equal("payments"))
+		})
+
+		It("returns 404 when the alias does not exist", func() {
+			serve("DELETE", "/aliases/payments", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("responds with 500 when the aliases cannot be saved", func() {
+			fakeStore.DeleteReturns(false, errors.New("disk full"))
+			serve("DELETE", "/aliases/payments", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("when the request does not carry the token", func() {
+		It("responds with 401", func() {
+			request, err := http.NewRequest("GET", "/aliases", nil)
+			Expect(err).NotTo(HaveOccurred())
+			request.Header.Set("Authorization", "Bearer wrong-token")
+			handler.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(fakeStore.ListCallCount()).To(Equal(0))
+		})
+
+		It("responds with 401 to the token without the Bearer scheme", func() {
+			request, err := http.NewRequest("GET", "/aliases", nil)
+			Expect(err).NotTo(HaveOccurred())
+			request.Header.Set("Authorization", "some-token")
+			handler.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(fakeStore.ListCallCount()).To(Equal(0))
+		})
+	})
+
+	It("returns 404 for other paths", func() {
+		serve("GET", "/something-else", "")
+
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("rejects unsupported methods", func() {
+		serve("POST", "/aliases", "")
+
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
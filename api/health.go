@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/pivotal-golang/lager"
+)
+
+//go:generate counterfeiter -o ../fakes/checker.go --fake-name Checker . Checker
+type Checker interface {
+	Check() error
+}
+
+// CheckFunc adapts a function to a Checker.
+type CheckFunc func() error
+
+func (f CheckFunc) Check() error {
+	return f()
+}
+
+type CheckResult struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+type HealthStatus struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// HealthHandler serves /healthz from the Liveness checks and /readyz from
+// the Readiness checks, responding 503 if any of them fail.
+type HealthHandler struct {
+	Logger    lager.Logger
+	Liveness  map[string]Checker
+	Readiness map[string]Checker
+}
+
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var checks map[string]Checker
+	switch r.URL.Path {
+	case "/healthz":
+		checks = h.Liveness
+	case "/readyz":
+		checks = h.Readiness
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	names := []string{}
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	status := HealthStatus{Status: "ok", Checks: []CheckResult{}}
+	for _, name := range names {
+		result := CheckResult{Name: name, Healthy: true}
+		if err := checks[name].Check(); err != nil {
+			h.Logger.Info("check-failed", lager.Data{"path": r.URL.Path, "check": name, "error": err.Error()})
+			result.Healthy = false
+			result.Error = err.Error()
+			status.Status = "failing"
+		}
+		status.Checks = append(status.Checks, result)
+	}
+
+	code := http.StatusOK
+	if status.Status != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, status)
+}
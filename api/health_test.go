@@ -0,0 +1,105 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/api"
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("HealthHandler", func() {
+	var (
+		handler    *api.HealthHandler
+		serverLoop *fakes.Checker
+		listeners  *fakes.Checker
+		daemon     *fakes.Checker
+		fakeLogger *lagertest.TestLogger
+		recorder   *httptest.ResponseRecorder
+	)
+
+	serve := func(method, path string) {
+		request, err := http.NewRequest(method, path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		handler.ServeHTTP(recorder, request)
+	}
+
+	BeforeEach(func() {
+		fakeLogger = lagertest.NewTestLogger("test")
+		serverLoop = &fakes.Checker{}
+		listeners = &fakes.Checker{}
+		daemon = &fakes.Checker{}
+		handler = &api.HealthHandler{
+			Logger:    fakeLogger,
+			Liveness:  map[string]api.Checker{"server_loop": serverLoop},
+			Readiness: map[string]api.Checker{"listeners": listeners, "daemon": daemon},
+		}
+		recorder = httptest.NewRecorder()
+	})
+
+	It("reports liveness on /healthz", func() {
+		serve("GET", "/healthz")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(MatchJSON(`{
+			"status": "ok",
+			"checks": [{"name": "server_loop", "healthy": true}]
+		}`))
+		Expect(serverLoop.CheckCallCount()).To(Equal(1))
+		Expect(listeners.CheckCallCount()).To(Equal(0))
+	})
+
+	It("reports readiness on /readyz", func() {
+		serve("GET", "/readyz")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(MatchJSON(`{
+			"status": "ok",
+			"checks": [
+				{"name": "daemon", "healthy": true},
+				{"name": "listeners", "healthy": true}
+			]
+		}`))
+	})
+
+	Context("when a check fails", func() {
+		BeforeEach(func() {
+			daemon.CheckReturns(errors.New("connection refused"))
+		})
+
+		It("responds 503 and explains the failing check", func() {
+			serve("GET", "/readyz")
+
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(recorder.Body.String()).To(MatchJSON(`{
+				"status": "failing",
+				"checks": [
+					{"name": "daemon", "healthy": false, "error": "connection refused"},
+					{"name": "listeners", "healthy": true}
+				]
+			}`))
+		})
+
+		It("logs the failure", func() {
+			serve("GET", "/readyz")
+
+			Expect(fakeLogger).To(gbytes.Say("check-failed.*daemon.*connection refused"))
+		})
+	})
+
+	It("returns 404 for other paths", func() {
+		serve("GET", "/aliases")
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("rejects methods other than GET and HEAD", func() {
+		serve("POST", "/healthz")
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
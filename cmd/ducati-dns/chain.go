@@ -0,0 +1,477 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/api"
+	"github.com/cloudfoundry-incubator/ducati-dns/config"
+	"github.com/cloudfoundry-incubator/ducati-dns/metrics"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+// shared holds what outlives a reload: the stores that clients write to,
+// the metrics registry, the record change history and the overlay zones.
+type shared struct {
+	logger   lager.Logger
+	metrics  *metrics.Metrics
+	aliases  *resolver.AliasStore
+	registry *resolver.Registry
+	changes  *resolver.ChangeHistory
+	zones    *overlayZones
+}
+
+// overlayZones keeps the overlay zone of each suffix across reloads, so
+// that its SOA serial and IXFR history carry on.  A zone whose TTL changed
+// starts over, as every record in it changed.
+type overlayZones struct {
+	mutex sync.Mutex
+	zones map[string]*resolver.OverlayZone
+}
+
+func (z *overlayZones) zone(suffix string, ttl int) *resolver.OverlayZone {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	if z.zones == nil {
+		z.zones = map[string]*resolver.OverlayZone{}
+	}
+	zone, ok := z.zones[suffix]
+	if !ok || zone.TTL != ttl {
+		zone = resolver.NewOverlayZone(suffix, ttl)
+		z.zones[suffix] = zone
+	}
+	return zone
+}
+
+// chain is the DNS handler stack built from one configuration.  A reload
+// replaces it as a whole.
+type chain struct {
+	handler      dns.Handler
+	upstreams    *resolver.UpstreamHealth
+	daemons      []*resolver.DaemonHealth
+	zoneTransfer *resolver.ZoneTransfer
+	clusterZones []*resolver.ZoneTransfer
+	pollers      []ifrit.Runner
+	caches       map[string]api.Cache
+}
+
+func buildChain(c config.Config, s shared) (*chain, error) {
+	logger := s.logger
+	built := &chain{caches: map[string]api.Cache{}}
+
+	built.upstreams = &resolver.UpstreamHealth{
+		Exchanger: &resolver.TCPFallback{
+			UDP: &metrics.Exchanger{Metrics: s.metrics, Exchanger: &dns.Client{Net: "udp"}},
+			TCP: &metrics.Exchanger{Metrics: s.metrics, Exchanger: &dns.Client{Net: "tcp"}},
+		},
+		Servers: c.Upstreams,
+		Window:  c.Health.ReadinessWindow,
+	}
+
+	forwardingResolver := &resolver.ForwardingResolver{
+		Logger:    logger.Session("forwarding-resolver"),
+		Exchanger: built.upstreams,
+		Server:    c.Upstreams[0],
+		Fallbacks: c.Upstreams[1:],
+	}
+
+	if c.DNSSEC.TrustAnchor != "" {
+		trustAnchors, err := resolver.LoadTrustAnchors(c.DNSSEC.TrustAnchor)
+		if err != nil {
+			return nil, fmt.Errorf("trust anchors: %s", err)
+		}
+		forwardingResolver.Validator = &resolver.Validator{
+			Logger:       logger.Session("validator"),
+			Exchanger:    forwardingResolver.Exchanger,
+			TrustAnchors: trustAnchors,
+			Metrics:      s.metrics,
+		}
+		built.caches["dnskey"] = forwardingResolver.Validator
+	}
+
+	forwardHandler := &metrics.Handler{Name: "forward", Metrics: s.metrics, Next: forwardingResolver}
+
+	httpResolver, err := built.overlayResolver(logger, c, c.Overlay, s, forwardingResolver)
+	if err != nil {
+		return nil, err
+	}
+	httpResolver.Aliases = s.aliases
+	httpResolver.Registry = s.registry
+	if healthChecker := healthChecker(httpResolver); healthChecker != nil {
+		built.caches["health_check"] = healthChecker
+	}
+
+	var overlayHandler dns.Handler = httpResolver
+	if c.StaticRecords.Path != "" {
+		staticRecords := &resolver.StaticRecords{
+			Logger:       logger.Session("static-records"),
+			Path:         c.StaticRecords.Path,
+			PollInterval: c.StaticRecords.PollInterval,
+			Suffix:       c.Overlay.DucatiSuffix,
+			Next:         httpResolver,
+		}
+		if err := staticRecords.Load(); err != nil {
+			return nil, fmt.Errorf("static records: %s", err)
+		}
+		overlayHandler = staticRecords
+		built.pollers = append(built.pollers, staticRecords)
+	}
+
+	if s.registry != nil {
+		overlayHandler = &resolver.UpdateHandler{
+			Logger:       logger.Session("update-handler"),
+			Registry:     s.registry,
+			Suffix:       c.Overlay.DucatiSuffix,
+			DefaultLease: c.Update.DefaultLease,
+			MaxLease:     c.Update.MaxLease,
+			Next:         overlayHandler,
+		}
+	}
+
+	transferNetworks, err := c.TransferNetworks()
+	if err != nil {
+		return nil, fmt.Errorf("transfer networks: %s", err)
+	}
+	built.zoneTransfer = &resolver.ZoneTransfer{
+		Logger:          logger.Session("zone-transfer"),
+		Source:          httpResolver.Source,
+		Registry:        s.registry,
+		Aliases:         s.aliases,
+		ChangeLog:       changeLog(logger, c, c.Overlay.DucatiSuffix, s),
+		HealthChecker:   healthChecker(httpResolver),
+		Zone:            s.zones.zone(c.Overlay.DucatiSuffix, httpResolver.TTL),
+		Interval:        c.ZoneRefresh,
+		AllowedNetworks: transferNetworks,
+		RequireTSIG:     c.TransferTSIG && c.TSIGKey != "",
+		Next:            overlayHandler,
+	}
+	overlayHandler = built.zoneTransfer
+	built.pollers = append(built.pollers, built.zoneTransfer)
+
+	if len(c.DNSSEC.Keys) > 0 {
+		signingKeys := []*resolver.SigningKey{}
+		for _, path := range c.DNSSEC.Keys {
+			key, err := resolver.LoadSigningKey(path)
+			if err != nil {
+				return nil, fmt.Errorf("dnssec key %s: %s", path, err)
+			}
+			signingKeys = append(signingKeys, key)
+		}
+		signer := &resolver.Signer{
+			Logger:      logger.Session("signer"),
+			Zone:        c.Overlay.DucatiSuffix,
+			OverlayZone: built.zoneTransfer.Zone,
+			Keys:        signingKeys,
+			Validity:    c.DNSSEC.SignatureValidity,
+			Metrics:     s.metrics,
+			Next:        overlayHandler,
+		}
+		overlayHandler = signer
+		built.caches["rrsig"] = signer
+	}
+
+	zones := map[string]dns.Handler{}
+	for origin, path := range c.Zones {
+		zone := &resolver.Zone{
+			Logger: logger.Session("zone", lager.Data{"origin": origin}),
+			Origin: origin,
+			Path:   path,
+		}
+		if err := zone.Load(); err != nil {
+			return nil, fmt.Errorf("zone: %s", err)
+		}
+		zones[origin] = &metrics.Handler{Name: "zone", Metrics: s.metrics, Next: zone}
+	}
+
+	overlays := map[string]dns.Handler{}
+	for _, cluster := range c.Overlay.Clusters {
+		clusterLogger := logger.Session("cluster", lager.Data{"suffix": cluster.Suffix})
+		clusterResolver, err := built.overlayResolver(clusterLogger, c, c.Overlay.ForCluster(cluster), s, forwardingResolver)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %s", cluster.Suffix, err)
+		}
+		overlays[cluster.Suffix] = &metrics.Handler{Name: "overlay", Metrics: s.metrics, Next: clusterResolver}
+
+		// Clusters are not transferred; their zones are only kept for the
+		// admin API and the change log.
+		clusterZone := &resolver.ZoneTransfer{
+			Logger:        clusterLogger.Session("zone"),
+			Source:        clusterResolver.Source,
+			ChangeLog:     changeLog(clusterLogger, c, cluster.Suffix, s),
+			HealthChecker: healthChecker(clusterResolver),
+			Zone:          s.zones.zone(cluster.Suffix, clusterResolver.TTL),
+			Interval:      c.ZoneRefresh,
+			Next:          clusterResolver,
+		}
+		built.clusterZones = append(built.clusterZones, clusterZone)
+		built.pollers = append(built.pollers, clusterZone)
+	}
+
+	built.handler = &resolver.Muxer{
+		Logger:               logger,
+		Suffix:               c.Overlay.DucatiSuffix,
+		SuffixPresentHandler: &metrics.Handler{Name: "overlay", Metrics: s.metrics, Next: overlayHandler},
+		Overlays:             overlays,
+		DefaultHandler:       forwardHandler,
+		Zones:                zones,
+	}
+
+	return built, nil
+}
+
+// overlayResolver builds the resolver for one overlay network and hooks up
+// its record sources.  Fallthrough queries
+// go straight to the forwarder; the overlay's metrics handler counts them.
+func (built *chain) overlayResolver(logger lager.Logger, c config.Config, overlay resolver.Config, s shared, forwarder dns.Handler) (*resolver.HTTPResolver, error) {
+	httpResolver, err := resolver.NewHTTPResolver(logger, overlay)
+	if err != nil {
+		return nil, err
+	}
+	for _, source := range recordSources(httpResolver.Source) {
+		switch source := source.(type) {
+		case *resolver.DaemonSource:
+			built.hookDaemon(c, s, source)
+		case *resolver.FileSource:
+			built.pollers = append(built.pollers, source)
+		}
+	}
+	if healthChecker := healthChecker(httpResolver); healthChecker != nil {
+		healthChecker.Metrics = s.metrics
+		built.pollers = append(built.pollers, healthChecker)
+	}
+	if overlay.Fallthrough {
+		httpResolver.Fallthrough = forwarder
+	}
+	return httpResolver, nil
+}
+
+// changeLog returns the change log fed by the overlay's zone rebuilds, or
+// nil when it is disabled.
+func changeLog(logger lager.Logger, c config.Config, suffix string, s shared) *resolver.ChangeLog {
+	if !c.ChangeLog.Enabled {
+		return nil
+	}
+	return &resolver.ChangeLog{
+		Logger:    logger.Session("change-log"),
+		Suffix:    suffix,
+		AuditFile: c.ChangeLog.AuditFile,
+		History:   s.changes,
+	}
+}
+
+// hookDaemon adds the source's daemon to those checked for readiness, times
+// the requests to each of its endpoints and adds its snapshot to the
+// pollers.
+func (built *chain) hookDaemon(c config.Config, s shared, source *resolver.DaemonSource) {
+	if coalescing, ok := source.Client.(*resolver.CoalescingClient); ok {
+		if breaker, ok := coalescing.Client.(*resolver.CircuitBreaker); ok {
+			if retrying, ok := breaker.Client.(*resolver.RetryingClient); ok {
+				if failover, ok := retrying.Client.(*resolver.FailoverClient); ok {
+					for i, endpoint := range failover.Endpoints {
+						failover.Endpoints[i].Client = &metrics.DaemonClient{Metrics: s.metrics, Client: endpoint.Client}
+					}
+				}
+			}
+			daemon := &resolver.DaemonHealth{
+				Client: breaker.Client,
+				Window: c.Health.ReadinessWindow,
+			}
+			breaker.Client = daemon
+			built.daemons = append(built.daemons, daemon)
+		}
+	}
+	if source.Snapshot != nil {
+		built.pollers = append(built.pollers, source.Snapshot)
+	}
+}
+
+// healthChecker returns the resolver's health checker, which its zone
+// rebuilds prune, or nil when it has none.
+func healthChecker(r *resolver.HTTPResolver) *resolver.HealthChecker {
+	healthChecker, _ := r.HealthChecker.(*resolver.HealthChecker)
+	return healthChecker
+}
+
+// recordSources returns the sources a resolver's Source merges.
+func recordSources(source resolver.RecordSource) []resolver.RecordSource {
+	if merge, ok := source.(*resolver.MergeSource); ok {
+		return merge.Sources
+	}
+	return []resolver.RecordSource{source}
+}
+
+// reloadableChain serves queries with the current chain and replaces it
+// when Load builds a new one from a valid configuration.  Queries already
+// in progress finish on the chain they started with.
+type reloadableChain struct {
+	Logger  lager.Logger
+	Handler *resolver.HandlerSwitch
+	Shared  shared
+	Args    []string
+	Env     []string
+
+	mutex   sync.Mutex
+	config  config.Config
+	current *chain
+	pollers []ifrit.Process
+	stopped bool
+}
+
+// Load re-reads the configuration and swaps in the chain built from it.
+// On any error the previous chain stays active.
+func (r *reloadableChain) Load() error {
+	c, _, err := config.Load(r.Args, r.Env)
+	if err != nil {
+		return fmt.Errorf("load config: %s", err)
+	}
+	return r.Swap(c)
+}
+
+func (r *reloadableChain) Swap(c config.Config) error {
+	next, err := buildChain(c, r.Shared)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.stopped {
+		return nil
+	}
+
+	if r.current != nil {
+		if changed := restartRequired(r.config, c); len(changed) > 0 {
+			r.Logger.Info("restart-required", lager.Data{"settings": changed})
+		}
+	}
+
+	r.Handler.Store(next.handler)
+
+	previous := r.pollers
+	r.pollers = nil
+	for _, poller := range next.pollers {
+		r.pollers = append(r.pollers, ifrit.Background(poller))
+	}
+	stopAll(previous)
+
+	r.config = c
+	r.current = next
+	r.Logger.Info("config-loaded")
+	return nil
+}
+
+// Run keeps the pollers of the current chain running until it is
+// signalled.
+func (r *reloadableChain) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+	<-signals
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.stopped = true
+	stopAll(r.pollers)
+	return nil
+}
+
+func stopAll(processes []ifrit.Process) {
+	for _, process := range processes {
+		process.Signal(os.Interrupt)
+	}
+	for _, process := range processes {
+		<-process.Wait()
+	}
+}
+
+func (r *reloadableChain) chain() *chain {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.current
+}
+
+// Records lists the current records of the main overlay and of every
+// cluster.
+func (r *reloadableChain) Records() ([]dns.RR, error) {
+	current := r.chain()
+
+	rrs, err := current.zoneTransfer.Records()
+	if err != nil {
+		return nil, err
+	}
+	for _, zone := range current.clusterZones {
+		clusterRRs, err := zone.Records()
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %s", zone.Zone.Suffix, err)
+		}
+		rrs = append(rrs, clusterRRs...)
+	}
+	return rrs, nil
+}
+
+func (r *reloadableChain) Status() []resolver.UpstreamStatus {
+	return r.chain().upstreams.Status()
+}
+
+func (r *reloadableChain) checkDaemon() error {
+	for _, daemon := range r.chain().daemons {
+		if err := daemon.Check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *reloadableChain) checkUpstreams() error {
+	return r.chain().upstreams.Check()
+}
+
+func (r *reloadableChain) cache(name string) api.Cache {
+	return &chainCache{name: name, chains: r}
+}
+
+// chainCache is the cache of the given name in whichever chain is current.
+type chainCache struct {
+	name   string
+	chains *reloadableChain
+}
+
+func (c *chainCache) Entries() []resolver.CacheEntry {
+	if cache, ok := c.chains.chain().caches[c.name]; ok {
+		return cache.Entries()
+	}
+	return []resolver.CacheEntry{}
+}
+
+func (c *chainCache) Flush(name string) int {
+	if cache, ok := c.chains.chain().caches[c.name]; ok {
+		return cache.Flush(name)
+	}
+	return 0
+}
+
+// restartRequired lists the settings that changed between old and new but
+// only take effect when the process starts.
+func restartRequired(old, new config.Config) []string {
+	changed := []string{}
+	check := func(key string, before, after string) {
+		if before != after {
+			changed = append(changed, key)
+		}
+	}
+	check("listen_address", old.ListenAddress, new.ListenAddress)
+	check("tsig_key", old.TSIGKey, new.TSIGKey)
+	check("alias_api.listen_address", old.AliasAPI.ListenAddress, new.AliasAPI.ListenAddress)
+	check("alias_api.token", old.AliasAPI.Token, new.AliasAPI.Token)
+	check("alias_api.path", old.AliasAPI.Path, new.AliasAPI.Path)
+	check("metrics.listen_address", old.Metrics.ListenAddress, new.Metrics.ListenAddress)
+	check("health.listen_address", old.Health.ListenAddress, new.Health.ListenAddress)
+	check("admin.listen_address", old.Admin.ListenAddress, new.Admin.ListenAddress)
+	check("admin.token", old.Admin.Token, new.Admin.Token)
+	return changed
+}
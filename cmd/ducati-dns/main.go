@@ -1,58 +1,65 @@
 package main
 
 import (
-	"errors"
 	"flag"
 	"log"
 	"net"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/cloudfoundry-incubator/ducati-dns/api"
+	"github.com/cloudfoundry-incubator/ducati-dns/config"
+	"github.com/cloudfoundry-incubator/ducati-dns/metrics"
 	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
 	"github.com/cloudfoundry-incubator/ducati-dns/runner"
 	"github.com/pivotal-golang/lager"
 	"github.com/tedsuo/ifrit"
 	"github.com/tedsuo/ifrit/grouper"
+	"github.com/tedsuo/ifrit/http_server"
 	"github.com/tedsuo/ifrit/sigmon"
+	"gopkg.in/yaml.v2"
 )
 
-func validate(c resolver.Config) error {
-	if c.DucatiSuffix == "" {
-		return errors.New("missing required arg: ducatiSuffix")
-	}
-	if c.DucatiAPI == "" {
-		return errors.New("missing required arg: ducatiAPI")
+// printConfig writes the effective configuration to stdout and exits,
+// reporting any validation error after it.
+func printConfig(cfg config.Config, err error) {
+	out, marshalErr := yaml.Marshal(cfg.Redacted())
+	if marshalErr != nil {
+		log.Fatalf("print config: %s", marshalErr)
 	}
+	os.Stdout.Write(out)
 
-	return nil
+	if err != nil {
+		log.Fatalf("config: %s", err)
+	}
+	os.Exit(0)
 }
 
 func main() {
-	var (
-		config            resolver.Config
-		externalDNSServer string
-		listenAddress     string
-	)
-
-	flag.StringVar(&externalDNSServer, "server", "", "Single DNS server to forward queries to")
-	flag.StringVar(&config.DucatiSuffix, "ducatiSuffix", "", "suffix for lookups on the overlay network")
-	flag.StringVar(&config.DucatiAPI, "ducatiAPI", "", "URL for the ducati API")
-	flag.StringVar(&listenAddress, "listenAddress", "127.0.0.1:53", "Host and port to listen for queries on")
-	flag.Parse()
-
-	if err := validate(config); err != nil {
-		log.Fatalf("validate: %s", err)
+	cfg, cmd, err := config.Load(os.Args[1:], os.Environ())
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if cmd.PrintConfig {
+		printConfig(cfg, err)
+	}
+	if err != nil {
+		log.Fatalf("config: %s", err)
 	}
 
-	if externalDNSServer == "" {
-		log.Fatalf("missing required arg: server")
+	tsigSecrets, err := cfg.TSIGSecrets()
+	if err != nil {
+		log.Fatalf("invalid tsigKey: %s", err)
 	}
 
 	logger := lager.NewLogger("ducati-dns")
-	logger.RegisterSink(lager.NewWriterSink(os.Stdout, lager.INFO))
+	logSink := lager.NewReconfigurableSink(lager.NewWriterSink(os.Stdout, lager.DEBUG), lager.INFO)
+	logger.RegisterSink(logSink)
 
-	udpAddr, err := net.ResolveUDPAddr("udp", listenAddress)
+	udpAddr, err := net.ResolveUDPAddr("udp", cfg.ListenAddress)
 	if err != nil {
-		log.Fatalf("invalid listen address %s: %s", listenAddress, err)
+		log.Fatalf("invalid listen address %s: %s", cfg.ListenAddress, err)
 	}
 	udpConn, err := net.ListenUDP("udp", udpAddr)
 	if err != nil {
@@ -60,12 +67,108 @@ func main() {
 	}
 	defer udpConn.Close()
 
-	dnsRunner := runner.New(logger, config, externalDNSServer, udpConn, nil)
+	tcpListener, err := net.Listen("tcp", cfg.ListenAddress)
+	if err != nil {
+		log.Fatalf("listen: %s", err)
+	}
+	defer tcpListener.Close()
+
+	appState := shared{
+		logger:  logger,
+		changes: &resolver.ChangeHistory{Max: cfg.ChangeLog.History},
+		zones:   &overlayZones{},
+	}
+	if cfg.Metrics.ListenAddress != "" {
+		appState.metrics = metrics.New()
+	}
+	if cfg.AliasAPI.ListenAddress != "" {
+		appState.aliases = &resolver.AliasStore{Path: cfg.AliasAPI.Path}
+		if cfg.AliasAPI.Path != "" {
+			if err := appState.aliases.Load(); err != nil {
+				log.Fatalf("%s", err)
+			}
+		}
+	}
+	if tsigSecrets != nil {
+		appState.registry = &resolver.Registry{}
+	}
+
+	handlerSwitch := &resolver.HandlerSwitch{}
+	chains := &reloadableChain{
+		Logger:  logger.Session("config"),
+		Handler: handlerSwitch,
+		Shared:  appState,
+		Args:    os.Args[1:],
+		Env:     os.Environ(),
+	}
+	if err := chains.Swap(cfg); err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
 
 	members := grouper.Members{
-		{"dns_runner", dnsRunner},
+		{"chain", chains},
+		{"reloader", &runner.Reloader{
+			Logger:  logger.Session("reloader"),
+			Reloads: hangups,
+			Loaders: []runner.Loader{chains},
+		}},
+	}
+
+	if appState.aliases != nil {
+		members = append(members, grouper.Member{"alias_api", http_server.New(cfg.AliasAPI.ListenAddress, &api.AliasHandler{
+			Logger: logger,
+			Store:  appState.aliases,
+			Token:  cfg.AliasAPI.Token,
+		})})
+	}
+
+	dnsRunner := runner.New(handlerSwitch, udpConn, nil, tsigSecrets)
+	dnsTCPRunner := runner.NewTCP(handlerSwitch, tcpListener, tsigSecrets)
+
+	if appState.metrics != nil {
+		members = append(members, grouper.Member{"metrics", http_server.New(cfg.Metrics.ListenAddress, appState.metrics.Handler())})
+	}
+
+	if cfg.Admin.ListenAddress != "" {
+		members = append(members, grouper.Member{"admin_api", http_server.New(cfg.Admin.ListenAddress, &api.AdminHandler{
+			Logger:  logger,
+			Records: chains,
+			Changes: appState.changes,
+			Caches: map[string]api.Cache{
+				"health_check": chains.cache("health_check"),
+				"dnskey":       chains.cache("dnskey"),
+				"rrsig":        chains.cache("rrsig"),
+			},
+			Upstreams: chains,
+			LogLevel:  logSink,
+			Token:     cfg.Admin.Token,
+		})})
 	}
 
+	if cfg.Health.ListenAddress != "" {
+		members = append(members, grouper.Member{"health", http_server.New(cfg.Health.ListenAddress, &api.HealthHandler{
+			Logger: logger.Session("health"),
+			Liveness: map[string]api.Checker{
+				"udp_server": api.CheckFunc(dnsRunner.Alive),
+				"tcp_server": api.CheckFunc(dnsTCPRunner.Alive),
+			},
+			Readiness: map[string]api.Checker{
+				"udp_listener": api.CheckFunc(dnsRunner.Listening),
+				"tcp_listener": api.CheckFunc(dnsTCPRunner.Listening),
+				"daemon":       api.CheckFunc(chains.checkDaemon),
+				"upstream":     api.CheckFunc(chains.checkUpstreams),
+			},
+		})})
+	}
+
+	members = append(members,
+		grouper.Member{"dns_runner", dnsRunner},
+		grouper.Member{"dns_tcp_runner", dnsTCPRunner},
+	)
+
 	group := grouper.NewOrdered(os.Interrupt, members)
 
 	monitor := ifrit.Invoke(sigmon.New(group))
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/cloudfoundry-incubator/ducati-dns/uaa"
+	"gopkg.in/yaml.v2"
+)
+
+type StaticRecords struct {
+	Path         string        `yaml:"path"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+type Update struct {
+	DefaultLease time.Duration `yaml:"default_lease"`
+	MaxLease     time.Duration `yaml:"max_lease"`
+}
+
+type API struct {
+	ListenAddress string `yaml:"listen_address"`
+	Token         string `yaml:"token"`
+}
+
+type AliasAPI struct {
+	ListenAddress string `yaml:"listen_address"`
+	Token         string `yaml:"token"`
+	Path          string `yaml:"path"`
+}
+
+type DNSSEC struct {
+	Keys              []string      `yaml:"keys"`
+	SignatureValidity time.Duration `yaml:"signature_validity"`
+	TrustAnchor       string        `yaml:"trust_anchor"`
+}
+
+type Metrics struct {
+	ListenAddress string `yaml:"listen_address"`
+}
+
+type Health struct {
+	ListenAddress   string        `yaml:"listen_address"`
+	ReadinessWindow time.Duration `yaml:"readiness_window"`
+}
+
+type ChangeLog struct {
+	Enabled   bool   `yaml:"enabled"`
+	AuditFile string `yaml:"audit_file"`
+	History   int    `yaml:"history"`
+}
+
+// Config is everything ducati-dns can be configured with, as read from a
+// YAML or JSON file and overridden by flags.
+type Config struct {
+	ListenAddress string            `yaml:"listen_address"`
+	Upstreams     []string          `yaml:"upstreams"`
+	Overlay       resolver.Config   `yaml:"overlay"`
+	StaticRecords StaticRecords     `yaml:"static_records"`
+	Zones         map[string]string `yaml:"zones"`
+	TransferAllow []string          `yaml:"transfer_allow"`
+	TransferTSIG  bool              `yaml:"transfer_tsig"`
+	ZoneRefresh   time.Duration     `yaml:"zone_refresh"`
+	TSIGKey       string            `yaml:"tsig_key"`
+	Update        Update            `yaml:"update"`
+	AliasAPI      AliasAPI          `yaml:"alias_api"`
+	DNSSEC        DNSSEC            `yaml:"dnssec"`
+	Metrics       Metrics           `yaml:"metrics"`
+	Health        Health            `yaml:"health"`
+	Admin         API               `yaml:"admin"`
+	ChangeLog     ChangeLog         `yaml:"change_log"`
+}
+
+func Defaults() Config {
+	return Config{
+		ListenAddress: "127.0.0.1:53",
+		Overlay: resolver.Config{
+			UAA: uaa.Config{ClientID: "ducati_dns"},
+			Daemon: resolver.DaemonConfig{
+				Timeout:          2 * time.Second,
+				Retries:          2,
+				RetryBackoff:     100 * time.Millisecond,
+				BreakerThreshold: 5,
+				BreakerCooldown:  30 * time.Second,
+				MaxStale:         5 * time.Minute,
+				ListingMaxAge:    time.Second,
+			},
+			Snapshot: resolver.SnapshotConfig{
+				Interval: 30 * time.Second,
+				TTL:      5,
+			},
+			RecordsFile: resolver.RecordsFileConfig{PollInterval: 5 * time.Second},
+			HealthCheck: resolver.HealthCheckConfig{
+				Path:     "/",
+				Timeout:  time.Second,
+				Interval: 10 * time.Second,
+				Workers:  8,
+			},
+		},
+		StaticRecords: StaticRecords{PollInterval: 5 * time.Second},
+		TransferTSIG:  true,
+		ZoneRefresh:   10 * time.Second,
+		Update: Update{
+			DefaultLease: time.Hour,
+			MaxLease:     24 * time.Hour,
+		},
+		DNSSEC:    DNSSEC{SignatureValidity: 7 * 24 * time.Hour},
+		Health:    Health{ReadinessWindow: 30 * time.Second},
+		Admin:     API{ListenAddress: "127.0.0.1:8055"},
+		ChangeLog: ChangeLog{History: 1000},
+	}
+}
+
+// Parse reads a YAML or JSON document on top of the defaults.  Unknown keys
+// are errors so that typos do not go unnoticed.
+func Parse(data []byte) (Config, error) {
+	c := Defaults()
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return Config{}, fmt.Errorf("parse config: %s", err)
+	}
+	return c, nil
+}
+
+func LoadFile(path string) (Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("read config: %s", err)
+	}
+	return Parse(data)
+}
+
+// Command holds the flags that choose what to do rather than how to serve.
+type Command struct {
+	ConfigPath  string
+	PrintConfig bool
+}
+
+func (cmd *Command) RegisterFlags(flags *flag.FlagSet) {
+	flags.StringVar(&cmd.ConfigPath, "config", cmd.ConfigPath, "YAML or JSON configuration file; DUCATI_DNS_* environment variables override its settings and flags override both")
+	flags.BoolVar(&cmd.PrintConfig, "print-config", cmd.PrintConfig, "print the effective configuration with secrets redacted and exit")
+}
+
+// Load merges the defaults, the configuration file, the DUCATI_DNS_*
+// variables in env and the flags in args, each taking precedence over
+// those before it, and validates the result.
+func Load(args, env []string) (Config, Command, error) {
+	var cmd Command
+
+	c := Defaults()
+	if err := overlay(&c, &cmd, args, env, os.Stderr); err != nil {
+		return Config{}, cmd, err
+	}
+
+	if cmd.ConfigPath != "" {
+		var err error
+		if c, err = LoadFile(cmd.ConfigPath); err != nil {
+			return Config{}, cmd, err
+		}
+		if err := overlay(&c, &cmd, args, env, ioutil.Discard); err != nil {
+			return Config{}, cmd, err
+		}
+	}
+
+	return c, cmd, c.Validate()
+}
+
+// overlay applies env and then args to c and cmd.
+func overlay(c *Config, cmd *Command, args, env []string, output io.Writer) error {
+	envFlags := flag.NewFlagSet("ducati-dns", flag.ContinueOnError)
+	cmd.RegisterFlags(envFlags)
+	c.RegisterFlags(envFlags)
+	if err := setFromEnv(envFlags, env); err != nil {
+		return err
+	}
+
+	argFlags := flag.NewFlagSet("ducati-dns", flag.ContinueOnError)
+	argFlags.SetOutput(output)
+	cmd.RegisterFlags(argFlags)
+	c.RegisterFlags(argFlags)
+	return argFlags.Parse(args)
+}
+
+const redacted = "REDACTED"
+
+// Redacted returns a copy of c with its secrets replaced, for printing.
+func (c Config) Redacted() Config {
+	if c.TSIGKey != "" {
+		c.TSIGKey = strings.SplitN(c.TSIGKey, ":", 2)[0] + ":" + redacted
+	}
+	if c.Overlay.UAA.ClientSecret != "" {
+		c.Overlay.UAA.ClientSecret = redacted
+	}
+	if c.AliasAPI.Token != "" {
+		c.AliasAPI.Token = redacted
+	}
+	if c.Admin.Token != "" {
+		c.Admin.Token = redacted
+	}
+	c.Overlay.DucatiAPIs = redactURLs(c.Overlay.DucatiAPIs)
+
+	var clusters []resolver.Cluster
+	for _, cluster := range c.Overlay.Clusters {
+		cluster.DucatiAPIs = redactURLs(cluster.DucatiAPIs)
+		if cluster.UAA.ClientSecret != "" {
+			cluster.UAA.ClientSecret = redacted
+		}
+		clusters = append(clusters, cluster)
+	}
+	c.Overlay.Clusters = clusters
+	return c
+}
+
+// redactURLs replaces the passwords in urls.
+func redactURLs(urls resolver.Endpoints) resolver.Endpoints {
+	var redactedURLs resolver.Endpoints
+	for _, raw := range urls {
+		if u, err := url.Parse(raw); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), redacted)
+				raw = u.String()
+			}
+		}
+		redactedURLs = append(redactedURLs, raw)
+	}
+	return redactedURLs
+}
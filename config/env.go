@@ -0,0 +1,127 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const EnvPrefix = "DUCATI_DNS_"
+
+// envNames lists the environment variable of every flag.  The names are
+// spelled out rather than derived from the flag names so that acronyms such
+// as API and CA stay whole and renaming a flag does not rename its variable.
+var envNames = map[string]string{
+	"config":                    "DUCATI_DNS_CONFIG",
+	"print-config":              "DUCATI_DNS_PRINT_CONFIG",
+	"server":                    "DUCATI_DNS_SERVER",
+	"ducatiSuffix":              "DUCATI_DNS_DUCATI_SUFFIX",
+	"ducatiAPI":                 "DUCATI_DNS_DUCATI_API",
+	"uaaURL":                    "DUCATI_DNS_UAA_URL",
+	"uaaClientID":               "DUCATI_DNS_UAA_CLIENT_ID",
+	"uaaClientSecret":           "DUCATI_DNS_UAA_CLIENT_SECRET",
+	"ducatiAPICACert":           "DUCATI_DNS_DUCATI_API_CA_CERT",
+	"ducatiAPIClientCert":       "DUCATI_DNS_DUCATI_API_CLIENT_CERT",
+	"ducatiAPIClientKey":        "DUCATI_DNS_DUCATI_API_CLIENT_KEY",
+	"ducatiAPIServerName":       "DUCATI_DNS_DUCATI_API_SERVER_NAME",
+	"daemonTimeout":             "DUCATI_DNS_DAEMON_TIMEOUT",
+	"daemonRetries":             "DUCATI_DNS_DAEMON_RETRIES",
+	"daemonRetryBackoff":        "DUCATI_DNS_DAEMON_RETRY_BACKOFF",
+	"daemonBreakerThreshold":    "DUCATI_DNS_DAEMON_BREAKER_THRESHOLD",
+	"daemonBreakerCooldown":     "DUCATI_DNS_DAEMON_BREAKER_COOLDOWN",
+	"daemonMerge":               "DUCATI_DNS_DAEMON_MERGE",
+	"daemonMaxStale":            "DUCATI_DNS_DAEMON_MAX_STALE",
+	"daemonListingMaxAge":       "DUCATI_DNS_DAEMON_LISTING_MAX_AGE",
+	"snapshotPath":              "DUCATI_DNS_SNAPSHOT_PATH",
+	"snapshotInterval":          "DUCATI_DNS_SNAPSHOT_INTERVAL",
+	"snapshotTTL":               "DUCATI_DNS_SNAPSHOT_TTL",
+	"recordsFile":               "DUCATI_DNS_RECORDS_FILE",
+	"recordsFilePollInterval":   "DUCATI_DNS_RECORDS_FILE_POLL_INTERVAL",
+	"ttl":                       "DUCATI_DNS_TTL",
+	"listenAddress":             "DUCATI_DNS_LISTEN_ADDRESS",
+	"localInstancesOnly":        "DUCATI_DNS_LOCAL_INSTANCES_ONLY",
+	"fallthrough":               "DUCATI_DNS_FALLTHROUGH",
+	"healthCheckType":           "DUCATI_DNS_HEALTH_CHECK_TYPE",
+	"healthCheckPort":           "DUCATI_DNS_HEALTH_CHECK_PORT",
+	"healthCheckPath":           "DUCATI_DNS_HEALTH_CHECK_PATH",
+	"healthCheckTimeout":        "DUCATI_DNS_HEALTH_CHECK_TIMEOUT",
+	"healthCheckInterval":       "DUCATI_DNS_HEALTH_CHECK_INTERVAL",
+	"healthCheckWorkers":        "DUCATI_DNS_HEALTH_CHECK_WORKERS",
+	"staticRecords":             "DUCATI_DNS_STATIC_RECORDS",
+	"staticRecordsPollInterval": "DUCATI_DNS_STATIC_RECORDS_POLL_INTERVAL",
+	"zone":                      "DUCATI_DNS_ZONE",
+	"transferAllow":             "DUCATI_DNS_TRANSFER_ALLOW",
+	"transferTSIG":              "DUCATI_DNS_TRANSFER_TSIG",
+	"zoneRefreshInterval":       "DUCATI_DNS_ZONE_REFRESH_INTERVAL",
+	"tsigKey":                   "DUCATI_DNS_TSIG_KEY",
+	"updateDefaultLease":        "DUCATI_DNS_UPDATE_DEFAULT_LEASE",
+	"updateMaxLease":            "DUCATI_DNS_UPDATE_MAX_LEASE",
+	"aliasAPIListenAddress":     "DUCATI_DNS_ALIAS_API_LISTEN_ADDRESS",
+	"aliasAPIToken":             "DUCATI_DNS_ALIAS_API_TOKEN",
+	"aliasPath":                 "DUCATI_DNS_ALIAS_PATH",
+	"dnssecKey":                 "DUCATI_DNS_DNSSEC_KEY",
+	"dnssecSignatureValidity":   "DUCATI_DNS_DNSSEC_SIGNATURE_VALIDITY",
+	"trustAnchor":               "DUCATI_DNS_TRUST_ANCHOR",
+	"metricsListenAddress":      "DUCATI_DNS_METRICS_LISTEN_ADDRESS",
+	"healthListenAddress":       "DUCATI_DNS_HEALTH_LISTEN_ADDRESS",
+	"readinessWindow":           "DUCATI_DNS_READINESS_WINDOW",
+	"adminListenAddress":        "DUCATI_DNS_ADMIN_LISTEN_ADDRESS",
+	"adminToken":                "DUCATI_DNS_ADMIN_TOKEN",
+	"changeLog":                 "DUCATI_DNS_CHANGE_LOG",
+	"auditFile":                 "DUCATI_DNS_AUDIT_FILE",
+	"changeLogHistory":          "DUCATI_DNS_CHANGE_LOG_HISTORY",
+}
+
+// EnvName returns the environment variable for a flag, e.g.
+// DUCATI_DNS_ALIAS_API_TOKEN for aliasAPIToken, or "" for a flag that
+// cannot be set from the environment.
+func EnvName(flagName string) string {
+	return envNames[flagName]
+}
+
+// setFromEnv sets the flags named by the DUCATI_DNS_* variables in env.
+// Repeatable flags take a comma-separated list.
+func setFromEnv(flags *flag.FlagSet, env []string) error {
+	byEnvName := map[string]*flag.Flag{}
+	flags.VisitAll(func(f *flag.Flag) {
+		if name := EnvName(f.Name); name != "" {
+			byEnvName[name] = f
+		}
+	})
+
+	vars := []string{}
+	for _, v := range env {
+		if strings.HasPrefix(v, EnvPrefix) {
+			vars = append(vars, v)
+		}
+	}
+	sort.Strings(vars)
+
+	for _, v := range vars {
+		parts := strings.SplitN(v, "=", 2)
+		name, value := parts[0], ""
+		if len(parts) == 2 {
+			value = parts[1]
+		}
+
+		f, ok := byEnvName[name]
+		if !ok {
+			return fmt.Errorf("unknown environment variable %s", name)
+		}
+
+		values := []string{value}
+		switch f.Value.(type) {
+		case *listFlag, *mapFlag:
+			values = strings.Split(value, ",")
+		}
+
+		for _, value := range values {
+			if err := flags.Set(f.Name, strings.TrimSpace(value)); err != nil {
+				return fmt.Errorf("invalid %s: %s", name, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package config_test
+
+import (
+	"flag"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EnvName", func() {
+	DescribeTable("names the variable of a flag",
+		func(flagName, envName string) {
+			Expect(config.EnvName(flagName)).To(Equal(envName))
+		},
+		Entry("single word", "server", "DUCATI_DNS_SERVER"),
+		Entry("camel case", "healthCheckPort", "DUCATI_DNS_HEALTH_CHECK_PORT"),
+		Entry("trailing acronym", "ducatiAPI", "DUCATI_DNS_DUCATI_API"),
+		Entry("inner acronym", "aliasAPIListenAddress", "DUCATI_DNS_ALIAS_API_LISTEN_ADDRESS"),
+		Entry("adjacent acronyms", "ducatiAPICACert", "DUCATI_DNS_DUCATI_API_CA_CERT"),
+		Entry("dashes", "print-config", "DUCATI_DNS_PRINT_CONFIG"),
+	)
+
+	It("names a distinct variable for every flag", func() {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		c := config.Defaults()
+		c.RegisterFlags(flags)
+		(&config.Command{}).RegisterFlags(flags)
+
+		seen := map[string]string{}
+		flags.VisitAll(func(f *flag.Flag) {
+			name := config.EnvName(f.Name)
+			Expect(name).To(HavePrefix(config.EnvPrefix), "flag %s", f.Name)
+			Expect(seen).NotTo(HaveKey(name), "flag %s", f.Name)
+			seen[name] = f.Name
+		})
+	})
+})
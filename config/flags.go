@@ -0,0 +1,145 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RegisterFlags defines a flag for every setting, defaulting to the value
+// it currently has in c.  A new flag also needs its variable in envNames
+// and the README.
+func (c *Config) RegisterFlags(flags *flag.FlagSet) {
+	flags.Var(&listFlag{values: &c.Upstreams}, "server", "DNS server to forward queries to (may be repeated; later servers are tried when earlier ones fail)")
+	flags.StringVar(&c.Overlay.DucatiSuffix, "ducatiSuffix", c.Overlay.DucatiSuffix, "suffix for lookups on the overlay network")
+	flags.Var(&listFlag{values: (*[]string)(&c.Overlay.DucatiAPIs)}, "ducatiAPI", "URL for the ducati API (may be repeated; requests fail over to later URLs and stay with the first that answers)")
+	flags.StringVar(&c.Overlay.UAA.URL, "uaaURL", c.Overlay.UAA.URL, "URL of the UAA to fetch ducati API tokens from with the client credentials grant (no authentication when empty)")
+	flags.StringVar(&c.Overlay.UAA.ClientID, "uaaClientID", c.Overlay.UAA.ClientID, "UAA client ID")
+	flags.StringVar(&c.Overlay.UAA.ClientSecret, "uaaClientSecret", c.Overlay.UAA.ClientSecret, "UAA client secret")
+	flags.StringVar(&c.Overlay.TLS.CACert, "ducatiAPICACert", c.Overlay.TLS.CACert, "PEM bundle of the authorities that sign the ducati API's certificate (system roots when empty)")
+	flags.StringVar(&c.Overlay.TLS.ClientCert, "ducatiAPIClientCert", c.Overlay.TLS.ClientCert, "PEM client certificate to present to the ducati API; re-read when it changes")
+	flags.StringVar(&c.Overlay.TLS.ClientKey, "ducatiAPIClientKey", c.Overlay.TLS.ClientKey, "PEM key of the client certificate")
+	flags.StringVar(&c.Overlay.TLS.ServerName, "ducatiAPIServerName", c.Overlay.TLS.ServerName, "name to verify the ducati API's certificate against (the URL's host when empty)")
+	flags.DurationVar(&c.Overlay.Daemon.Timeout, "daemonTimeout", c.Overlay.Daemon.Timeout, "timeout for a single request to the ducati API")
+	flags.IntVar(&c.Overlay.Daemon.Retries, "daemonRetries", c.Overlay.Daemon.Retries, "how many times to retry a failed container listing")
+	flags.DurationVar(&c.Overlay.Daemon.RetryBackoff, "daemonRetryBackoff", c.Overlay.Daemon.RetryBackoff, "upper bound of the jittered wait before the first retry, doubled for each further retry")
+	flags.IntVar(&c.Overlay.Daemon.BreakerThreshold, "daemonBreakerThreshold", c.Overlay.Daemon.BreakerThreshold, "consecutive failed listings after which the ducati API is no longer called")
+	flags.DurationVar(&c.Overlay.Daemon.BreakerCooldown, "daemonBreakerCooldown", c.Overlay.Daemon.BreakerCooldown, "how long to wait before trying the ducati API again once the breaker opened")
+	flags.BoolVar(&c.Overlay.Daemon.Merge, "daemonMerge", c.Overlay.Daemon.Merge, "list containers from every ducati API and combine the results instead of failing over")
+	flags.DurationVar(&c.Overlay.Daemon.MaxStale, "daemonMaxStale", c.Overlay.Daemon.MaxStale, "how old the last good container listing may be to answer from, with the snapshot TTL, while the breaker is open (SERVFAIL when older)")
+	flags.StringVar(&c.Overlay.Snapshot.Path, "snapshotPath", c.Overlay.Snapshot.Path, "file to keep the last known container listing in, served while the ducati API fails, also after a restart (disabled when empty)")
+	flags.DurationVar(&c.Overlay.Daemon.ListingMaxAge, "daemonListingMaxAge", c.Overlay.Daemon.ListingMaxAge, "how long one container listing answers every lookup before the ducati API is asked again (0 to only share listings in progress)")
+	flags.DurationVar(&c.Overlay.Snapshot.Interval, "snapshotInterval", c.Overlay.Snapshot.Interval, "how often to write the last container listing to the snapshot file when it changed, and so how far the file may lag after a restart")
+	flags.IntVar(&c.Overlay.Snapshot.TTL, "snapshotTTL", c.Overlay.Snapshot.TTL, "TTL in seconds of answers served from the snapshot")
+	flags.StringVar(&c.Overlay.RecordsFile.Path, "recordsFile", c.Overlay.RecordsFile.Path, "JSON file of overlay instances served in addition to, or without, the ducati API")
+	flags.DurationVar(&c.Overlay.RecordsFile.PollInterval, "recordsFilePollInterval", c.Overlay.RecordsFile.PollInterval, "how often to check the records file for changes")
+	flags.IntVar(&c.Overlay.TTL, "ttl", c.Overlay.TTL, "TTL in seconds of answers for overlay instances")
+	flags.StringVar(&c.ListenAddress, "listenAddress", c.ListenAddress, "Host and port to listen for queries on")
+	flags.BoolVar(&c.Overlay.LocalInstancesOnly, "localInstancesOnly", c.Overlay.LocalInstancesOnly, "only answer with instances on the client's host when any exist")
+	flags.BoolVar(&c.Overlay.Fallthrough, "fallthrough", c.Overlay.Fallthrough, "forward names and query types unknown to the overlay instead of answering NXDOMAIN")
+	flags.StringVar(&c.Overlay.HealthCheck.Type, "healthCheckType", c.Overlay.HealthCheck.Type, "probe overlay instances before answering: tcp or http (disabled when empty)")
+	flags.IntVar(&c.Overlay.HealthCheck.Port, "healthCheckPort", c.Overlay.HealthCheck.Port, "port on each instance's overlay IP to probe")
+	flags.StringVar(&c.Overlay.HealthCheck.Path, "healthCheckPath", c.Overlay.HealthCheck.Path, "path to GET for http health checks")
+	flags.DurationVar(&c.Overlay.HealthCheck.Timeout, "healthCheckTimeout", c.Overlay.HealthCheck.Timeout, "timeout for a single health check")
+	flags.DurationVar(&c.Overlay.HealthCheck.Interval, "healthCheckInterval", c.Overlay.HealthCheck.Interval, "how long health check results are cached")
+	flags.IntVar(&c.Overlay.HealthCheck.Workers, "healthCheckWorkers", c.Overlay.HealthCheck.Workers, "maximum number of concurrent health checks")
+	flags.StringVar(&c.StaticRecords.Path, "staticRecords", c.StaticRecords.Path, "file of static records served ahead of the overlay")
+	flags.DurationVar(&c.StaticRecords.PollInterval, "staticRecordsPollInterval", c.StaticRecords.PollInterval, "how often to check the static records file for changes")
+	flags.Var(&mapFlag{values: &c.Zones}, "zone", "authoritative zone to serve from a master file, as origin=path (may be repeated)")
+	flags.Var(&commaListFlag{values: &c.TransferAllow}, "transferAllow", "comma-separated CIDRs allowed to transfer the overlay zone (transfers disabled when empty)")
+	flags.DurationVar(&c.ZoneRefresh, "zoneRefreshInterval", c.ZoneRefresh, "how often to rebuild the overlay zone served to transfers, the admin API and DNSSEC denials")
+	flags.BoolVar(&c.TransferTSIG, "transferTSIG", c.TransferTSIG, "require zone transfers to be signed with the TSIG key, when one is set")
+	flags.StringVar(&c.TSIGKey, "tsigKey", c.TSIGKey, "TSIG key as name:base64secret; when set, dynamic updates are accepted and, with transferTSIG, zone transfers must be signed with it")
+	flags.DurationVar(&c.Update.DefaultLease, "updateDefaultLease", c.Update.DefaultLease, "lease for dynamically registered records with a TTL of zero")
+	flags.DurationVar(&c.Update.MaxLease, "updateMaxLease", c.Update.MaxLease, "maximum lease for dynamically registered records")
+	flags.StringVar(&c.AliasAPI.ListenAddress, "aliasAPIListenAddress", c.AliasAPI.ListenAddress, "host and port for the alias registration API (disabled when empty)")
+	flags.StringVar(&c.AliasAPI.Token, "aliasAPIToken", c.AliasAPI.Token, "bearer token required by the alias registration API")
+	flags.StringVar(&c.AliasAPI.Path, "aliasPath", c.AliasAPI.Path, "file to keep the registered aliases in across restarts (kept in memory only when empty)")
+	flags.Var(&listFlag{values: &c.DNSSEC.Keys}, "dnssecKey", "sign the overlay zone with the key pair at this path, without the .key/.private extension (may be repeated)")
+	flags.DurationVar(&c.DNSSEC.SignatureValidity, "dnssecSignatureValidity", c.DNSSEC.SignatureValidity, "validity period of generated DNSSEC signatures")
+	flags.StringVar(&c.DNSSEC.TrustAnchor, "trustAnchor", c.DNSSEC.TrustAnchor, "file of DS or DNSKEY records; when set, forwarded answers are DNSSEC validated")
+	flags.StringVar(&c.Metrics.ListenAddress, "metricsListenAddress", c.Metrics.ListenAddress, "host and port to serve Prometheus metrics on at /metrics (disabled when empty)")
+	flags.StringVar(&c.Health.ListenAddress, "healthListenAddress", c.Health.ListenAddress, "host and port to serve /healthz and /readyz on (disabled when empty)")
+	flags.DurationVar(&c.Health.ReadinessWindow, "readinessWindow", c.Health.ReadinessWindow, "how recently the daemon and an upstream must have answered to be considered healthy")
+	flags.StringVar(&c.Admin.ListenAddress, "adminListenAddress", c.Admin.ListenAddress, "host and port for the admin API (disabled when empty)")
+	flags.StringVar(&c.Admin.Token, "adminToken", c.Admin.Token, "bearer token required by the admin API (none when empty)")
+	flags.BoolVar(&c.ChangeLog.Enabled, "changeLog", c.ChangeLog.Enabled, "log the records that were added, removed or changed each time the overlay zone is rebuilt")
+	flags.StringVar(&c.ChangeLog.AuditFile, "auditFile", c.ChangeLog.AuditFile, "file to append record changes to as JSON lines (none when empty)")
+	flags.IntVar(&c.ChangeLog.History, "changeLogHistory", c.ChangeLog.History, "number of recent record changes served by the admin API")
+}
+
+// listFlag collects repeated values.  The first value given on the command
+// line replaces the list rather than adding to one read from a file.
+type listFlag struct {
+	values *[]string
+	set    bool
+}
+
+func (l *listFlag) String() string {
+	if l.values == nil {
+		return ""
+	}
+	return strings.Join(*l.values, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	if !l.set {
+		*l.values = nil
+		l.set = true
+	}
+	*l.values = append(*l.values, value)
+	return nil
+}
+
+type commaListFlag struct {
+	values *[]string
+}
+
+func (l *commaListFlag) String() string {
+	if l.values == nil {
+		return ""
+	}
+	return strings.Join(*l.values, ",")
+}
+
+func (l *commaListFlag) Set(value string) error {
+	values := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	*l.values = values
+	return nil
+}
+
+type mapFlag struct {
+	values *map[string]string
+	set    bool
+}
+
+func (m *mapFlag) String() string {
+	if m.values == nil {
+		return ""
+	}
+	pairs := []string{}
+	for key, value := range *m.values {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m *mapFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected origin=path, got %q", value)
+	}
+	if !m.set || *m.values == nil {
+		*m.values = map[string]string{}
+		m.set = true
+	}
+	(*m.values)[parts[0]] = parts[1]
+	return nil
+}
@@ -0,0 +1,320 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/miekg/dns"
+)
+
+// Errors lists every problem found by Validate.
+type Errors []string
+
+func (e Errors) Error() string {
+	return strings.Join(e, "; ")
+}
+
+type validation struct {
+	errors Errors
+}
+
+// missing and invalid name each setting by its flag, followed by its key in
+// the configuration file.
+func (v *validation) missing(flagName, key string) {
+	v.errors = append(v.errors, fmt.Sprintf("missing required arg: %s (%s)", flagName, key))
+}
+
+func (v *validation) invalid(flagName, key, format string, args ...interface{}) {
+	v.errors = append(v.errors, fmt.Sprintf("invalid %s (%s): %s", flagName, key, fmt.Sprintf(format, args...)))
+}
+
+// missingKey and invalidKey name settings that can only be given in the
+// configuration file.
+func (v *validation) missingKey(key string) {
+	v.errors = append(v.errors, fmt.Sprintf("missing required setting: %s", key))
+}
+
+func (v *validation) invalidKey(key, format string, args ...interface{}) {
+	v.errors = append(v.errors, fmt.Sprintf("invalid %s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (v *validation) address(flagName, key, address string, required bool) {
+	if address == "" {
+		if required {
+			v.missing(flagName, key)
+		}
+		return
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		v.invalid(flagName, key, "%s", err)
+	}
+}
+
+// Validate checks every setting and reports all of the problems at once.
+func (c Config) Validate() error {
+	v := &validation{}
+
+	v.address("listenAddress", "listen_address", c.ListenAddress, true)
+
+	if len(c.Upstreams) == 0 {
+		v.missing("server", "upstreams")
+	}
+	for _, upstream := range c.Upstreams {
+		v.address("server", "upstreams", upstream, true)
+	}
+
+	c.validateOverlay(v)
+
+	if c.StaticRecords.Path != "" && c.StaticRecords.PollInterval <= 0 {
+		v.invalid("staticRecordsPollInterval", "static_records.poll_interval", "must be positive, got %s", c.StaticRecords.PollInterval)
+	}
+
+	origins := []string{}
+	for origin := range c.Zones {
+		origins = append(origins, origin)
+	}
+	sort.Strings(origins)
+	for _, origin := range origins {
+		if origin == "" || c.Zones[origin] == "" {
+			v.invalid("zone", "zones", "expected an origin and a path, got %q: %q", origin, c.Zones[origin])
+		}
+	}
+
+	if _, err := c.TransferNetworks(); err != nil {
+		v.invalid("transferAllow", "transfer_allow", "%s", err)
+	}
+	if c.ZoneRefresh <= 0 {
+		v.invalid("zoneRefreshInterval", "zone_refresh", "must be positive, got %s", c.ZoneRefresh)
+	}
+	if _, err := c.TSIGSecrets(); err != nil {
+		v.invalid("tsigKey", "tsig_key", "%s", err)
+	}
+
+	if c.Update.DefaultLease <= 0 {
+		v.invalid("updateDefaultLease", "update.default_lease", "must be positive, got %s", c.Update.DefaultLease)
+	}
+	if c.Update.MaxLease < c.Update.DefaultLease {
+		v.invalid("updateMaxLease", "update.max_lease", "must be at least the default lease of %s, got %s", c.Update.DefaultLease, c.Update.MaxLease)
+	}
+
+	v.address("aliasAPIListenAddress", "alias_api.listen_address", c.AliasAPI.ListenAddress, false)
+	if c.AliasAPI.ListenAddress != "" && c.AliasAPI.Token == "" {
+		v.missing("aliasAPIToken", "alias_api.token")
+	}
+
+	if len(c.DNSSEC.Keys) > 0 && c.DNSSEC.SignatureValidity <= 0 {
+		v.invalid("dnssecSignatureValidity", "dnssec.signature_validity", "must be positive, got %s", c.DNSSEC.SignatureValidity)
+	}
+
+	v.address("metricsListenAddress", "metrics.listen_address", c.Metrics.ListenAddress, false)
+	v.address("healthListenAddress", "health.listen_address", c.Health.ListenAddress, false)
+	if c.Health.ReadinessWindow <= 0 {
+		v.invalid("readinessWindow", "health.readiness_window", "must be positive, got %s", c.Health.ReadinessWindow)
+	}
+	v.address("adminListenAddress", "admin.listen_address", c.Admin.ListenAddress, false)
+
+	if c.ChangeLog.AuditFile != "" && !c.ChangeLog.Enabled {
+		v.invalid("changeLog", "change_log.enabled", "must be set when an audit file is given")
+	}
+	if c.ChangeLog.History < 1 {
+		v.invalid("changeLogHistory", "change_log.history", "must be at least 1, got %d", c.ChangeLog.History)
+	}
+
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+	return nil
+}
+
+func (c Config) validateOverlay(v *validation) {
+	if c.Overlay.DucatiSuffix == "" {
+		v.missing("ducatiSuffix", "overlay.suffix")
+	}
+
+	if len(c.Overlay.DucatiAPIs) == 0 && c.Overlay.RecordsFile.Path == "" {
+		v.missing("ducatiAPI", "overlay.api")
+	}
+	for _, api := range c.Overlay.DucatiAPIs {
+		if !isHTTPURL(api) {
+			v.invalid("ducatiAPI", "overlay.api", "expected an http or https URL, got %q", api)
+		}
+	}
+
+	if c.Overlay.UAA.URL != "" {
+		if !isHTTPURL(c.Overlay.UAA.URL) {
+			v.invalid("uaaURL", "overlay.uaa.url", "expected an http or https URL, got %q", c.Overlay.UAA.URL)
+		}
+		if c.Overlay.UAA.ClientID == "" {
+			v.missing("uaaClientID", "overlay.uaa.client_id")
+		}
+		if c.Overlay.UAA.ClientSecret == "" {
+			v.missing("uaaClientSecret", "overlay.uaa.client_secret")
+		}
+	}
+
+	tlsConfig := c.Overlay.TLS
+	for _, api := range c.Overlay.DucatiAPIs {
+		if tlsConfig.Enabled() && !strings.HasPrefix(api, "https://") {
+			v.invalid("ducatiAPI", "overlay.api", "TLS settings require an https URL, got %q", api)
+		}
+	}
+	if tlsConfig.ClientCert != "" && tlsConfig.ClientKey == "" {
+		v.missing("ducatiAPIClientKey", "overlay.tls.client_key")
+	}
+	if tlsConfig.ClientKey != "" && tlsConfig.ClientCert == "" {
+		v.missing("ducatiAPIClientCert", "overlay.tls.client_cert")
+	}
+
+	daemon := c.Overlay.Daemon
+	if daemon.Timeout <= 0 {
+		v.invalid("daemonTimeout", "overlay.daemon.timeout", "must be positive, got %s", daemon.Timeout)
+	}
+	if daemon.Retries < 0 {
+		v.invalid("daemonRetries", "overlay.daemon.retries", "must not be negative, got %d", daemon.Retries)
+	}
+	if daemon.RetryBackoff < 0 {
+		v.invalid("daemonRetryBackoff", "overlay.daemon.retry_backoff", "must not be negative, got %s", daemon.RetryBackoff)
+	}
+	if daemon.BreakerThreshold < 1 {
+		v.invalid("daemonBreakerThreshold", "overlay.daemon.breaker_threshold", "must be at least 1, got %d", daemon.BreakerThreshold)
+	}
+	if daemon.BreakerCooldown <= 0 {
+		v.invalid("daemonBreakerCooldown", "overlay.daemon.breaker_cooldown", "must be positive, got %s", daemon.BreakerCooldown)
+	}
+	if daemon.MaxStale < 0 {
+		v.invalid("daemonMaxStale", "overlay.daemon.max_stale", "must not be negative, got %s", daemon.MaxStale)
+	}
+	if daemon.ListingMaxAge < 0 {
+		v.invalid("daemonListingMaxAge", "overlay.daemon.listing_max_age", "must not be negative, got %s", daemon.ListingMaxAge)
+	}
+
+	if c.Overlay.TTL < 0 {
+		v.invalid("ttl", "overlay.ttl", "must not be negative, got %d", c.Overlay.TTL)
+	}
+
+	snapshot := c.Overlay.Snapshot
+	if snapshot.Path != "" && snapshot.Interval <= 0 {
+		v.invalid("snapshotInterval", "overlay.snapshot.interval", "must be positive, got %s", snapshot.Interval)
+	}
+	if snapshot.TTL < 0 {
+		v.invalid("snapshotTTL", "overlay.snapshot.ttl", "must not be negative, got %d", snapshot.TTL)
+	}
+
+	if c.Overlay.RecordsFile.Path != "" && c.Overlay.RecordsFile.PollInterval <= 0 {
+		v.invalid("recordsFilePollInterval", "overlay.records_file.poll_interval", "must be positive, got %s", c.Overlay.RecordsFile.PollInterval)
+	}
+
+	suffixes := map[string]bool{c.Overlay.DucatiSuffix: true}
+	for i, cluster := range c.Overlay.Clusters {
+		validateCluster(v, fmt.Sprintf("overlay.clusters[%d]", i), cluster, c.Overlay.ForCluster(cluster), suffixes)
+	}
+
+	healthCheck := c.Overlay.HealthCheck
+	switch healthCheck.Type {
+	case "":
+		return
+	case "tcp", "http":
+	default:
+		v.invalid("healthCheckType", "overlay.health_check.type", "expected tcp or http, got %q", healthCheck.Type)
+		return
+	}
+
+	if healthCheck.Port == 0 {
+		v.missing("healthCheckPort", "overlay.health_check.port")
+	} else if healthCheck.Port < 0 || healthCheck.Port > 65535 {
+		v.invalid("healthCheckPort", "overlay.health_check.port", "expected 1-65535, got %d", healthCheck.Port)
+	}
+	if healthCheck.Type == "http" && !strings.HasPrefix(healthCheck.Path, "/") {
+		v.invalid("healthCheckPath", "overlay.health_check.path", "must start with /, got %q", healthCheck.Path)
+	}
+	if healthCheck.Timeout <= 0 {
+		v.invalid("healthCheckTimeout", "overlay.health_check.timeout", "must be positive, got %s", healthCheck.Timeout)
+	}
+	if healthCheck.Interval <= 0 {
+		v.invalid("healthCheckInterval", "overlay.health_check.interval", "must be positive, got %s", healthCheck.Interval)
+	}
+	if healthCheck.Workers < 1 {
+		v.invalid("healthCheckWorkers", "overlay.health_check.workers", "must be at least 1, got %d", healthCheck.Workers)
+	}
+}
+
+// validateCluster checks the settings of cluster and, where the cluster
+// inherits them, those of the effective overlay configuration.
+func validateCluster(v *validation, key string, cluster resolver.Cluster, effective resolver.Config, suffixes map[string]bool) {
+	if cluster.Suffix == "" {
+		v.missingKey(key + ".suffix")
+	} else if suffixes[cluster.Suffix] {
+		v.invalidKey(key+".suffix", "%q is already served", cluster.Suffix)
+	}
+	suffixes[cluster.Suffix] = true
+
+	if len(cluster.DucatiAPIs) == 0 {
+		v.missingKey(key + ".api")
+	}
+	for _, api := range cluster.DucatiAPIs {
+		if !isHTTPURL(api) {
+			v.invalidKey(key+".api", "expected an http or https URL, got %q", api)
+		} else if effective.TLS.Enabled() && !strings.HasPrefix(api, "https://") {
+			v.invalidKey(key+".api", "TLS settings require an https URL, got %q", api)
+		}
+	}
+
+	if cluster.TTL < 0 {
+		v.invalidKey(key+".ttl", "must not be negative, got %d", cluster.TTL)
+	}
+
+	if cluster.UAA.URL != "" {
+		if !isHTTPURL(cluster.UAA.URL) {
+			v.invalidKey(key+".uaa.url", "expected an http or https URL, got %q", cluster.UAA.URL)
+		}
+		if cluster.UAA.ClientID == "" {
+			v.missingKey(key + ".uaa.client_id")
+		}
+		if cluster.UAA.ClientSecret == "" {
+			v.missingKey(key + ".uaa.client_secret")
+		}
+	}
+
+	if (cluster.TLS.ClientCert == "") != (cluster.TLS.ClientKey == "") {
+		v.invalidKey(key+".tls", "client_cert and client_key must be given together")
+	}
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func (c Config) TransferNetworks() ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	for _, cidr := range c.TransferAllow {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// TSIGSecrets returns the TSIG key as the map expected by dns.Server, or nil
+// when no key is configured.
+func (c Config) TSIGSecrets() (map[string]string, error) {
+	if c.TSIGKey == "" {
+		return nil, nil
+	}
+	parts := strings.SplitN(c.TSIGKey, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.New("expected name:secret")
+	}
+	if _, err := base64.StdEncoding.DecodeString(parts[1]); err != nil {
+		return nil, fmt.Errorf("secret is not base64: %s", err)
+	}
+	return map[string]string{dns.Fqdn(parts[0]): parts[1]}, nil
+}
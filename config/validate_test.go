@@ -0,0 +1,160 @@
+package config_test
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/config"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/cloudfoundry-incubator/ducati-dns/uaa"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validate", func() {
+	var c config.Config
+
+	BeforeEach(func() {
+		c = config.Defaults()
+		c.Upstreams = []string{"8.8.8.8:53"}
+		c.Overlay.DucatiSuffix = "potato"
+		c.Overlay.DucatiAPIs = resolver.Endpoints{"http://127.0.0.1:4001"}
+	})
+
+	It("accepts a minimal configuration", func() {
+		Expect(c.Validate()).To(Succeed())
+	})
+
+	It("names missing settings by flag and key", func() {
+		c.Upstreams = nil
+		c.Overlay.DucatiAPIs = nil
+
+		err := c.Validate()
+		Expect(err).To(MatchError(ContainSubstring("missing required arg: server (upstreams)")))
+		Expect(err).To(MatchError(ContainSubstring("missing required arg: ducatiAPI (overlay.api)")))
+	})
+
+	It("reports every problem at once", func() {
+		c.Upstreams = []string{"8.8.8.8"}
+		c.Overlay.HealthCheck.Type = "http"
+		c.Overlay.HealthCheck.Path = "healthz"
+		c.TransferAllow = []string{"10.0.0.0/33"}
+		c.Update.MaxLease = time.Minute
+
+		err := c.Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.(config.Errors)).To(ConsistOf(
+			ContainSubstring("invalid server (upstreams)"),
+			"missing required arg: healthCheckPort (overlay.health_check.port)",
+			`invalid healthCheckPath (overlay.health_check.path): must start with /, got "healthz"`,
+			ContainSubstring("invalid transferAllow (transfer_allow)"),
+			"invalid updateMaxLease (update.max_lease): must be at least the default lease of 1h0m0s, got 1m0s",
+		))
+	})
+
+	It("requires an http URL for the ducati API", func() {
+		c.Overlay.DucatiAPIs = resolver.Endpoints{"127.0.0.1:4001"}
+		Expect(c.Validate()).To(MatchError(`invalid ducatiAPI (overlay.api): expected an http or https URL, got "127.0.0.1:4001"`))
+	})
+
+	It("checks every ducati API", func() {
+		c.Overlay.DucatiAPIs = resolver.Endpoints{"http://127.0.0.1:4001", "daemon-2"}
+		Expect(c.Validate()).To(MatchError(`invalid ducatiAPI (overlay.api): expected an http or https URL, got "daemon-2"`))
+	})
+
+	It("requires a token for the alias API", func() {
+		c.AliasAPI.ListenAddress = "127.0.0.1:8053"
+		Expect(c.Validate()).To(MatchError("missing required arg: aliasAPIToken (alias_api.token)"))
+	})
+
+	It("requires a client secret when a UAA is configured", func() {
+		c.Overlay.UAA.URL = "https://uaa.example.com"
+		Expect(c.Validate()).To(MatchError("missing required arg: uaaClientSecret (overlay.uaa.client_secret)"))
+
+		c.Overlay.UAA.ClientSecret = "some-secret"
+		Expect(c.Validate()).To(Succeed())
+	})
+
+	It("requires an https URL and a complete key pair for TLS to the ducati API", func() {
+		c.Overlay.TLS.ClientCert = "/certs/client.pem"
+
+		err := c.Validate()
+		Expect(err).To(MatchError(ContainSubstring(`invalid ducatiAPI (overlay.api): TLS settings require an https URL, got "http://127.0.0.1:4001"`)))
+		Expect(err).To(MatchError(ContainSubstring("missing required arg: ducatiAPIClientKey (overlay.tls.client_key)")))
+
+		c.Overlay.DucatiAPIs = resolver.Endpoints{"https://daemon.service.cf.internal:4001"}
+		c.Overlay.TLS.ClientKey = "/certs/client.key"
+		Expect(c.Validate()).To(Succeed())
+	})
+
+	It("requires a positive refresh interval for snapshots", func() {
+		c.Overlay.Snapshot.Path = "/var/vcap/data/ducati-dns/snapshot.json"
+		c.Overlay.Snapshot.Interval = 0
+		Expect(c.Validate()).To(MatchError("invalid snapshotInterval (overlay.snapshot.interval): must be positive, got 0s"))
+	})
+
+	It("does not require a ducati API when a records file is given", func() {
+		c.Overlay.DucatiAPIs = nil
+		c.Overlay.RecordsFile.Path = "/var/vcap/jobs/ducati-dns/config/records.json"
+		Expect(c.Validate()).To(Succeed())
+
+		c.Overlay.RecordsFile.PollInterval = 0
+		Expect(c.Validate()).To(MatchError("invalid recordsFilePollInterval (overlay.records_file.poll_interval): must be positive, got 0s"))
+	})
+
+	It("requires the change log to run when an audit file is given", func() {
+		c.ChangeLog.AuditFile = "/var/vcap/sys/log/ducati-dns/audit.log"
+		Expect(c.Validate()).To(MatchError("invalid changeLog (change_log.enabled): must be set when an audit file is given"))
+
+		c.ChangeLog.Enabled = true
+		Expect(c.Validate()).To(Succeed())
+	})
+
+	It("validates clusters by their key in the configuration file", func() {
+		c.Overlay.Clusters = []resolver.Cluster{
+			{Suffix: "prod.overlay", DucatiAPIs: resolver.Endpoints{"http://prod:4001"}},
+			{Suffix: "potato", DucatiAPIs: resolver.Endpoints{"staging:4001"}, TTL: -1},
+			{DucatiAPIs: resolver.Endpoints{"http://other:4001"}, UAA: uaa.Config{URL: "https://uaa.example.com", ClientID: "ducati_dns"}},
+		}
+
+		err := c.Validate()
+		Expect(err).To(HaveOccurred())
+		Expect(err.(config.Errors)).To(ConsistOf(
+			`invalid overlay.clusters[1].suffix: "potato" is already served`,
+			`invalid overlay.clusters[1].api: expected an http or https URL, got "staging:4001"`,
+			"invalid overlay.clusters[1].ttl: must not be negative, got -1",
+			"missing required setting: overlay.clusters[2].suffix",
+			"missing required setting: overlay.clusters[2].uaa.client_secret",
+		))
+	})
+
+	It("requires a positive zone refresh interval", func() {
+		c.ZoneRefresh = 0
+		Expect(c.Validate()).To(MatchError("invalid zoneRefreshInterval (zone_refresh): must be positive, got 0s"))
+	})
+
+	It("requires https for clusters that inherit the TLS settings", func() {
+		c.Overlay.DucatiAPIs = resolver.Endpoints{"https://daemon:4001"}
+		c.Overlay.TLS.CACert = "/certs/ca.pem"
+		c.Overlay.Clusters = []resolver.Cluster{
+			{Suffix: "prod.overlay", DucatiAPIs: resolver.Endpoints{"http://prod:4001"}},
+		}
+
+		Expect(c.Validate()).To(MatchError(`invalid overlay.clusters[0].api: TLS settings require an https URL, got "http://prod:4001"`))
+	})
+
+	It("does not echo the TSIG secret", func() {
+		c.TSIGKey = "secret-without-name"
+		err := c.Validate()
+		Expect(err).To(MatchError("invalid tsigKey (tsig_key): expected name:secret"))
+	})
+
+	Describe("TSIGSecrets", func() {
+		It("returns the key keyed by its fully qualified name", func() {
+			c.TSIGKey = "transfer:c2VjcmV0"
+			secrets, err := c.TSIGSecrets()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secrets).To(Equal(map[string]string{"transfer.": "c2VjcmV0"}))
+		})
+	})
+})
@@ -0,0 +1,165 @@
+// This file was generated by counterfeiter
+package fakes
+
+import "sync"
+
+type AliasStore struct {
+	SetStub        func(alias, target string) (bool, error)
+	setMutex       sync.RWMutex
+	setArgsForCall []struct {
+		alias  string
+		target string
+	}
+	setReturns struct {
+		result1 bool
+		result2 error
+	}
+	DeleteStub        func(alias string) (bool, error)
+	deleteMutex       sync.RWMutex
+	deleteArgsForCall []struct {
+		alias string
+	}
+	deleteReturns struct {
+		result1 bool
+		result2 error
+	}
+	GetStub        func(alias string) (string, bool)
+	getMutex       sync.RWMutex
+	getArgsForCall []struct {
+		alias string
+	}
+	getReturns struct {
+		result1 string
+		result2 bool
+	}
+	ListStub        func() map[string]string
+	listMutex       sync.RWMutex
+	listArgsForCall []struct{}
+	listReturns     struct {
+		result1 map[string]string
+	}
+}
+
+func (fake *AliasStore) Set(alias string, target string) (bool, error) {
+	fake.setMutex.Lock()
+	fake.setArgsForCall = append(fake.setArgsForCall, struct {
+		alias  string
+		target string
+	}{alias, target})
+	fake.setMutex.Unlock()
+	if fake.SetStub != nil {
+		return fake.SetStub(alias, target)
+	} else {
+		return fake.setReturns.result1, fake.setReturns.result2
+	}
+}
+
+func (fake *AliasStore) SetCallCount() int {
+	fake.setMutex.RLock()
+	defer fake.setMutex.RUnlock()
+	return len(fake.setArgsForCall)
+}
+
+func (fake *AliasStore) SetArgsForCall(i int) (string, string) {
+	fake.setMutex.RLock()
+	defer fake.setMutex.RUnlock()
+	return fake.setArgsForCall[i].alias, fake.setArgsForCall[i].target
+}
+
+func (fake *AliasStore) SetReturns(result1 bool, result2 error) {
+	fake.SetStub = nil
+	fake.setReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *AliasStore) Delete(alias string) (bool, error) {
+	fake.deleteMutex.Lock()
+	fake.deleteArgsForCall = append(fake.deleteArgsForCall, struct {
+		alias string
+	}{alias})
+	fake.deleteMutex.Unlock()
+	if fake.DeleteStub != nil {
+		return fake.DeleteStub(alias)
+	} else {
+		return fake.deleteReturns.result1, fake.deleteReturns.result2
+	}
+}
+
+func (fake *AliasStore) DeleteCallCount() int {
+	fake.deleteMutex.RLock()
+	defer fake.deleteMutex.RUnlock()
+	return len(fake.deleteArgsForCall)
+}
+
+func (fake *AliasStore) DeleteArgsForCall(i int) string {
+	fake.deleteMutex.RLock()
+	defer fake.deleteMutex.RUnlock()
+	return fake.deleteArgsForCall[i].alias
+}
+
+func (fake *AliasStore) DeleteReturns(result1 bool, result2 error) {
+	fake.DeleteStub = nil
+	fake.deleteReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *AliasStore) Get(alias string) (string, bool) {
+	fake.getMutex.Lock()
+	fake.getArgsForCall = append(fake.getArgsForCall, struct {
+		alias string
+	}{alias})
+	fake.getMutex.Unlock()
+	if fake.GetStub != nil {
+		return fake.GetStub(alias)
+	} else {
+		return fake.getReturns.result1, fake.getReturns.result2
+	}
+}
+
+func (fake *AliasStore) GetCallCount() int {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	return len(fake.getArgsForCall)
+}
+
+func (fake *AliasStore) GetArgsForCall(i int) string {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	return fake.getArgsForCall[i].alias
+}
+
+func (fake *AliasStore) GetReturns(result1 string, result2 bool) {
+	fake.GetStub = nil
+	fake.getReturns = struct {
+		result1 string
+		result2 bool
+	}{result1, result2}
+}
+
+func (fake *AliasStore) List() map[string]string {
+	fake.listMutex.Lock()
+	fake.listArgsForCall = append(fake.listArgsForCall, struct{}{})
+	fake.listMutex.Unlock()
+	if fake.ListStub != nil {
+		return fake.ListStub()
+	} else {
+		return fake.listReturns.result1
+	}
+}
+
+func (fake *AliasStore) ListCallCount() int {
+	fake.listMutex.RLock()
+	defer fake.listMutex.RUnlock()
+	return len(fake.listArgsForCall)
+}
+
+func (fake *AliasStore) ListReturns(result1 map[string]string) {
+	fake.ListStub = nil
+	fake.listReturns = struct {
+		result1 map[string]string
+	}{result1}
+}
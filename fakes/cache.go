@@ -0,0 +1,84 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/api"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+)
+
+type Cache struct {
+	EntriesStub        func() []resolver.CacheEntry
+	entriesMutex       sync.RWMutex
+	entriesArgsForCall []struct{}
+	entriesReturns     struct {
+		result1 []resolver.CacheEntry
+	}
+	FlushStub        func(name string) int
+	flushMutex       sync.RWMutex
+	flushArgsForCall []struct {
+		name string
+	}
+	flushReturns struct {
+		result1 int
+	}
+}
+
+func (fake *Cache) Entries() []resolver.CacheEntry {
+	fake.entriesMutex.Lock()
+	fake.entriesArgsForCall = append(fake.entriesArgsForCall, struct{}{})
+	fake.entriesMutex.Unlock()
+	if fake.EntriesStub != nil {
+		return fake.EntriesStub()
+	} else {
+		return fake.entriesReturns.result1
+	}
+}
+
+func (fake *Cache) EntriesCallCount() int {
+	fake.entriesMutex.RLock()
+	defer fake.entriesMutex.RUnlock()
+	return len(fake.entriesArgsForCall)
+}
+
+func (fake *Cache) EntriesReturns(result1 []resolver.CacheEntry) {
+	fake.EntriesStub = nil
+	fake.entriesReturns = struct {
+		result1 []resolver.CacheEntry
+	}{result1}
+}
+
+func (fake *Cache) Flush(name string) int {
+	fake.flushMutex.Lock()
+	fake.flushArgsForCall = append(fake.flushArgsForCall, struct {
+		name string
+	}{name})
+	fake.flushMutex.Unlock()
+	if fake.FlushStub != nil {
+		return fake.FlushStub(name)
+	} else {
+		return fake.flushReturns.result1
+	}
+}
+
+func (fake *Cache) FlushCallCount() int {
+	fake.flushMutex.RLock()
+	defer fake.flushMutex.RUnlock()
+	return len(fake.flushArgsForCall)
+}
+
+func (fake *Cache) FlushArgsForCall(i int) string {
+	fake.flushMutex.RLock()
+	defer fake.flushMutex.RUnlock()
+	return fake.flushArgsForCall[i].name
+}
+
+func (fake *Cache) FlushReturns(result1 int) {
+	fake.FlushStub = nil
+	fake.flushReturns = struct {
+		result1 int
+	}{result1}
+}
+
+var _ api.Cache = new(Cache)
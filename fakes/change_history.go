@@ -0,0 +1,41 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+)
+
+type ChangeHistory struct {
+	ChangesStub        func() []resolver.RecordChange
+	changesMutex       sync.RWMutex
+	changesArgsForCall []struct{}
+	changesReturns     struct {
+		result1 []resolver.RecordChange
+	}
+}
+
+func (fake *ChangeHistory) Changes() []resolver.RecordChange {
+	fake.changesMutex.Lock()
+	fake.changesArgsForCall = append(fake.changesArgsForCall, struct{}{})
+	fake.changesMutex.Unlock()
+	if fake.ChangesStub != nil {
+		return fake.ChangesStub()
+	} else {
+		return fake.changesReturns.result1
+	}
+}
+
+func (fake *ChangeHistory) ChangesCallCount() int {
+	fake.changesMutex.RLock()
+	defer fake.changesMutex.RUnlock()
+	return len(fake.changesArgsForCall)
+}
+
+func (fake *ChangeHistory) ChangesReturns(result1 []resolver.RecordChange) {
+	fake.ChangesStub = nil
+	fake.changesReturns = struct {
+		result1 []resolver.RecordChange
+	}{result1}
+}
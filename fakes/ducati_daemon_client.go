@@ -15,6 +15,35 @@ type DucatiDaemonClient struct {
 		result1 []models.Container
 		result2 error
 	}
+	ListAppContainersStub        func(appGUID string) ([]models.Container, error)
+	listAppContainersMutex       sync.RWMutex
+	listAppContainersArgsForCall []struct {
+		appGUID string
+	}
+	listAppContainersReturns struct {
+		result1 []models.Container
+		result2 error
+	}
+	GetContainerStub        func(id string) (models.Container, bool, error)
+	getContainerMutex       sync.RWMutex
+	getContainerArgsForCall []struct {
+		id string
+	}
+	getContainerReturns struct {
+		result1 models.Container
+		result2 bool
+		result3 error
+	}
+	GetContainerByIPStub        func(ip string) (models.Container, bool, error)
+	getContainerByIPMutex       sync.RWMutex
+	getContainerByIPArgsForCall []struct {
+		ip string
+	}
+	getContainerByIPReturns struct {
+		result1 models.Container
+		result2 bool
+		result3 error
+	}
 }
 
 func (fake *DucatiDaemonClient) ListContainers() ([]models.Container, error) {
@@ -41,3 +70,104 @@ func (fake *DucatiDaemonClient) ListContainersReturns(result1 []models.Container
 		result2 error
 	}{result1, result2}
 }
+
+func (fake *DucatiDaemonClient) ListAppContainers(appGUID string) ([]models.Container, error) {
+	fake.listAppContainersMutex.Lock()
+	fake.listAppContainersArgsForCall = append(fake.listAppContainersArgsForCall, struct {
+		appGUID string
+	}{appGUID})
+	fake.listAppContainersMutex.Unlock()
+	if fake.ListAppContainersStub != nil {
+		return fake.ListAppContainersStub(appGUID)
+	} else {
+		return fake.listAppContainersReturns.result1, fake.listAppContainersReturns.result2
+	}
+}
+
+func (fake *DucatiDaemonClient) ListAppContainersCallCount() int {
+	fake.listAppContainersMutex.RLock()
+	defer fake.listAppContainersMutex.RUnlock()
+	return len(fake.listAppContainersArgsForCall)
+}
+
+func (fake *DucatiDaemonClient) ListAppContainersArgsForCall(i int) string {
+	fake.listAppContainersMutex.RLock()
+	defer fake.listAppContainersMutex.RUnlock()
+	return fake.listAppContainersArgsForCall[i].appGUID
+}
+
+func (fake *DucatiDaemonClient) ListAppContainersReturns(result1 []models.Container, result2 error) {
+	fake.ListAppContainersStub = nil
+	fake.listAppContainersReturns = struct {
+		result1 []models.Container
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *DucatiDaemonClient) GetContainer(id string) (models.Container, bool, error) {
+	fake.getContainerMutex.Lock()
+	fake.getContainerArgsForCall = append(fake.getContainerArgsForCall, struct {
+		id string
+	}{id})
+	fake.getContainerMutex.Unlock()
+	if fake.GetContainerStub != nil {
+		return fake.GetContainerStub(id)
+	} else {
+		return fake.getContainerReturns.result1, fake.getContainerReturns.result2, fake.getContainerReturns.result3
+	}
+}
+
+func (fake *DucatiDaemonClient) GetContainerCallCount() int {
+	fake.getContainerMutex.RLock()
+	defer fake.getContainerMutex.RUnlock()
+	return len(fake.getContainerArgsForCall)
+}
+
+func (fake *DucatiDaemonClient) GetContainerArgsForCall(i int) string {
+	fake.getContainerMutex.RLock()
+	defer fake.getContainerMutex.RUnlock()
+	return fake.getContainerArgsForCall[i].id
+}
+
+func (fake *DucatiDaemonClient) GetContainerReturns(result1 models.Container, result2 bool, result3 error) {
+	fake.GetContainerStub = nil
+	fake.getContainerReturns = struct {
+		result1 models.Container
+		result2 bool
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *DucatiDaemonClient) GetContainerByIP(ip string) (models.Container, bool, error) {
+	fake.getContainerByIPMutex.Lock()
+	fake.getContainerByIPArgsForCall = append(fake.getContainerByIPArgsForCall, struct {
+		ip string
+	}{ip})
+	fake.getContainerByIPMutex.Unlock()
+	if fake.GetContainerByIPStub != nil {
+		return fake.GetContainerByIPStub(ip)
+	} else {
+		return fake.getContainerByIPReturns.result1, fake.getContainerByIPReturns.result2, fake.getContainerByIPReturns.result3
+	}
+}
+
+func (fake *DucatiDaemonClient) GetContainerByIPCallCount() int {
+	fake.getContainerByIPMutex.RLock()
+	defer fake.getContainerByIPMutex.RUnlock()
+	return len(fake.getContainerByIPArgsForCall)
+}
+
+func (fake *DucatiDaemonClient) GetContainerByIPArgsForCall(i int) string {
+	fake.getContainerByIPMutex.RLock()
+	defer fake.getContainerByIPMutex.RUnlock()
+	return fake.getContainerByIPArgsForCall[i].ip
+}
+
+func (fake *DucatiDaemonClient) GetContainerByIPReturns(result1 models.Container, result2 bool, result3 error) {
+	fake.GetContainerByIPStub = nil
+	fake.getContainerByIPReturns = struct {
+		result1 models.Container
+		result2 bool
+		result3 error
+	}{result1, result2, result3}
+}
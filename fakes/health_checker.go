@@ -0,0 +1,51 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+)
+
+type HealthChecker struct {
+	HealthyStub        func(instances []resolver.Instance) []resolver.Instance
+	healthyMutex       sync.RWMutex
+	healthyArgsForCall []struct {
+		instances []resolver.Instance
+	}
+	healthyReturns struct {
+		result1 []resolver.Instance
+	}
+}
+
+func (fake *HealthChecker) Healthy(instances []resolver.Instance) []resolver.Instance {
+	fake.healthyMutex.Lock()
+	fake.healthyArgsForCall = append(fake.healthyArgsForCall, struct {
+		instances []resolver.Instance
+	}{instances})
+	fake.healthyMutex.Unlock()
+	if fake.HealthyStub != nil {
+		return fake.HealthyStub(instances)
+	} else {
+		return fake.healthyReturns.result1
+	}
+}
+
+func (fake *HealthChecker) HealthyCallCount() int {
+	fake.healthyMutex.RLock()
+	defer fake.healthyMutex.RUnlock()
+	return len(fake.healthyArgsForCall)
+}
+
+func (fake *HealthChecker) HealthyArgsForCall(i int) []resolver.Instance {
+	fake.healthyMutex.RLock()
+	defer fake.healthyMutex.RUnlock()
+	return fake.healthyArgsForCall[i].instances
+}
+
+func (fake *HealthChecker) HealthyReturns(result1 []resolver.Instance) {
+	fake.HealthyStub = nil
+	fake.healthyReturns = struct {
+		result1 []resolver.Instance
+	}{result1}
+}
@@ -0,0 +1,43 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/runner"
+)
+
+type Loader struct {
+	LoadStub        func() error
+	loadMutex       sync.RWMutex
+	loadArgsForCall []struct{}
+	loadReturns     struct {
+		result1 error
+	}
+}
+
+func (fake *Loader) Load() error {
+	fake.loadMutex.Lock()
+	fake.loadArgsForCall = append(fake.loadArgsForCall, struct{}{})
+	fake.loadMutex.Unlock()
+	if fake.LoadStub != nil {
+		return fake.LoadStub()
+	} else {
+		return fake.loadReturns.result1
+	}
+}
+
+func (fake *Loader) LoadCallCount() int {
+	fake.loadMutex.RLock()
+	defer fake.loadMutex.RUnlock()
+	return len(fake.loadArgsForCall)
+}
+
+func (fake *Loader) LoadReturns(result1 error) {
+	fake.LoadStub = nil
+	fake.loadReturns = struct {
+		result1 error
+	}{result1}
+}
+
+var _ runner.Loader = new(Loader)
@@ -0,0 +1,69 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/pivotal-golang/lager"
+)
+
+type LogLevel struct {
+	GetMinLevelStub        func() lager.LogLevel
+	getMinLevelMutex       sync.RWMutex
+	getMinLevelArgsForCall []struct{}
+	getMinLevelReturns     struct {
+		result1 lager.LogLevel
+	}
+	SetMinLevelStub        func(lager.LogLevel)
+	setMinLevelMutex       sync.RWMutex
+	setMinLevelArgsForCall []struct {
+		arg1 lager.LogLevel
+	}
+}
+
+func (fake *LogLevel) GetMinLevel() lager.LogLevel {
+	fake.getMinLevelMutex.Lock()
+	fake.getMinLevelArgsForCall = append(fake.getMinLevelArgsForCall, struct{}{})
+	fake.getMinLevelMutex.Unlock()
+	if fake.GetMinLevelStub != nil {
+		return fake.GetMinLevelStub()
+	} else {
+		return fake.getMinLevelReturns.result1
+	}
+}
+
+func (fake *LogLevel) GetMinLevelCallCount() int {
+	fake.getMinLevelMutex.RLock()
+	defer fake.getMinLevelMutex.RUnlock()
+	return len(fake.getMinLevelArgsForCall)
+}
+
+func (fake *LogLevel) GetMinLevelReturns(result1 lager.LogLevel) {
+	fake.GetMinLevelStub = nil
+	fake.getMinLevelReturns = struct {
+		result1 lager.LogLevel
+	}{result1}
+}
+
+func (fake *LogLevel) SetMinLevel(arg1 lager.LogLevel) {
+	fake.setMinLevelMutex.Lock()
+	fake.setMinLevelArgsForCall = append(fake.setMinLevelArgsForCall, struct {
+		arg1 lager.LogLevel
+	}{arg1})
+	fake.setMinLevelMutex.Unlock()
+	if fake.SetMinLevelStub != nil {
+		fake.SetMinLevelStub(arg1)
+	}
+}
+
+func (fake *LogLevel) SetMinLevelCallCount() int {
+	fake.setMinLevelMutex.RLock()
+	defer fake.setMinLevelMutex.RUnlock()
+	return len(fake.setMinLevelArgsForCall)
+}
+
+func (fake *LogLevel) SetMinLevelArgsForCall(i int) lager.LogLevel {
+	fake.setMinLevelMutex.RLock()
+	defer fake.setMinLevelMutex.RUnlock()
+	return fake.setMinLevelArgsForCall[i].arg1
+}
@@ -0,0 +1,47 @@
+// This file was generated by counterfeiter
+package fakes
+
+import "sync"
+
+type Prober struct {
+	ProbeStub        func(address string) error
+	probeMutex       sync.RWMutex
+	probeArgsForCall []struct {
+		address string
+	}
+	probeReturns struct {
+		result1 error
+	}
+}
+
+func (fake *Prober) Probe(address string) error {
+	fake.probeMutex.Lock()
+	fake.probeArgsForCall = append(fake.probeArgsForCall, struct {
+		address string
+	}{address})
+	fake.probeMutex.Unlock()
+	if fake.ProbeStub != nil {
+		return fake.ProbeStub(address)
+	} else {
+		return fake.probeReturns.result1
+	}
+}
+
+func (fake *Prober) ProbeCallCount() int {
+	fake.probeMutex.RLock()
+	defer fake.probeMutex.RUnlock()
+	return len(fake.probeArgsForCall)
+}
+
+func (fake *Prober) ProbeArgsForCall(i int) string {
+	fake.probeMutex.RLock()
+	defer fake.probeMutex.RUnlock()
+	return fake.probeArgsForCall[i].address
+}
+
+func (fake *Prober) ProbeReturns(result1 error) {
+	fake.ProbeStub = nil
+	fake.probeReturns = struct {
+		result1 error
+	}{result1}
+}
@@ -0,0 +1,43 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+type RecordLister struct {
+	RecordsStub        func() ([]dns.RR, error)
+	recordsMutex       sync.RWMutex
+	recordsArgsForCall []struct{}
+	recordsReturns     struct {
+		result1 []dns.RR
+		result2 error
+	}
+}
+
+func (fake *RecordLister) Records() ([]dns.RR, error) {
+	fake.recordsMutex.Lock()
+	fake.recordsArgsForCall = append(fake.recordsArgsForCall, struct{}{})
+	fake.recordsMutex.Unlock()
+	if fake.RecordsStub != nil {
+		return fake.RecordsStub()
+	} else {
+		return fake.recordsReturns.result1, fake.recordsReturns.result2
+	}
+}
+
+func (fake *RecordLister) RecordsCallCount() int {
+	fake.recordsMutex.RLock()
+	defer fake.recordsMutex.RUnlock()
+	return len(fake.recordsArgsForCall)
+}
+
+func (fake *RecordLister) RecordsReturns(result1 []dns.RR, result2 error) {
+	fake.RecordsStub = nil
+	fake.recordsReturns = struct {
+		result1 []dns.RR
+		result2 error
+	}{result1, result2}
+}
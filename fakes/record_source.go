@@ -0,0 +1,133 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+)
+
+type RecordSource struct {
+	InstancesStub        func(app string) ([]resolver.Instance, bool, error)
+	instancesMutex       sync.RWMutex
+	instancesArgsForCall []struct {
+		app string
+	}
+	instancesReturns struct {
+		result1 []resolver.Instance
+		result2 bool
+		result3 error
+	}
+	InstanceByIPStub        func(ip string) (resolver.Instance, bool, error)
+	instanceByIPMutex       sync.RWMutex
+	instanceByIPArgsForCall []struct {
+		ip string
+	}
+	instanceByIPReturns struct {
+		result1 resolver.Instance
+		result2 bool
+		result3 error
+	}
+	AllInstancesStub        func() ([]resolver.Instance, error)
+	allInstancesMutex       sync.RWMutex
+	allInstancesArgsForCall []struct{}
+	allInstancesReturns     struct {
+		result1 []resolver.Instance
+		result2 error
+	}
+}
+
+func (fake *RecordSource) Instances(app string) ([]resolver.Instance, bool, error) {
+	fake.instancesMutex.Lock()
+	fake.instancesArgsForCall = append(fake.instancesArgsForCall, struct {
+		app string
+	}{app})
+	fake.instancesMutex.Unlock()
+	if fake.InstancesStub != nil {
+		return fake.InstancesStub(app)
+	} else {
+		return fake.instancesReturns.result1, fake.instancesReturns.result2, fake.instancesReturns.result3
+	}
+}
+
+func (fake *RecordSource) InstancesCallCount() int {
+	fake.instancesMutex.RLock()
+	defer fake.instancesMutex.RUnlock()
+	return len(fake.instancesArgsForCall)
+}
+
+func (fake *RecordSource) InstancesArgsForCall(i int) string {
+	fake.instancesMutex.RLock()
+	defer fake.instancesMutex.RUnlock()
+	return fake.instancesArgsForCall[i].app
+}
+
+func (fake *RecordSource) InstancesReturns(result1 []resolver.Instance, result2 bool, result3 error) {
+	fake.InstancesStub = nil
+	fake.instancesReturns = struct {
+		result1 []resolver.Instance
+		result2 bool
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *RecordSource) InstanceByIP(ip string) (resolver.Instance, bool, error) {
+	fake.instanceByIPMutex.Lock()
+	fake.instanceByIPArgsForCall = append(fake.instanceByIPArgsForCall, struct {
+		ip string
+	}{ip})
+	fake.instanceByIPMutex.Unlock()
+	if fake.InstanceByIPStub != nil {
+		return fake.InstanceByIPStub(ip)
+	} else {
+		return fake.instanceByIPReturns.result1, fake.instanceByIPReturns.result2, fake.instanceByIPReturns.result3
+	}
+}
+
+func (fake *RecordSource) InstanceByIPCallCount() int {
+	fake.instanceByIPMutex.RLock()
+	defer fake.instanceByIPMutex.RUnlock()
+	return len(fake.instanceByIPArgsForCall)
+}
+
+func (fake *RecordSource) InstanceByIPArgsForCall(i int) string {
+	fake.instanceByIPMutex.RLock()
+	defer fake.instanceByIPMutex.RUnlock()
+	return fake.instanceByIPArgsForCall[i].ip
+}
+
+func (fake *RecordSource) InstanceByIPReturns(result1 resolver.Instance, result2 bool, result3 error) {
+	fake.InstanceByIPStub = nil
+	fake.instanceByIPReturns = struct {
+		result1 resolver.Instance
+		result2 bool
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *RecordSource) AllInstances() ([]resolver.Instance, error) {
+	fake.allInstancesMutex.Lock()
+	fake.allInstancesArgsForCall = append(fake.allInstancesArgsForCall, struct{}{})
+	fake.allInstancesMutex.Unlock()
+	if fake.AllInstancesStub != nil {
+		return fake.AllInstancesStub()
+	} else {
+		return fake.allInstancesReturns.result1, fake.allInstancesReturns.result2
+	}
+}
+
+func (fake *RecordSource) AllInstancesCallCount() int {
+	fake.allInstancesMutex.RLock()
+	defer fake.allInstancesMutex.RUnlock()
+	return len(fake.allInstancesArgsForCall)
+}
+
+func (fake *RecordSource) AllInstancesReturns(result1 []resolver.Instance, result2 error) {
+	fake.AllInstancesStub = nil
+	fake.allInstancesReturns = struct {
+		result1 []resolver.Instance
+		result2 error
+	}{result1, result2}
+}
+
+var _ resolver.RecordSource = new(RecordSource)
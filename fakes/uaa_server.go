@@ -0,0 +1,105 @@
+package fakes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// UAAServer is a UAA token endpoint for tests.  It grants tokens to one
+// client with the client credentials grant and can check that requests to
+// other test servers carry one of them.
+type UAAServer struct {
+	*httptest.Server
+
+	ClientID     string
+	ClientSecret string
+
+	mutex         sync.Mutex
+	expiresIn     int
+	tokenRequests int
+	valid         map[string]bool
+}
+
+func NewUAAServer(clientID, clientSecret string) *UAAServer {
+	u := &UAAServer{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		expiresIn:    3600,
+		valid:        map[string]bool{},
+	}
+	u.Server = httptest.NewServer(http.HandlerFunc(u.serveHTTP))
+	return u
+}
+
+func (u *UAAServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/oauth/token" || r.Method != "POST" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.tokenRequests++
+
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != u.ClientID || secret != u.ClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"unauthorized","error_description":"Bad credentials"}`))
+		return
+	}
+	if r.PostFormValue("grant_type") != "client_credentials" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"unsupported_grant_type"}`))
+		return
+	}
+
+	token := fmt.Sprintf("token-%d", u.tokenRequests)
+	u.valid[token] = true
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": token,
+		"token_type":   "bearer",
+		"expires_in":   u.expiresIn,
+		"scope":        "",
+		"jti":          token,
+	})
+}
+
+// SetExpiresIn sets the lifetime in seconds of the tokens granted from now
+// on.
+func (u *UAAServer) SetExpiresIn(seconds int) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.expiresIn = seconds
+}
+
+func (u *UAAServer) TokenRequests() int {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.tokenRequests
+}
+
+// RevokeAll makes every token granted so far invalid.
+func (u *UAAServer) RevokeAll() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.valid = map[string]bool{}
+}
+
+// Authorized reports whether r carries a bearer token granted by the server
+// and not revoked since.
+func (u *UAAServer) Authorized(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.valid[strings.TrimPrefix(header, "Bearer ")]
+}
@@ -0,0 +1,41 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+)
+
+type UpstreamStatus struct {
+	StatusStub        func() []resolver.UpstreamStatus
+	statusMutex       sync.RWMutex
+	statusArgsForCall []struct{}
+	statusReturns     struct {
+		result1 []resolver.UpstreamStatus
+	}
+}
+
+func (fake *UpstreamStatus) Status() []resolver.UpstreamStatus {
+	fake.statusMutex.Lock()
+	fake.statusArgsForCall = append(fake.statusArgsForCall, struct{}{})
+	fake.statusMutex.Unlock()
+	if fake.StatusStub != nil {
+		return fake.StatusStub()
+	} else {
+		return fake.statusReturns.result1
+	}
+}
+
+func (fake *UpstreamStatus) StatusCallCount() int {
+	fake.statusMutex.RLock()
+	defer fake.statusMutex.RUnlock()
+	return len(fake.statusArgsForCall)
+}
+
+func (fake *UpstreamStatus) StatusReturns(result1 []resolver.UpstreamStatus) {
+	fake.StatusStub = nil
+	fake.statusReturns = struct {
+		result1 []resolver.UpstreamStatus
+	}{result1}
+}
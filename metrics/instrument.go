@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/miekg/dns"
+)
+
+// Handler records the count, latency and response code of the queries
+// answered by Next under the given handler name.  Queries Next marks as
+// forwarded, such as an overlay's fallthrough, are recorded under
+// "fallthrough" instead, so that each query is counted once.
+type Handler struct {
+	Name    string
+	Metrics *Metrics
+	Next    dns.Handler
+}
+
+func (h *Handler) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
+	h.Metrics.queryStarted()
+	start := time.Now()
+
+	recorder := &rcodeRecorder{ResponseWriter: w, rcode: "NONE"}
+	h.Next.ServeDNS(recorder, request)
+
+	name := h.Name
+	if recorder.forwarded {
+		name = "fallthrough"
+	}
+	h.Metrics.queryFinished(name, request.Question[0].Qtype, recorder.rcode, time.Since(start))
+}
+
+type rcodeRecorder struct {
+	dns.ResponseWriter
+	rcode     string
+	forwarded bool
+}
+
+func (r *rcodeRecorder) MarkForwarded() {
+	r.forwarded = true
+}
+
+func (r *rcodeRecorder) WriteMsg(m *dns.Msg) error {
+	r.rcode = rcodeName(m.Rcode)
+	return r.ResponseWriter.WriteMsg(m)
+}
+
+type daemonClient interface {
+	ListContainers() ([]models.Container, error)
+	ListAppContainers(appGUID string) ([]models.Container, error)
+	GetContainer(id string) (models.Container, bool, error)
+	GetContainerByIP(ip string) (models.Container, bool, error)
+}
+
+// DaemonClient records the latency and errors of calls to one ducati
+// daemon API.  It wraps each endpoint, so retries and failovers count as
+// separate requests.
+type DaemonClient struct {
+	Metrics *Metrics
+	Client  daemonClient
+}
+
+func (d *DaemonClient) ListContainers() ([]models.Container, error) {
+	start := time.Now()
+	containers, err := d.Client.ListContainers()
+	d.Metrics.daemonRequest(time.Since(start), err)
+	return containers, err
+}
+
+func (d *DaemonClient) ListAppContainers(appGUID string) ([]models.Container, error) {
+	start := time.Now()
+	containers, err := d.Client.ListAppContainers(appGUID)
+	d.Metrics.daemonRequest(time.Since(start), err)
+	return containers, err
+}
+
+func (d *DaemonClient) GetContainer(id string) (models.Container, bool, error) {
+	start := time.Now()
+	container, found, err := d.Client.GetContainer(id)
+	d.Metrics.daemonRequest(time.Since(start), err)
+	return container, found, err
+}
+
+func (d *DaemonClient) GetContainerByIP(ip string) (models.Container, bool, error) {
+	start := time.Now()
+	container, found, err := d.Client.GetContainerByIP(ip)
+	d.Metrics.daemonRequest(time.Since(start), err)
+	return container, found, err
+}
+
+type exchanger interface {
+	Exchange(m *dns.Msg, a string) (r *dns.Msg, rtt time.Duration, err error)
+}
+
+// Exchanger records the round trip time reported by each upstream exchange.
+type Exchanger struct {
+	Metrics   *Metrics
+	Exchanger exchanger
+}
+
+func (e *Exchanger) Exchange(m *dns.Msg, a string) (*dns.Msg, time.Duration, error) {
+	resp, rtt, err := e.Exchanger.Exchange(m, a)
+	e.Metrics.upstreamExchange(rtt, err)
+	return resp, rtt, err
+}
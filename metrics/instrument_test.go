@@ -0,0 +1,179 @@
+package metrics_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/metrics"
+	"github.com/miekg/dns"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Instrumentation", func() {
+	var m *metrics.Metrics
+
+	BeforeEach(func() {
+		m = metrics.New()
+	})
+
+	Describe("Handler", func() {
+		var (
+			handler        *metrics.Handler
+			nextHandler    *fakes.Handler
+			responseWriter *fakes.ResponseWriter
+			request        *dns.Msg
+		)
+
+		BeforeEach(func() {
+			request = &dns.Msg{}
+			request.SetQuestion("app-a.potato.", dns.TypeAAAA)
+
+			nextHandler = &fakes.Handler{}
+			nextHandler.ServeDNSStub = func(w dns.ResponseWriter, r *dns.Msg) {
+				resp := &dns.Msg{}
+				resp.SetRcode(r, dns.RcodeNameError)
+				w.WriteMsg(resp)
+			}
+			responseWriter = &fakes.ResponseWriter{}
+
+			handler = &metrics.Handler{Name: "overlay", Metrics: m, Next: nextHandler}
+		})
+
+		It("passes the request and response through", func() {
+			handler.ServeDNS(responseWriter, request)
+
+			Expect(nextHandler.ServeDNSCallCount()).To(Equal(1))
+			_, r := nextHandler.ServeDNSArgsForCall(0)
+			Expect(r).To(Equal(request))
+
+			Expect(responseWriter.WriteMsgCallCount()).To(Equal(1))
+			Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeNameError))
+		})
+
+		It("counts queries by handler, qtype and rcode", func() {
+			handler.ServeDNS(responseWriter, request)
+
+			body := scrape(m)
+			Expect(body).To(ContainSubstring(`ducati_dns_queries_total{handler="overlay",qtype="AAAA",rcode="NXDOMAIN"} 1`))
+			Expect(body).To(ContainSubstring(`ducati_dns_query_duration_seconds_count{handler="overlay"} 1`))
+			Expect(body).To(ContainSubstring("ducati_dns_queries_in_flight 0"))
+		})
+
+		It("tracks queries in flight", func() {
+			nextHandler.ServeDNSStub = func(w dns.ResponseWriter, r *dns.Msg) {
+				Expect(scrape(m)).To(ContainSubstring("ducati_dns_queries_in_flight 1"))
+			}
+
+			handler.ServeDNS(responseWriter, request)
+		})
+
+		Context("when the next handler does not respond", func() {
+			BeforeEach(func() {
+				nextHandler.ServeDNSStub = nil
+			})
+
+			It("records the query without an rcode", func() {
+				handler.ServeDNS(responseWriter, request)
+
+				Expect(scrape(m)).To(ContainSubstring(`ducati_dns_queries_total{handler="overlay",qtype="AAAA",rcode="NONE"} 1`))
+			})
+		})
+
+		Context("when the next handler forwards the query", func() {
+			BeforeEach(func() {
+				next := nextHandler.ServeDNSStub
+				nextHandler.ServeDNSStub = func(w dns.ResponseWriter, r *dns.Msg) {
+					w.(interface {
+						MarkForwarded()
+					}).MarkForwarded()
+					next(w, r)
+				}
+			})
+
+			It("records the query once, as a fallthrough", func() {
+				handler.ServeDNS(responseWriter, request)
+
+				body := scrape(m)
+				Expect(body).To(ContainSubstring(`ducati_dns_queries_total{handler="fallthrough",qtype="AAAA",rcode="NXDOMAIN"} 1`))
+				Expect(body).NotTo(ContainSubstring(`handler="overlay"`))
+			})
+		})
+	})
+
+	Describe("DaemonClient", func() {
+		var (
+			client     *metrics.DaemonClient
+			fakeClient *fakes.DucatiDaemonClient
+		)
+
+		BeforeEach(func() {
+			fakeClient = &fakes.DucatiDaemonClient{}
+			fakeClient.ListContainersReturns([]models.Container{{ID: "some-container"}}, nil)
+			client = &metrics.DaemonClient{Metrics: m, Client: fakeClient}
+		})
+
+		It("records the latency of each call", func() {
+			containers, err := client.ListContainers()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containers).To(Equal([]models.Container{{ID: "some-container"}}))
+
+			body := scrape(m)
+			Expect(body).To(ContainSubstring("ducati_dns_daemon_request_duration_seconds_count 1"))
+			Expect(body).To(ContainSubstring("ducati_dns_daemon_request_errors_total 0"))
+		})
+
+		It("counts errors", func() {
+			fakeClient.ListContainersReturns(nil, errors.New("potato"))
+
+			_, err := client.ListContainers()
+			Expect(err).To(MatchError("potato"))
+			Expect(scrape(m)).To(ContainSubstring("ducati_dns_daemon_request_errors_total 1"))
+		})
+
+		It("records targeted lookups", func() {
+			fakeClient.GetContainerByIPReturns(models.Container{ID: "some-container"}, true, nil)
+
+			container, found, err := client.GetContainerByIP("10.11.12.13")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(container.ID).To(Equal("some-container"))
+			Expect(fakeClient.GetContainerByIPArgsForCall(0)).To(Equal("10.11.12.13"))
+			Expect(scrape(m)).To(ContainSubstring("ducati_dns_daemon_request_duration_seconds_count 1"))
+		})
+	})
+
+	Describe("Exchanger", func() {
+		var (
+			exchanger     *metrics.Exchanger
+			fakeExchanger *fakes.Exchanger
+		)
+
+		BeforeEach(func() {
+			fakeExchanger = &fakes.Exchanger{}
+			fakeExchanger.ExchangeReturns(&dns.Msg{}, 250*time.Millisecond, nil)
+			exchanger = &metrics.Exchanger{Metrics: m, Exchanger: fakeExchanger}
+		})
+
+		It("records the round trip time", func() {
+			_, rtt, err := exchanger.Exchange(&dns.Msg{}, "1.2.3.4:53")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rtt).To(Equal(250 * time.Millisecond))
+
+			body := scrape(m)
+			Expect(body).To(ContainSubstring("ducati_dns_upstream_rtt_seconds_count 1"))
+			Expect(body).To(ContainSubstring("ducati_dns_upstream_rtt_seconds_sum 0.25"))
+		})
+
+		It("counts failed exchanges", func() {
+			fakeExchanger.ExchangeReturns(nil, 0, errors.New("potato"))
+
+			_, _, err := exchanger.Exchange(&dns.Msg{}, "1.2.3.4:53")
+			Expect(err).To(MatchError("potato"))
+			Expect(scrape(m)).To(ContainSubstring("ducati_dns_upstream_errors_total 1"))
+		})
+	})
+})
@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "ducati_dns"
+
+// Metrics holds the collectors exported on /metrics.  A nil *Metrics is
+// valid and records nothing, so instrumented components work without it.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	queries         *prometheus.CounterVec
+	queryDuration   *prometheus.HistogramVec
+	inFlight        prometheus.Gauge
+	daemonDuration  prometheus.Histogram
+	daemonErrors    prometheus.Counter
+	upstreamRTT     prometheus.Histogram
+	upstreamErrors  prometheus.Counter
+	cacheOperations *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "queries_total",
+			Help:      "DNS queries answered, by handler, query type and response code.",
+		}, []string{"handler", "qtype", "rcode"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "query_duration_seconds",
+			Help:      "Time taken to answer DNS queries, by handler.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"handler"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "queries_in_flight",
+			Help:      "DNS queries currently being answered.",
+		}),
+		daemonDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "daemon_request_duration_seconds",
+			Help:      "Latency of each HTTP request to a ducati daemon API, retries included.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 12),
+		}),
+		daemonErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "daemon_request_errors_total",
+			Help:      "Failed HTTP requests to a ducati daemon API, retries included.",
+		}),
+		upstreamRTT: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "upstream_rtt_seconds",
+			Help:      "Round trip time of queries forwarded to the upstream server.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 12),
+		}),
+		upstreamErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_errors_total",
+			Help:      "Queries that could not be exchanged with the upstream server.",
+		}),
+		cacheOperations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_lookups_total",
+			Help:      "Cache lookups, by cache and result (hit or miss).",
+		}, []string{"cache", "result"}),
+	}
+
+	m.Registry.MustRegister(
+		m.queries,
+		m.queryDuration,
+		m.inFlight,
+		m.daemonDuration,
+		m.daemonErrors,
+		m.upstreamRTT,
+		m.upstreamErrors,
+		m.cacheOperations,
+	)
+
+	return m
+}
+
+func (m *Metrics) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{}))
+	return mux
+}
+
+func (m *Metrics) CacheHit(cache string) {
+	if m == nil {
+		return
+	}
+	m.cacheOperations.WithLabelValues(cache, "hit").Inc()
+}
+
+func (m *Metrics) CacheMiss(cache string) {
+	if m == nil {
+		return
+	}
+	m.cacheOperations.WithLabelValues(cache, "miss").Inc()
+}
+
+func (m *Metrics) queryStarted() {
+	if m == nil {
+		return
+	}
+	m.inFlight.Inc()
+}
+
+func (m *Metrics) queryFinished(handler string, qtype uint16, rcode string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.inFlight.Dec()
+	m.queries.WithLabelValues(handler, typeName(qtype), rcode).Inc()
+	m.queryDuration.WithLabelValues(handler).Observe(duration.Seconds())
+}
+
+func (m *Metrics) daemonRequest(duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.daemonDuration.Observe(duration.Seconds())
+	if err != nil {
+		m.daemonErrors.Inc()
+	}
+}
+
+func (m *Metrics) upstreamExchange(rtt time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	if err != nil {
+		m.upstreamErrors.Inc()
+		return
+	}
+	m.upstreamRTT.Observe(rtt.Seconds())
+}
+
+func typeName(qtype uint16) string {
+	if name, ok := dns.TypeToString[qtype]; ok {
+		return name
+	}
+	return "OTHER"
+}
+
+func rcodeName(rcode int) string {
+	if name, ok := dns.RcodeToString[rcode]; ok {
+		return name
+	}
+	return "OTHER"
+}
@@ -0,0 +1,62 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/metrics"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func scrape(m *metrics.Metrics) string {
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	Expect(recorder.Code).To(Equal(http.StatusOK))
+
+	body, err := ioutil.ReadAll(recorder.Body)
+	Expect(err).NotTo(HaveOccurred())
+	return string(body)
+}
+
+var _ = Describe("Metrics", func() {
+	var m *metrics.Metrics
+
+	BeforeEach(func() {
+		m = metrics.New()
+	})
+
+	It("serves the registered collectors on /metrics", func() {
+		body := scrape(m)
+		Expect(body).To(ContainSubstring("ducati_dns_queries_in_flight 0"))
+		Expect(body).To(ContainSubstring("ducati_dns_daemon_request_errors_total 0"))
+	})
+
+	It("does not serve other paths", func() {
+		recorder := httptest.NewRecorder()
+		m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("counts cache hits and misses", func() {
+		m.CacheHit("rrsig")
+		m.CacheHit("rrsig")
+		m.CacheMiss("rrsig")
+
+		body := scrape(m)
+		Expect(body).To(ContainSubstring(`ducati_dns_cache_lookups_total{cache="rrsig",result="hit"} 2`))
+		Expect(body).To(ContainSubstring(`ducati_dns_cache_lookups_total{cache="rrsig",result="miss"} 1`))
+	})
+
+	Context("when nil", func() {
+		It("records nothing without panicking", func() {
+			var nilMetrics *metrics.Metrics
+			Expect(func() {
+				nilMetrics.CacheHit("rrsig")
+				nilMetrics.CacheMiss("rrsig")
+			}).NotTo(Panic())
+		})
+	})
+})
@@ -0,0 +1,117 @@
+package resolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// AliasStore maps alias labels under the overlay suffix to the app GUIDs
+// they currently point at.  When Path is set, every change is written to
+// that file before it takes effect, and Load restores the aliases saved by
+// a previous run.
+type AliasStore struct {
+	Path string
+
+	mutex   sync.RWMutex
+	aliases map[string]string
+}
+
+// Load reads the aliases saved at Path.  A missing file is not an error.
+func (s *AliasStore) Load() error {
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read aliases: %s", err)
+	}
+
+	aliases := map[string]string{}
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return fmt.Errorf("parse aliases: %s", err)
+	}
+
+	s.mutex.Lock()
+	s.aliases = aliases
+	s.mutex.Unlock()
+	return nil
+}
+
+func (s *AliasStore) Set(alias, target string) (created bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	alias = strings.ToLower(alias)
+	_, exists := s.aliases[alias]
+
+	aliases := s.copy()
+	aliases[alias] = target
+	if err := s.save(aliases); err != nil {
+		return false, err
+	}
+
+	s.aliases = aliases
+	return !exists, nil
+}
+
+func (s *AliasStore) Delete(alias string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	alias = strings.ToLower(alias)
+	if _, exists := s.aliases[alias]; !exists {
+		return false, nil
+	}
+
+	aliases := s.copy()
+	delete(aliases, alias)
+	if err := s.save(aliases); err != nil {
+		return false, err
+	}
+
+	s.aliases = aliases
+	return true, nil
+}
+
+func (s *AliasStore) Get(alias string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	target, ok := s.aliases[strings.ToLower(alias)]
+	return target, ok
+}
+
+func (s *AliasStore) List() map[string]string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.copy()
+}
+
+func (s *AliasStore) copy() map[string]string {
+	aliases := map[string]string{}
+	for alias, target := range s.aliases {
+		aliases[alias] = target
+	}
+	return aliases
+}
+
+// save writes the aliases to Path, if set.
+func (s *AliasStore) save(aliases map[string]string) error {
+	if s.Path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(aliases)
+	if err != nil {
+		return fmt.Errorf("marshal aliases: %s", err)
+	}
+	if err := writeFileAtomic(s.Path, data); err != nil {
+		return fmt.Errorf("write aliases: %s", err)
+	}
+	return nil
+}
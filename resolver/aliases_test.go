@@ -0,0 +1,112 @@
+package resolver_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AliasStore", func() {
+	var store *resolver.AliasStore
+
+	BeforeEach(func() {
+		store = &resolver.AliasStore{}
+	})
+
+	It("reports whether an alias was created or updated", func() {
+		created, err := store.Set("payments", "blue-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created).To(BeTrue())
+
+		created, err = store.Set("payments", "green-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created).To(BeFalse())
+
+		target, ok := store.Get("payments")
+		Expect(ok).To(BeTrue())
+		Expect(target).To(Equal("green-guid"))
+	})
+
+	It("matches aliases case-insensitively", func() {
+		store.Set("Payments", "blue-guid")
+
+		_, ok := store.Get("PAYMENTS")
+		Expect(ok).To(BeTrue())
+	})
+
+	It("deletes aliases", func() {
+		store.Set("payments", "blue-guid")
+
+		deleted, err := store.Delete("payments")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deleted).To(BeTrue())
+
+		deleted, err = store.Delete("payments")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deleted).To(BeFalse())
+		Expect(store.List()).To(BeEmpty())
+	})
+
+	It("lists a copy of the aliases", func() {
+		store.Set("payments", "blue-guid")
+		aliases := store.List()
+		aliases["billing"] = "other-guid"
+
+		Expect(store.List()).To(Equal(map[string]string{"payments": "blue-guid"}))
+	})
+
+	Context("when a path is set", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "aliases")
+			Expect(err).NotTo(HaveOccurred())
+
+			store.Path = filepath.Join(dir, "aliases.json")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("restores the saved aliases in a new store", func() {
+			_, err := store.Set("payments", "blue-guid")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = store.Set("billing", "billing-guid")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = store.Delete("billing")
+			Expect(err).NotTo(HaveOccurred())
+
+			restored := &resolver.AliasStore{Path: store.Path}
+			Expect(restored.Load()).To(Succeed())
+			Expect(restored.List()).To(Equal(map[string]string{"payments": "blue-guid"}))
+		})
+
+		It("loads nothing when the file does not exist yet", func() {
+			Expect(store.Load()).To(Succeed())
+			Expect(store.List()).To(BeEmpty())
+		})
+
+		It("fails to load a corrupt file", func() {
+			Expect(ioutil.WriteFile(store.Path, []byte("{"), 0644)).To(Succeed())
+
+			Expect(store.Load()).To(MatchError(ContainSubstring("parse aliases")))
+		})
+
+		It("leaves the alias unchanged when it cannot be saved", func() {
+			store.Path = filepath.Join(dir, "missing", "aliases.json")
+
+			_, err := store.Set("payments", "blue-guid")
+			Expect(err).To(MatchError(ContainSubstring("write aliases")))
+
+			_, ok := store.Get("payments")
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
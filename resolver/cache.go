@@ -0,0 +1,10 @@
+package resolver
+
+import "time"
+
+// CacheEntry describes a cached item for the admin API.
+type CacheEntry struct {
+	Key     string    `json:"key"`
+	Value   string    `json:"value"`
+	Expires time.Time `json:"expires"`
+}
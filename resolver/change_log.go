@@ -0,0 +1,185 @@
+package resolver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+)
+
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// RecordChange is a record that was added, removed or changed between two
+// listings.  Previous is the instance with the same IP before a change.
+type RecordChange struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Name     string    `json:"name"`
+	Instance Instance  `json:"instance"`
+	Previous *Instance `json:"previous,omitempty"`
+}
+
+// ChangeHistory keeps the most recent Max changes, and the last listing of
+// each overlay to compare the next against.  It is shared by the change
+// logs of every overlay and outlives a reload.
+type ChangeHistory struct {
+	Max int
+
+	mutex     sync.RWMutex
+	changes   []RecordChange
+	baselines map[string]map[string]Instance
+}
+
+func (h *ChangeHistory) Add(changes ...RecordChange) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.changes = append(h.changes, changes...)
+	if h.Max > 0 && len(h.changes) > h.Max {
+		h.changes = append([]RecordChange{}, h.changes[len(h.changes)-h.Max:]...)
+	}
+}
+
+// swapBaseline stores the listing of the overlay under suffix and returns
+// the one it replaces, if any.
+func (h *ChangeHistory) swapBaseline(suffix string, current map[string]Instance) (map[string]Instance, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.baselines == nil {
+		h.baselines = map[string]map[string]Instance{}
+	}
+	previous, ok := h.baselines[suffix]
+	h.baselines[suffix] = current
+	return previous, ok
+}
+
+// Changes returns the recorded changes, oldest first.
+func (h *ChangeHistory) Changes() []RecordChange {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return append([]RecordChange{}, h.changes...)
+}
+
+// ChangeLog reports how the records under Suffix changed between the
+// listings of the overlay instances it is given, which the overlay zone
+// hands it on every rebuild.  Changes are logged, added to History and,
+// when AuditFile is set, appended to it as JSON lines.  History also keeps
+// the previous listing, so a reload does not start over from a new
+// baseline; the first listing only sets it.
+type ChangeLog struct {
+	Logger    lager.Logger
+	Suffix    string
+	AuditFile string
+	History   *ChangeHistory
+}
+
+// Record records the changes since the previous listing.
+func (l *ChangeLog) Record(instances []Instance) error {
+	current := map[string]Instance{}
+	for _, instance := range instances {
+		current[instance.IP] = instance
+	}
+
+	previous, ok := l.History.swapBaseline(l.Suffix, current)
+	if !ok {
+		l.Logger.Info("change-log-baseline", lager.Data{"instances": len(current)})
+		return nil
+	}
+
+	changes := l.diff(previous, current, time.Now())
+	if len(changes) == 0 {
+		return nil
+	}
+
+	for _, change := range changes {
+		data := lager.Data{"name": change.Name, "ip": change.Instance.IP, "host_ip": change.Instance.HostIP}
+		if change.Previous != nil {
+			data["previous_app"] = change.Previous.App
+			data["previous_host_ip"] = change.Previous.HostIP
+		}
+		l.Logger.Info("record-"+change.Type, data)
+	}
+	l.History.Add(changes...)
+
+	if l.AuditFile != "" {
+		if err := l.audit(changes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *ChangeLog) diff(previous, current map[string]Instance, now time.Time) []RecordChange {
+	changes := []RecordChange{}
+	for ip, instance := range current {
+		before, ok := previous[ip]
+		switch {
+		case !ok:
+			changes = append(changes, l.change(now, ChangeAdded, instance, nil))
+		case before != instance:
+			before := before
+			changes = append(changes, l.change(now, ChangeChanged, instance, &before))
+		}
+	}
+	for ip, instance := range previous {
+		if _, ok := current[ip]; !ok {
+			changes = append(changes, l.change(now, ChangeRemoved, instance, nil))
+		}
+	}
+
+	sort.Sort(byIP(changes))
+	return changes
+}
+
+func (l *ChangeLog) change(now time.Time, changeType string, instance Instance, previous *Instance) RecordChange {
+	return RecordChange{
+		Time:     now,
+		Type:     changeType,
+		Name:     instance.App + "." + l.Suffix + ".",
+		Instance: instance,
+		Previous: previous,
+	}
+}
+
+// audit appends the changes to AuditFile in a single write, so that change
+// logs sharing the file do not interleave their lines.
+func (l *ChangeLog) audit(changes []RecordChange) error {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	for _, change := range changes {
+		if err := encoder.Encode(change); err != nil {
+			return fmt.Errorf("write audit file: %s", err)
+		}
+	}
+
+	f, err := os.OpenFile(l.AuditFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("write audit file: %s", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("write audit file: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write audit file: %s", err)
+	}
+	return nil
+}
+
+type byIP []RecordChange
+
+func (b byIP) Len() int           { return len(b) }
+func (b byIP) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byIP) Less(i, j int) bool { return b[i].Instance.IP < b[j].Instance.IP }
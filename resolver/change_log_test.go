@@ -0,0 +1,190 @@
+package resolver_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("ChangeLog", func() {
+	var (
+		listing    []resolver.Instance
+		fakeLogger *lagertest.TestLogger
+		history    *resolver.ChangeHistory
+		changeLog  *resolver.ChangeLog
+		tempDir    string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "change-log")
+		Expect(err).NotTo(HaveOccurred())
+
+		listing = []resolver.Instance{
+			{App: "some-app", IP: "10.0.0.1", HostIP: "10.244.0.2"},
+			{App: "some-app", IP: "10.0.0.2", HostIP: "10.244.0.2"},
+			{App: "other-app", IP: "10.0.0.3", HostIP: "10.244.0.3"},
+		}
+
+		fakeLogger = lagertest.NewTestLogger("test")
+		history = &resolver.ChangeHistory{Max: 10}
+		changeLog = &resolver.ChangeLog{
+			Logger:  fakeLogger,
+			Suffix:  "potato",
+			History: history,
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	changeInstances := func() {
+		listing = []resolver.Instance{
+			{App: "some-app", IP: "10.0.0.1", HostIP: "10.244.0.2"},
+			{App: "some-app", IP: "10.0.0.2", HostIP: "10.244.0.4"},
+			{App: "new-app", IP: "10.0.0.4", HostIP: "10.244.0.3"},
+		}
+	}
+
+	It("takes the first listing as the baseline", func() {
+		Expect(changeLog.Record(listing)).To(Succeed())
+
+		Expect(history.Changes()).To(BeEmpty())
+		Expect(fakeLogger).To(gbytes.Say("change-log-baseline.*\"instances\":3"))
+	})
+
+	It("records the added, removed and changed records", func() {
+		Expect(changeLog.Record(listing)).To(Succeed())
+		changeInstances()
+		before := time.Now()
+		Expect(changeLog.Record(listing)).To(Succeed())
+
+		changes := history.Changes()
+		Expect(changes).To(HaveLen(3))
+
+		Expect(changes[0].Type).To(Equal(resolver.ChangeChanged))
+		Expect(changes[0].Name).To(Equal("some-app.potato."))
+		Expect(changes[0].Instance.HostIP).To(Equal("10.244.0.4"))
+		Expect(changes[0].Previous).To(Equal(&resolver.Instance{App: "some-app", IP: "10.0.0.2", HostIP: "10.244.0.2"}))
+
+		Expect(changes[1].Type).To(Equal(resolver.ChangeRemoved))
+		Expect(changes[1].Name).To(Equal("other-app.potato."))
+		Expect(changes[1].Instance.IP).To(Equal("10.0.0.3"))
+
+		Expect(changes[2].Type).To(Equal(resolver.ChangeAdded))
+		Expect(changes[2].Name).To(Equal("new-app.potato."))
+		Expect(changes[2].Instance.IP).To(Equal("10.0.0.4"))
+
+		for _, change := range changes {
+			Expect(change.Time).To(BeTemporally(">=", before))
+		}
+	})
+
+	It("logs each change", func() {
+		Expect(changeLog.Record(listing)).To(Succeed())
+		changeInstances()
+		Expect(changeLog.Record(listing)).To(Succeed())
+
+		Expect(fakeLogger).To(gbytes.Say(`record-changed.*"host_ip":"10.244.0.4".*"ip":"10.0.0.2".*"previous_host_ip":"10.244.0.2"`))
+		Expect(fakeLogger).To(gbytes.Say(`record-removed.*"ip":"10.0.0.3".*"name":"other-app.potato."`))
+		Expect(fakeLogger).To(gbytes.Say(`record-added.*"ip":"10.0.0.4".*"name":"new-app.potato."`))
+	})
+
+	It("records nothing when the records did not change", func() {
+		Expect(changeLog.Record(listing)).To(Succeed())
+		Expect(changeLog.Record(listing)).To(Succeed())
+
+		Expect(history.Changes()).To(BeEmpty())
+	})
+
+	It("keeps the baseline in the history, for the change log of a reloaded chain", func() {
+		Expect(changeLog.Record(listing)).To(Succeed())
+
+		changeLog = &resolver.ChangeLog{Logger: fakeLogger, Suffix: "potato", History: history}
+		changeInstances()
+		Expect(changeLog.Record(listing)).To(Succeed())
+		Expect(history.Changes()).To(HaveLen(3))
+	})
+
+	It("keeps a baseline for each suffix", func() {
+		Expect(changeLog.Record(listing)).To(Succeed())
+
+		other := &resolver.ChangeLog{Logger: fakeLogger, Suffix: "prod.potato", History: history}
+		changeInstances()
+		Expect(other.Record(listing)).To(Succeed())
+		Expect(history.Changes()).To(BeEmpty())
+	})
+
+	It("keeps only the most recent changes", func() {
+		history.Max = 2
+
+		Expect(changeLog.Record(listing)).To(Succeed())
+		changeInstances()
+		Expect(changeLog.Record(listing)).To(Succeed())
+
+		changes := history.Changes()
+		Expect(changes).To(HaveLen(2))
+		Expect(changes[1].Type).To(Equal(resolver.ChangeAdded))
+	})
+
+	Context("when an audit file is given", func() {
+		var auditPath string
+
+		BeforeEach(func() {
+			auditPath = filepath.Join(tempDir, "audit.log")
+			changeLog.AuditFile = auditPath
+		})
+
+		readAudit := func() []resolver.RecordChange {
+			f, err := os.Open(auditPath)
+			Expect(err).NotTo(HaveOccurred())
+			defer f.Close()
+
+			changes := []resolver.RecordChange{}
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				var change resolver.RecordChange
+				Expect(json.Unmarshal(scanner.Bytes(), &change)).To(Succeed())
+				changes = append(changes, change)
+			}
+			return changes
+		}
+
+		It("appends each change as a JSON line", func() {
+			Expect(changeLog.Record(listing)).To(Succeed())
+			_, err := os.Stat(auditPath)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+
+			changeInstances()
+			Expect(changeLog.Record(listing)).To(Succeed())
+			listing = nil
+			Expect(changeLog.Record(listing)).To(Succeed())
+
+			changes := readAudit()
+			Expect(changes).To(HaveLen(6))
+			Expect(changes[0].Type).To(Equal(resolver.ChangeChanged))
+			Expect(changes[5].Type).To(Equal(resolver.ChangeRemoved))
+			Expect(changes[5].Instance.IP).To(Equal("10.0.0.4"))
+		})
+
+		It("fails when the audit file cannot be written", func() {
+			changeLog.AuditFile = filepath.Join(tempDir, "missing", "audit.log")
+
+			Expect(changeLog.Record(listing)).To(Succeed())
+			changeInstances()
+			Expect(changeLog.Record(listing)).To(MatchError(ContainSubstring("write audit file:")))
+			Expect(history.Changes()).To(HaveLen(3))
+		})
+	})
+})
@@ -0,0 +1,288 @@
+package resolver
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/pivotal-golang/lager"
+)
+
+var ErrCircuitOpen = errors.New("daemon circuit breaker is open")
+
+// ErrStale comes with containers the breaker answered from its cache while
+// open, so that callers can tell them from a fresh listing.
+var ErrStale = errors.New("daemon circuit breaker is open, answering from cache")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreaker stops calling the daemon after Threshold consecutive
+// failures.  While open it answers from the last successful answer to the
+// same question, or from the last full listing, if that is no older than
+// MaxStale, along with ErrStale, or fails fast with ErrCircuitOpen.  After
+// Cooldown a single trial call is let through; its outcome closes the
+// breaker or opens it again.
+type CircuitBreaker struct {
+	Logger    lager.Logger
+	Client    ducatiDaemonClient
+	Threshold int
+	Cooldown  time.Duration
+	MaxStale  time.Duration
+
+	mutex      sync.Mutex
+	state      breakerState
+	failures   int
+	openedAt   time.Time
+	trial      bool
+	last       []models.Container
+	lastUpdate time.Time
+	apps       map[string]cachedListing
+	ips        map[string]cachedLookup
+	pruned     time.Time
+}
+
+type cachedListing struct {
+	containers []models.Container
+	updated    time.Time
+}
+
+type cachedLookup struct {
+	container models.Container
+	found     bool
+	updated   time.Time
+}
+
+func (b *CircuitBreaker) ListContainers() ([]models.Container, error) {
+	var containers []models.Container
+	useStale, err := b.guard(func() (err error) {
+		containers, err = b.Client.ListContainers()
+		return err
+	})
+	if useStale {
+		last, _, err := b.stale()
+		if err != nil {
+			return nil, err
+		}
+		return last, ErrStale
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	b.last = containers
+	b.lastUpdate = time.Now()
+	b.mutex.Unlock()
+	return containers, nil
+}
+
+func (b *CircuitBreaker) ListAppContainers(appGUID string) ([]models.Container, error) {
+	var containers []models.Container
+	useStale, err := b.guard(func() (err error) {
+		containers, err = b.Client.ListAppContainers(appGUID)
+		return err
+	})
+	if !useStale {
+		if err == nil {
+			b.mutex.Lock()
+			b.prune()
+			b.apps[appGUID] = cachedListing{containers: containers, updated: time.Now()}
+			b.mutex.Unlock()
+		}
+		return containers, err
+	}
+
+	b.mutex.Lock()
+	cached, ok := b.apps[appGUID]
+	b.mutex.Unlock()
+
+	last, lastUpdate, err := b.stale()
+	if ok && b.fresh(cached.updated) && (err != nil || cached.updated.After(lastUpdate)) {
+		return cached.containers, ErrStale
+	}
+	if err != nil {
+		return nil, err
+	}
+	return filterContainers(last, func(c models.Container) bool { return c.App == appGUID }), ErrStale
+}
+
+func (b *CircuitBreaker) GetContainer(id string) (models.Container, bool, error) {
+	var container models.Container
+	var found bool
+	useStale, err := b.guard(func() (err error) {
+		container, found, err = b.Client.GetContainer(id)
+		return err
+	})
+	if !useStale {
+		return container, found, err
+	}
+	return b.staleContainer(func(c models.Container) bool { return c.ID == id })
+}
+
+func (b *CircuitBreaker) GetContainerByIP(ip string) (models.Container, bool, error) {
+	var container models.Container
+	var found bool
+	useStale, err := b.guard(func() (err error) {
+		container, found, err = b.Client.GetContainerByIP(ip)
+		return err
+	})
+	if !useStale {
+		if err == nil {
+			b.mutex.Lock()
+			b.prune()
+			b.ips[ip] = cachedLookup{container: container, found: found, updated: time.Now()}
+			b.mutex.Unlock()
+		}
+		return container, found, err
+	}
+
+	b.mutex.Lock()
+	cached, ok := b.ips[ip]
+	b.mutex.Unlock()
+
+	_, lastUpdate, err := b.stale()
+	if ok && b.fresh(cached.updated) && (err != nil || cached.updated.After(lastUpdate)) {
+		return cached.container, cached.found, ErrStale
+	}
+	return b.staleContainer(func(c models.Container) bool { return c.IP == ip })
+}
+
+// guard makes the request unless the breaker is open and records its
+// outcome.  It reports whether the caller should answer from the last
+// listing instead.
+func (b *CircuitBreaker) guard(request func() error) (bool, error) {
+	if !b.allow() {
+		return true, nil
+	}
+
+	err := request()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err != nil {
+		b.failed(err)
+		return b.state == breakerOpen, err
+	}
+
+	if b.state != breakerClosed {
+		b.Logger.Info("breaker-closed")
+	}
+	b.state = breakerClosed
+	b.failures = 0
+	b.trial = false
+	return false, nil
+}
+
+// State returns closed, open or half-open.
+func (b *CircuitBreaker) State() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.state.String()
+}
+
+func (b *CircuitBreaker) allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.Cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.trial = true
+		b.Logger.Info("breaker-half-open")
+		return true
+	case breakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *CircuitBreaker) failed(err error) {
+	b.failures++
+	b.trial = false
+
+	threshold := b.Threshold
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	if b.state == breakerHalfOpen || (b.state != breakerOpen && b.failures >= threshold) {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+		b.Logger.Error("breaker-opened", err, lager.Data{"failures": b.failures})
+	}
+}
+
+func (b *CircuitBreaker) stale() ([]models.Container, time.Time, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.last != nil && b.fresh(b.lastUpdate) {
+		return b.last, b.lastUpdate, nil
+	}
+	return nil, time.Time{}, ErrCircuitOpen
+}
+
+func (b *CircuitBreaker) staleContainer(match func(models.Container) bool) (models.Container, bool, error) {
+	last, _, err := b.stale()
+	if err != nil {
+		return models.Container{}, false, err
+	}
+	container, found := firstContainer(last, match)
+	return container, found, ErrStale
+}
+
+func (b *CircuitBreaker) fresh(updated time.Time) bool {
+	return time.Since(updated) <= b.MaxStale
+}
+
+// prune drops the cached answers that are too old to be served, at most
+// once every MaxStale.  It must be called with the mutex held.
+func (b *CircuitBreaker) prune() {
+	if b.apps == nil {
+		b.apps = map[string]cachedListing{}
+		b.ips = map[string]cachedLookup{}
+	}
+	if time.Since(b.pruned) < b.MaxStale {
+		return
+	}
+	b.pruned = time.Now()
+
+	for app, cached := range b.apps {
+		if !b.fresh(cached.updated) {
+			delete(b.apps, app)
+		}
+	}
+	for ip, cached := range b.ips {
+		if !b.fresh(cached.updated) {
+			delete(b.ips, ip)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package resolver_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CircuitBreaker", func() {
+	var (
+		fakeDaemonClient *fakes.DucatiDaemonClient
+		logger           *lagertest.TestLogger
+		breaker          *resolver.CircuitBreaker
+		containers       []models.Container
+	)
+
+	BeforeEach(func() {
+		containers = []models.Container{{App: "some-app-guid", IP: "10.11.12.13"}}
+		fakeDaemonClient = &fakes.DucatiDaemonClient{}
+		fakeDaemonClient.ListContainersReturns(containers, nil)
+		logger = lagertest.NewTestLogger("test")
+		breaker = &resolver.CircuitBreaker{
+			Logger:    logger,
+			Client:    fakeDaemonClient,
+			Threshold: 2,
+			Cooldown:  50 * time.Millisecond,
+		}
+	})
+
+	fail := func(times int) {
+		fakeDaemonClient.ListContainersReturns(nil, errors.New("potato"))
+		for i := 0; i < times; i++ {
+			breaker.ListContainers()
+		}
+	}
+
+	It("passes calls through while closed", func() {
+		Expect(breaker.ListContainers()).To(Equal(containers))
+		Expect(breaker.State()).To(Equal("closed"))
+	})
+
+	It("returns errors until the threshold is reached", func() {
+		fakeDaemonClient.ListContainersReturns(nil, errors.New("potato"))
+
+		_, err := breaker.ListContainers()
+		Expect(err).To(MatchError("potato"))
+		Expect(breaker.State()).To(Equal("closed"))
+	})
+
+	Context("once the threshold is reached", func() {
+		BeforeEach(func() {
+			fail(2)
+		})
+
+		It("opens and logs the transition", func() {
+			Expect(breaker.State()).To(Equal("open"))
+			Expect(logger).To(gbytes.Say("breaker-opened.*potato.*failures.*2"))
+		})
+
+		It("fails fast without calling the daemon", func() {
+			_, err := breaker.ListContainers()
+			Expect(err).To(Equal(resolver.ErrCircuitOpen))
+			Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(2))
+		})
+
+		It("lets a trial call through after the cooldown and closes when it succeeds", func() {
+			time.Sleep(60 * time.Millisecond)
+			fakeDaemonClient.ListContainersReturns(containers, nil)
+
+			Expect(breaker.ListContainers()).To(Equal(containers))
+			Expect(breaker.State()).To(Equal("closed"))
+			Expect(logger).To(gbytes.Say("breaker-half-open"))
+			Expect(logger).To(gbytes.Say("breaker-closed"))
+		})
+
+		It("opens again when the trial call fails", func() {
+			time.Sleep(60 * time.Millisecond)
+
+			breaker.ListContainers()
+			Expect(breaker.State()).To(Equal("open"))
+			Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(3))
+
+			_, err := breaker.ListContainers()
+			Expect(err).To(Equal(resolver.ErrCircuitOpen))
+			Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(3))
+		})
+	})
+
+	Context("when stale data is allowed", func() {
+		BeforeEach(func() {
+			breaker.MaxStale = time.Minute
+			breaker.ListContainers()
+			fail(2)
+		})
+
+		It("answers with the last good listing while open and reports it as stale", func() {
+			listed, err := breaker.ListContainers()
+			Expect(err).To(Equal(resolver.ErrStale))
+			Expect(listed).To(Equal(containers))
+			Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(3))
+		})
+
+		It("fails fast once the listing is too old", func() {
+			breaker.MaxStale = time.Nanosecond
+
+			_, err := breaker.ListContainers()
+			Expect(err).To(Equal(resolver.ErrCircuitOpen))
+		})
+
+		It("answers targeted lookups from the last good listing", func() {
+			listed, err := breaker.ListAppContainers("some-app-guid")
+			Expect(err).To(Equal(resolver.ErrStale))
+			Expect(listed).To(Equal(containers))
+
+			listed, err = breaker.ListAppContainers("some-other-app-guid")
+			Expect(err).To(Equal(resolver.ErrStale))
+			Expect(listed).To(BeEmpty())
+
+			container, found, err := breaker.GetContainerByIP("10.11.12.13")
+			Expect(err).To(Equal(resolver.ErrStale))
+			Expect(found).To(BeTrue())
+			Expect(container).To(Equal(containers[0]))
+
+			_, found, err = breaker.GetContainer("missing")
+			Expect(err).To(Equal(resolver.ErrStale))
+			Expect(found).To(BeFalse())
+
+			Expect(fakeDaemonClient.ListAppContainersCallCount()).To(Equal(0))
+			Expect(fakeDaemonClient.GetContainerByIPCallCount()).To(Equal(0))
+			Expect(fakeDaemonClient.GetContainerCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when only targeted lookups are made", func() {
+		var appContainers []models.Container
+
+		BeforeEach(func() {
+			breaker.MaxStale = time.Minute
+			appContainers = []models.Container{{App: "some-app-guid", IP: "10.11.12.14", HostIP: "10.244.0.2"}}
+			fakeDaemonClient.ListAppContainersReturns(appContainers, nil)
+			fakeDaemonClient.GetContainerByIPReturns(appContainers[0], true, nil)
+
+			breaker.ListAppContainers("some-app-guid")
+			breaker.GetContainerByIP("10.11.12.14")
+
+			fakeDaemonClient.ListAppContainersReturns(nil, errors.New("potato"))
+			fakeDaemonClient.GetContainerByIPReturns(models.Container{}, false, errors.New("potato"))
+			breaker.ListAppContainers("some-app-guid")
+			breaker.ListAppContainers("some-app-guid")
+			Expect(breaker.State()).To(Equal("open"))
+		})
+
+		It("answers from the last good answer to the same question while open", func() {
+			listed, err := breaker.ListAppContainers("some-app-guid")
+			Expect(err).To(Equal(resolver.ErrStale))
+			Expect(listed).To(Equal(appContainers))
+
+			container, found, err := breaker.GetContainerByIP("10.11.12.14")
+			Expect(err).To(Equal(resolver.ErrStale))
+			Expect(found).To(BeTrue())
+			Expect(container).To(Equal(appContainers[0]))
+
+			Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(0))
+		})
+
+		It("fails fast for questions it has no answer to", func() {
+			_, err := breaker.ListAppContainers("some-other-app-guid")
+			Expect(err).To(Equal(resolver.ErrCircuitOpen))
+		})
+
+		It("fails fast once the answer is too old", func() {
+			breaker.MaxStale = time.Nanosecond
+
+			_, err := breaker.ListAppContainers("some-app-guid")
+			Expect(err).To(Equal(resolver.ErrCircuitOpen))
+		})
+	})
+
+	It("prefers a full listing that is more recent than the targeted answer", func() {
+		breaker.MaxStale = time.Minute
+		fakeDaemonClient.ListAppContainersReturns([]models.Container{{App: "some-app-guid", IP: "10.11.12.14"}}, nil)
+		breaker.ListAppContainers("some-app-guid")
+		breaker.ListContainers()
+		fail(2)
+
+		listed, _ := breaker.ListAppContainers("some-app-guid")
+		Expect(listed).To(Equal(containers))
+	})
+
+	It("counts failed targeted lookups towards the threshold", func() {
+		fakeDaemonClient.ListAppContainersReturns(nil, errors.New("potato"))
+		breaker.ListAppContainers("some-app-guid")
+		breaker.ListAppContainers("some-app-guid")
+
+		Expect(breaker.State()).To(Equal("open"))
+		_, err := breaker.ListAppContainers("some-app-guid")
+		Expect(err).To(Equal(resolver.ErrCircuitOpen))
+		Expect(fakeDaemonClient.ListAppContainersCallCount()).To(Equal(2))
+	})
+})
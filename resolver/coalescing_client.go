@@ -0,0 +1,139 @@
+package resolver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+)
+
+type daemonCall struct {
+	done    chan struct{}
+	listing *containerIndex
+	err     error
+}
+
+// containerIndex is one container listing, indexed for the lookups by app,
+// ID and IP.
+type containerIndex struct {
+	containers []models.Container
+	apps       map[string][]models.Container
+	ids        map[string]models.Container
+	ips        map[string]models.Container
+	listed     time.Time
+}
+
+func newContainerIndex(containers []models.Container) *containerIndex {
+	index := &containerIndex{
+		containers: containers,
+		apps:       map[string][]models.Container{},
+		ids:        map[string]models.Container{},
+		ips:        map[string]models.Container{},
+		listed:     time.Now(),
+	}
+	for _, c := range containers {
+		index.apps[c.App] = append(index.apps[c.App], c)
+		if _, ok := index.ids[c.ID]; !ok {
+			index.ids[c.ID] = c
+		}
+		if _, ok := index.ips[c.IP]; !ok {
+			index.ips[c.IP] = c
+		}
+	}
+	return index
+}
+
+// CoalescingClient answers every lookup from one container listing, which
+// it shares between the callers that need it while it is in progress and
+// reuses for MaxAge after it was made.  The daemon lists every container
+// whatever it is asked, so a query that looks up both an app and the
+// client's IP costs a single listing.  Callers receive shared slices and
+// must not modify them.  OnListing, if set, is given every listing the
+// daemon answered without an error.
+type CoalescingClient struct {
+	Client    ducatiDaemonClient
+	MaxAge    time.Duration
+	OnListing func([]models.Container)
+
+	mutex   sync.Mutex
+	call    *daemonCall
+	listing *containerIndex
+}
+
+func (c *CoalescingClient) ListContainers() ([]models.Container, error) {
+	listing, err := c.list()
+	if listing == nil {
+		return nil, err
+	}
+	return listing.containers, err
+}
+
+func (c *CoalescingClient) ListAppContainers(appGUID string) ([]models.Container, error) {
+	listing, err := c.list()
+	if listing == nil {
+		return nil, err
+	}
+	containers, ok := listing.apps[appGUID]
+	if !ok {
+		containers = []models.Container{}
+	}
+	return containers, err
+}
+
+func (c *CoalescingClient) GetContainer(id string) (models.Container, bool, error) {
+	listing, err := c.list()
+	if listing == nil {
+		return models.Container{}, false, err
+	}
+	container, found := listing.ids[id]
+	return container, found, err
+}
+
+func (c *CoalescingClient) GetContainerByIP(ip string) (models.Container, bool, error) {
+	listing, err := c.list()
+	if listing == nil {
+		return models.Container{}, false, err
+	}
+	container, found := listing.ips[ip]
+	return container, found, err
+}
+
+// list returns the last listing while it is younger than MaxAge, or joins
+// or starts a request for a new one.  A listing that came with an error,
+// such as a stale one from the circuit breaker, is passed on with it but
+// not reused.
+func (c *CoalescingClient) list() (*containerIndex, error) {
+	c.mutex.Lock()
+	if c.listing != nil && time.Since(c.listing.listed) < c.MaxAge {
+		listing := c.listing
+		c.mutex.Unlock()
+		return listing, nil
+	}
+	if call := c.call; call != nil {
+		c.mutex.Unlock()
+		<-call.done
+		return call.listing, call.err
+	}
+	call := &daemonCall{done: make(chan struct{})}
+	c.call = call
+	c.mutex.Unlock()
+
+	containers, err := c.Client.ListContainers()
+	if err == nil || containers != nil {
+		call.listing = newContainerIndex(containers)
+	}
+	call.err = err
+
+	c.mutex.Lock()
+	c.call = nil
+	if err == nil {
+		c.listing = call.listing
+	}
+	c.mutex.Unlock()
+	close(call.done)
+
+	if err == nil && c.OnListing != nil {
+		c.OnListing(containers)
+	}
+	return call.listing, call.err
+}
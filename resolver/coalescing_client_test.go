@@ -0,0 +1,172 @@
+package resolver_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CoalescingClient", func() {
+	var (
+		fakeDaemonClient *fakes.DucatiDaemonClient
+		client           *resolver.CoalescingClient
+		release          chan struct{}
+		containers       []models.Container
+		listErr          error
+	)
+
+	BeforeEach(func() {
+		containers = []models.Container{{App: "some-app-guid", IP: "10.11.12.13"}}
+		listErr = nil
+		release = make(chan struct{})
+		fakeDaemonClient = &fakes.DucatiDaemonClient{}
+		fakeDaemonClient.ListContainersStub = func() ([]models.Container, error) {
+			<-release
+			return containers, listErr
+		}
+		client = &resolver.CoalescingClient{Client: fakeDaemonClient}
+	})
+
+	type result struct {
+		containers []models.Container
+		err        error
+	}
+
+	// listConcurrently starts n listings while the first is still waiting
+	// on the daemon, then lets the daemon answer.
+	listConcurrently := func(n int) []result {
+		results := make([]result, n)
+		wg := sync.WaitGroup{}
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				c, err := client.ListContainers()
+				results[i] = result{c, err}
+			}(i)
+			if i == 0 {
+				Eventually(fakeDaemonClient.ListContainersCallCount).Should(Equal(1))
+			}
+		}
+		Consistently(fakeDaemonClient.ListContainersCallCount, 50*time.Millisecond).Should(Equal(1))
+		close(release)
+		wg.Wait()
+		return results
+	}
+
+	It("shares one daemon request between concurrent callers", func() {
+		results := listConcurrently(5)
+
+		Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(1))
+		for _, r := range results {
+			Expect(r.err).NotTo(HaveOccurred())
+			Expect(r.containers).To(Equal(containers))
+		}
+	})
+
+	It("shares errors too", func() {
+		listErr = errors.New("potato")
+
+		for _, r := range listConcurrently(3) {
+			Expect(r.err).To(MatchError("potato"))
+		}
+	})
+
+	It("makes a new request once the previous one finished", func() {
+		close(release)
+
+		client.ListContainers()
+		client.ListContainers()
+		Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(2))
+	})
+
+	It("answers lookups by app, ID and IP from the shared listing", func() {
+		containers = []models.Container{
+			{ID: "container-1", App: "some-app-guid", IP: "10.11.12.13"},
+			{ID: "container-2", App: "some-other-app-guid", IP: "10.11.12.14"},
+		}
+		client.MaxAge = time.Minute
+		close(release)
+
+		Expect(client.ListAppContainers("some-app-guid")).To(Equal([]models.Container{containers[0]}))
+		Expect(client.ListAppContainers("unknown-app-guid")).To(BeEmpty())
+
+		container, found, err := client.GetContainerByIP("10.11.12.14")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(container.ID).To(Equal("container-2"))
+
+		_, found, err = client.GetContainer("container-3")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeFalse())
+
+		Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(1))
+		Expect(fakeDaemonClient.ListAppContainersCallCount()).To(Equal(0))
+		Expect(fakeDaemonClient.GetContainerByIPCallCount()).To(Equal(0))
+	})
+
+	It("lists again once the listing is older than MaxAge", func() {
+		client.MaxAge = 20 * time.Millisecond
+		close(release)
+
+		client.ListAppContainers("some-app-guid")
+		client.GetContainerByIP("10.11.12.13")
+		Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(1))
+
+		time.Sleep(30 * time.Millisecond)
+		client.ListAppContainers("some-app-guid")
+		Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(2))
+	})
+
+	It("does not reuse a listing that came with an error", func() {
+		client.MaxAge = time.Minute
+		listErr = errors.New("potato")
+		close(release)
+
+		_, err := client.ListAppContainers("some-app-guid")
+		Expect(err).To(MatchError("potato"))
+		client.ListAppContainers("some-app-guid")
+		Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(2))
+	})
+
+	It("hands listings the daemon answered without an error to OnListing", func() {
+		recorded := [][]models.Container{}
+		client.OnListing = func(c []models.Container) {
+			recorded = append(recorded, c)
+		}
+		close(release)
+
+		client.ListContainers()
+		listErr = resolver.ErrStale
+		client.ListContainers()
+
+		Expect(recorded).To(Equal([][]models.Container{containers}))
+	})
+
+	It("coalesces lookups of different apps into one listing", func() {
+		results := make(chan []models.Container, 3)
+		for _, app := range []string{"some-app-guid", "some-app-guid", "some-other-app-guid"} {
+			go func(app string) {
+				defer GinkgoRecover()
+				containers, err := client.ListAppContainers(app)
+				Expect(err).NotTo(HaveOccurred())
+				results <- containers
+			}(app)
+		}
+
+		Eventually(fakeDaemonClient.ListContainersCallCount).Should(Equal(1))
+		Consistently(fakeDaemonClient.ListContainersCallCount, 50*time.Millisecond).Should(Equal(1))
+		close(release)
+
+		Eventually(results).Should(HaveLen(3))
+		Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(1))
+	})
+})
@@ -0,0 +1,115 @@
+package resolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/client"
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+)
+
+type httpDoer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// DaemonClient adds lookups by app and IP to the ducati daemon client.  The
+// daemon's GET /containers lists every container and takes no filters, so
+// the lookups filter its response here; the CoalescingClient above it
+// answers them from a shared listing instead.
+type DaemonClient struct {
+	*client.DaemonClient
+
+	baseURL    string
+	httpClient httpDoer
+}
+
+func NewDaemonClient(baseURL string, httpClient httpDoer) *DaemonClient {
+	return &DaemonClient{
+		DaemonClient: client.New(baseURL, httpClient),
+		baseURL:      baseURL,
+		httpClient:   httpClient,
+	}
+}
+
+func (c *DaemonClient) ListAppContainers(appGUID string) ([]models.Container, error) {
+	containers, err := c.list()
+	if err != nil {
+		return nil, err
+	}
+	return filterContainers(containers, func(c models.Container) bool { return c.App == appGUID }), nil
+}
+
+func (c *DaemonClient) GetContainer(id string) (models.Container, bool, error) {
+	var container models.Container
+	found, err := c.get("/containers/"+url.QueryEscape(id), &container)
+	if err != nil || !found {
+		return models.Container{}, false, err
+	}
+	return container, true, nil
+}
+
+func (c *DaemonClient) GetContainerByIP(ip string) (models.Container, bool, error) {
+	containers, err := c.list()
+	if err != nil {
+		return models.Container{}, false, err
+	}
+	container, found := firstContainer(containers, func(c models.Container) bool { return c.IP == ip })
+	return container, found, nil
+}
+
+func (c *DaemonClient) list() ([]models.Container, error) {
+	var containers []models.Container
+	if _, err := c.get("/containers", &containers); err != nil {
+		return nil, err
+	}
+	return containers, nil
+}
+
+// get decodes the JSON body at path into out.  A 404 is reported as not
+// found rather than as an error.
+func (c *DaemonClient) get(path string, out interface{}) (bool, error) {
+	req, err := http.NewRequest("GET", c.baseURL+path, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status code on GET %s: expected 200 but got %d", req.URL.Path, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return false, fmt.Errorf("decode %s: %s", req.URL.Path, err)
+	}
+	return true, nil
+}
+
+func filterContainers(containers []models.Container, match func(models.Container) bool) []models.Container {
+	matched := []models.Container{}
+	for _, c := range containers {
+		if match(c) {
+			matched = append(matched, c)
+		}
+	}
+	return matched
+}
+
+func firstContainer(containers []models.Container, match func(models.Container) bool) (models.Container, bool) {
+	for _, c := range containers {
+		if match(c) {
+			return c, true
+		}
+	}
+	return models.Container{}, false
+}
@@ -0,0 +1,108 @@
+package resolver_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DaemonClient", func() {
+	var (
+		server   *httptest.Server
+		requests []*http.Request
+		status   int
+		body     string
+		client   *resolver.DaemonClient
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		status = http.StatusOK
+		body = `[
+			{"id": "container-1", "app": "some-app-guid", "ip": "10.0.0.1"},
+			{"id": "container-2", "app": "some-other-app-guid", "ip": "10.0.0.2"}
+		]`
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+			w.Header().Set("content-type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}))
+		client = resolver.NewDaemonClient(server.URL, http.DefaultClient)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("ListAppContainers", func() {
+		It("lists every container and keeps the app's", func() {
+			containers, err := client.ListAppContainers("some-app-guid")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containers).To(Equal([]models.Container{
+				{ID: "container-1", App: "some-app-guid", IP: "10.0.0.1"},
+			}))
+
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].URL.RequestURI()).To(Equal("/containers"))
+		})
+
+		It("returns an error on unexpected status codes", func() {
+			status = http.StatusInternalServerError
+
+			_, err := client.ListAppContainers("some-app-guid")
+			Expect(err).To(MatchError("unexpected status code on GET /containers: expected 200 but got 500"))
+		})
+	})
+
+	Describe("GetContainerByIP", func() {
+		It("returns the container with the IP", func() {
+			container, found, err := client.GetContainerByIP("10.0.0.2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(container.ID).To(Equal("container-2"))
+			Expect(requests[0].URL.RequestURI()).To(Equal("/containers"))
+		})
+
+		It("reports when no container has the IP", func() {
+			_, found, err := client.GetContainerByIP("10.0.0.3")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Describe("GetContainer", func() {
+		BeforeEach(func() {
+			body = `{"id": "container-1", "app": "some-app-guid", "ip": "10.0.0.1"}`
+		})
+
+		It("gets the container by ID", func() {
+			container, found, err := client.GetContainer("container-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(container).To(Equal(models.Container{ID: "container-1", App: "some-app-guid", IP: "10.0.0.1"}))
+			Expect(requests[0].URL.Path).To(Equal("/containers/container-1"))
+		})
+
+		It("reports unknown containers as not found", func() {
+			status = http.StatusNotFound
+			body = ""
+
+			_, found, err := client.GetContainer("container-9")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("returns an error when the body is not a container", func() {
+			body = "potato"
+
+			_, _, err := client.GetContainer("container-1")
+			Expect(err).To(MatchError(HavePrefix("decode /containers/container-1:")))
+		})
+	})
+})
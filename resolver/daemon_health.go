@@ -0,0 +1,68 @@
+package resolver
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+)
+
+// DaemonHealth wraps a daemon client and remembers when the daemon last
+// answered, so readiness can be reported without probing on every check.
+type DaemonHealth struct {
+	Client ducatiDaemonClient
+	Window time.Duration
+
+	mutex       sync.Mutex
+	lastSuccess time.Time
+}
+
+func (d *DaemonHealth) ListContainers() ([]models.Container, error) {
+	containers, err := d.Client.ListContainers()
+	d.answered(err)
+	return containers, err
+}
+
+func (d *DaemonHealth) ListAppContainers(appGUID string) ([]models.Container, error) {
+	containers, err := d.Client.ListAppContainers(appGUID)
+	d.answered(err)
+	return containers, err
+}
+
+func (d *DaemonHealth) GetContainer(id string) (models.Container, bool, error) {
+	container, found, err := d.Client.GetContainer(id)
+	d.answered(err)
+	return container, found, err
+}
+
+func (d *DaemonHealth) GetContainerByIP(ip string) (models.Container, bool, error) {
+	container, found, err := d.Client.GetContainerByIP(ip)
+	d.answered(err)
+	return container, found, err
+}
+
+func (d *DaemonHealth) answered(err error) {
+	if err == nil {
+		d.mutex.Lock()
+		d.lastSuccess = time.Now()
+		d.mutex.Unlock()
+	}
+}
+
+// Check succeeds if the daemon answered within Window, and otherwise asks
+// it for the container list.
+func (d *DaemonHealth) Check() error {
+	d.mutex.Lock()
+	recent := time.Since(d.lastSuccess) < d.Window
+	d.mutex.Unlock()
+
+	if recent {
+		return nil
+	}
+
+	if _, err := d.ListContainers(); err != nil {
+		return fmt.Errorf("daemon unreachable: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package resolver_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DaemonHealth", func() {
+	var (
+		daemonHealth     *resolver.DaemonHealth
+		fakeDaemonClient *fakes.DucatiDaemonClient
+	)
+
+	BeforeEach(func() {
+		fakeDaemonClient = &fakes.DucatiDaemonClient{}
+		fakeDaemonClient.ListContainersReturns([]models.Container{{ID: "some-container"}}, nil)
+		daemonHealth = &resolver.DaemonHealth{
+			Client: fakeDaemonClient,
+			Window: time.Minute,
+		}
+	})
+
+	It("passes containers through from the client", func() {
+		containers, err := daemonHealth.ListContainers()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(containers).To(Equal([]models.Container{{ID: "some-container"}}))
+	})
+
+	It("does not probe the daemon when it answered recently", func() {
+		daemonHealth.ListContainers()
+
+		Expect(daemonHealth.Check()).To(Succeed())
+		Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(1))
+	})
+
+	It("counts answers to targeted lookups", func() {
+		daemonHealth.GetContainerByIP("10.11.12.13")
+
+		Expect(daemonHealth.Check()).To(Succeed())
+		Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(0))
+	})
+
+	It("probes the daemon when it has not answered recently", func() {
+		Expect(daemonHealth.Check()).To(Succeed())
+		Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(1))
+	})
+
+	Context("when the daemon is unreachable", func() {
+		BeforeEach(func() {
+			fakeDaemonClient.ListContainersReturns(nil, errors.New("connection refused"))
+		})
+
+		It("fails the check", func() {
+			Expect(daemonHealth.Check()).To(MatchError("daemon unreachable: connection refused"))
+		})
+	})
+})
@@ -0,0 +1,49 @@
+package resolver
+
+import "github.com/pivotal-golang/lager"
+
+// DaemonSource finds instances through the ducati daemon.  Containers the
+// circuit breaker answered from its cache are served as stale, and while the
+// daemon fails, so is the last listing kept in a Snapshot.
+type DaemonSource struct {
+	Logger   lager.Logger
+	Client   ducatiDaemonClient
+	Snapshot *Snapshot
+}
+
+func (s *DaemonSource) Instances(app string) ([]Instance, bool, error) {
+	containers, err := s.Client.ListAppContainers(app)
+	if err == ErrStale {
+		s.Logger.Info("serving-stale", lager.Data{"app": app})
+		return containerInstances(containers), true, nil
+	}
+	if err != nil && s.Snapshot != nil {
+		if snapshot, ok := s.Snapshot.AppContainers(app); ok && len(snapshot) > 0 {
+			s.Logger.Info("serving-snapshot", lager.Data{"app": app, "error": err.Error()})
+			return containerInstances(snapshot), true, nil
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return containerInstances(containers), false, nil
+}
+
+func (s *DaemonSource) InstanceByIP(ip string) (Instance, bool, error) {
+	container, found, err := s.Client.GetContainerByIP(ip)
+	if err == ErrStale {
+		err = nil
+	}
+	if err != nil || !found {
+		return Instance{}, false, err
+	}
+	return containerInstance(container), true, nil
+}
+
+func (s *DaemonSource) AllInstances() ([]Instance, error) {
+	containers, err := s.Client.ListContainers()
+	if err != nil && err != ErrStale {
+		return nil, err
+	}
+	return containerInstances(containers), nil
+}
@@ -0,0 +1,85 @@
+package resolver_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DaemonSource", func() {
+	var (
+		fakeDaemonClient *fakes.DucatiDaemonClient
+		fakeLogger       *lagertest.TestLogger
+		source           *resolver.DaemonSource
+	)
+
+	BeforeEach(func() {
+		fakeDaemonClient = &fakes.DucatiDaemonClient{}
+		fakeLogger = lagertest.NewTestLogger("test")
+		source = &resolver.DaemonSource{
+			Logger: fakeLogger,
+			Client: fakeDaemonClient,
+		}
+	})
+
+	It("returns the app's containers as instances", func() {
+		fakeDaemonClient.ListAppContainersReturns([]models.Container{
+			{ID: "some-container", App: "some-app-guid", IP: "10.0.0.1", HostIP: "10.244.0.2"},
+		}, nil)
+
+		instances, stale, err := source.Instances("some-app-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stale).To(BeFalse())
+		Expect(instances).To(Equal([]resolver.Instance{
+			{App: "some-app-guid", IP: "10.0.0.1", HostIP: "10.244.0.2"},
+		}))
+		Expect(fakeDaemonClient.ListAppContainersArgsForCall(0)).To(Equal("some-app-guid"))
+	})
+
+	It("returns the daemon's errors", func() {
+		fakeDaemonClient.ListAppContainersReturns(nil, errors.New("potato"))
+
+		_, _, err := source.Instances("some-app-guid")
+		Expect(err).To(MatchError("potato"))
+	})
+
+	It("serves containers the circuit breaker answered from its cache as stale", func() {
+		fakeDaemonClient.ListAppContainersReturns([]models.Container{
+			{App: "some-app-guid", IP: "10.0.0.1", HostIP: "10.244.0.2"},
+		}, resolver.ErrStale)
+
+		instances, stale, err := source.Instances("some-app-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stale).To(BeTrue())
+		Expect(instances).To(HaveLen(1))
+		Expect(fakeLogger).To(gbytes.Say("serving-stale"))
+	})
+
+	It("finds instances by IP", func() {
+		fakeDaemonClient.GetContainerByIPReturns(models.Container{App: "some-app-guid", IP: "10.0.0.1", HostIP: "10.244.0.2"}, true, nil)
+
+		instance, found, err := source.InstanceByIP("10.0.0.1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(instance.HostIP).To(Equal("10.244.0.2"))
+		Expect(fakeDaemonClient.GetContainerByIPArgsForCall(0)).To(Equal("10.0.0.1"))
+	})
+
+	It("lists every container as an instance", func() {
+		fakeDaemonClient.ListContainersReturns([]models.Container{
+			{App: "some-app-guid", IP: "10.0.0.1"},
+			{App: "other-app-guid", IP: "10.0.0.2"},
+		}, nil)
+
+		instances, err := source.AllInstances()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,189 @@
+package resolver
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/pivotal-golang/lager"
+)
+
+// errNotFound makes a merging lookup ask the next endpoint.
+var errNotFound = errors.New("container not found")
+
+type DaemonEndpoint struct {
+	Name   string
+	Client ducatiDaemonClient
+}
+
+// FailoverClient sends each request to the preferred endpoint and, when it
+// fails, to the others in turn.  The first endpoint to answer becomes the
+// preferred one, so requests stay with a healthy daemon.  With Merge set,
+// container listings are requested from every endpoint and combined, so
+// that one stale daemon does not hide instances; where endpoints disagree
+// about a container, the preferred endpoint's copy wins.
+type FailoverClient struct {
+	Logger    lager.Logger
+	Endpoints []DaemonEndpoint
+	Merge     bool
+
+	mutex     sync.Mutex
+	preferred int
+}
+
+func (c *FailoverClient) ListContainers() ([]models.Container, error) {
+	return c.list(func(client ducatiDaemonClient) ([]models.Container, error) {
+		return client.ListContainers()
+	})
+}
+
+func (c *FailoverClient) ListAppContainers(appGUID string) ([]models.Container, error) {
+	return c.list(func(client ducatiDaemonClient) ([]models.Container, error) {
+		return client.ListAppContainers(appGUID)
+	})
+}
+
+func (c *FailoverClient) GetContainer(id string) (models.Container, bool, error) {
+	return c.get(func(client ducatiDaemonClient) (models.Container, bool, error) {
+		return client.GetContainer(id)
+	})
+}
+
+func (c *FailoverClient) GetContainerByIP(ip string) (models.Container, bool, error) {
+	return c.get(func(client ducatiDaemonClient) (models.Container, bool, error) {
+		return client.GetContainerByIP(ip)
+	})
+}
+
+// Preferred returns the name of the endpoint requests are sent to first.
+func (c *FailoverClient) Preferred() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.Endpoints[c.preferred].Name
+}
+
+func (c *FailoverClient) list(request func(ducatiDaemonClient) ([]models.Container, error)) ([]models.Container, error) {
+	if !c.Merge {
+		var containers []models.Container
+		err := c.failover(func(client ducatiDaemonClient) (err error) {
+			containers, err = request(client)
+			return err
+		})
+		return containers, err
+	}
+
+	results := make([][]models.Container, len(c.Endpoints))
+	errs := make([]error, len(c.Endpoints))
+	wg := sync.WaitGroup{}
+	for i, endpoint := range c.Endpoints {
+		wg.Add(1)
+		go func(i int, endpoint DaemonEndpoint) {
+			defer wg.Done()
+			results[i], errs[i] = request(endpoint.Client)
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	c.mutex.Lock()
+	start := c.preferred
+	c.mutex.Unlock()
+
+	var err error
+	answered := false
+	merged := []models.Container{}
+	seen := map[string]bool{}
+	for i := range c.Endpoints {
+		index := (start + i) % len(c.Endpoints)
+		if errs[index] != nil {
+			err = errs[index]
+			c.Logger.Error("daemon-request-failed", err, lager.Data{"endpoint": c.Endpoints[index].Name})
+			continue
+		}
+		if !answered && i > 0 {
+			c.prefer(start, index)
+		}
+		answered = true
+		for _, container := range results[index] {
+			key := container.ID
+			if key == "" {
+				key = container.IP
+			}
+			if !seen[key] {
+				seen[key] = true
+				merged = append(merged, container)
+			}
+		}
+	}
+
+	if !answered {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// get asks the endpoints in turn.  Without Merge the first answer is
+// final; with Merge the next endpoint is asked when one does not know the
+// container.
+func (c *FailoverClient) get(request func(ducatiDaemonClient) (models.Container, bool, error)) (models.Container, bool, error) {
+	var container models.Container
+	var found bool
+	err := c.failover(func(client ducatiDaemonClient) (err error) {
+		container, found, err = request(client)
+		if err == nil && !found && c.Merge {
+			return errNotFound
+		}
+		return err
+	})
+	if err == errNotFound {
+		return models.Container{}, false, nil
+	}
+	return container, found, err
+}
+
+func (c *FailoverClient) failover(request func(ducatiDaemonClient) error) error {
+	c.mutex.Lock()
+	start := c.preferred
+	c.mutex.Unlock()
+
+	var err error
+	preferredFailed, notFound := false, false
+	for i := range c.Endpoints {
+		index := (start + i) % len(c.Endpoints)
+		endpoint := c.Endpoints[index]
+
+		err = request(endpoint.Client)
+		if err == errNotFound {
+			notFound = true
+			continue
+		}
+		if err != nil {
+			c.Logger.Error("daemon-request-failed", err, lager.Data{"endpoint": endpoint.Name})
+			preferredFailed = preferredFailed || i == 0
+			continue
+		}
+
+		if preferredFailed {
+			c.prefer(start, index)
+		}
+		return nil
+	}
+
+	if notFound {
+		return errNotFound
+	}
+	return err
+}
+
+func (c *FailoverClient) prefer(from, to int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.preferred != from {
+		return
+	}
+	c.preferred = to
+	c.Logger.Info("daemon-failover", lager.Data{
+		"from": c.Endpoints[from].Name,
+		"to":   c.Endpoints[to].Name,
+	})
+}
@@ -0,0 +1,156 @@
+package resolver_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FailoverClient", func() {
+	var (
+		first, second *fakes.DucatiDaemonClient
+		logger        *lagertest.TestLogger
+		client        *resolver.FailoverClient
+	)
+
+	BeforeEach(func() {
+		first = &fakes.DucatiDaemonClient{}
+		first.ListContainersReturns([]models.Container{{ID: "container-1", IP: "10.0.0.1"}}, nil)
+		second = &fakes.DucatiDaemonClient{}
+		second.ListContainersReturns([]models.Container{{ID: "container-2", IP: "10.0.0.2"}}, nil)
+		logger = lagertest.NewTestLogger("test")
+		client = &resolver.FailoverClient{
+			Logger: logger,
+			Endpoints: []resolver.DaemonEndpoint{
+				{Name: "daemon-1", Client: first},
+				{Name: "daemon-2", Client: second},
+			},
+		}
+	})
+
+	It("asks the first endpoint", func() {
+		Expect(client.ListContainers()).To(Equal([]models.Container{{ID: "container-1", IP: "10.0.0.1"}}))
+		Expect(second.ListContainersCallCount()).To(Equal(0))
+		Expect(client.Preferred()).To(Equal("daemon-1"))
+	})
+
+	Context("when the preferred endpoint fails", func() {
+		BeforeEach(func() {
+			first.ListContainersReturns(nil, errors.New("potato"))
+		})
+
+		It("fails over to the next one and logs it", func() {
+			Expect(client.ListContainers()).To(Equal([]models.Container{{ID: "container-2", IP: "10.0.0.2"}}))
+			Expect(logger).To(gbytes.Say("daemon-request-failed.*daemon-1.*potato"))
+			Expect(logger).To(gbytes.Say("daemon-failover.*from.*daemon-1.*to.*daemon-2"))
+		})
+
+		It("stays with the endpoint that answered", func() {
+			client.ListContainers()
+			first.ListContainersReturns([]models.Container{}, nil)
+
+			client.ListContainers()
+			Expect(first.ListContainersCallCount()).To(Equal(1))
+			Expect(second.ListContainersCallCount()).To(Equal(2))
+			Expect(client.Preferred()).To(Equal("daemon-2"))
+		})
+
+		It("returns the last error when every endpoint fails", func() {
+			second.ListContainersReturns(nil, errors.New("tomato"))
+
+			_, err := client.ListContainers()
+			Expect(err).To(MatchError("tomato"))
+			Expect(client.Preferred()).To(Equal("daemon-1"))
+		})
+	})
+
+	It("treats not found as an answer", func() {
+		first.GetContainerByIPReturns(models.Container{}, false, nil)
+
+		_, found, err := client.GetContainerByIP("10.0.0.2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeFalse())
+		Expect(second.GetContainerByIPCallCount()).To(Equal(0))
+	})
+
+	Context("when merging", func() {
+		BeforeEach(func() {
+			client.Merge = true
+		})
+
+		It("combines the listings of every endpoint", func() {
+			second.ListAppContainersReturns([]models.Container{
+				{ID: "container-1", IP: "10.0.0.1"},
+				{ID: "container-2", IP: "10.0.0.2"},
+			}, nil)
+			first.ListAppContainersReturns([]models.Container{{ID: "container-1", IP: "10.0.0.1"}}, nil)
+
+			Expect(client.ListAppContainers("some-app-guid")).To(Equal([]models.Container{
+				{ID: "container-1", IP: "10.0.0.1"},
+				{ID: "container-2", IP: "10.0.0.2"},
+			}))
+			Expect(first.ListAppContainersArgsForCall(0)).To(Equal("some-app-guid"))
+			Expect(second.ListAppContainersArgsForCall(0)).To(Equal("some-app-guid"))
+		})
+
+		It("ignores endpoints that fail", func() {
+			first.ListContainersReturns(nil, errors.New("potato"))
+
+			Expect(client.ListContainers()).To(Equal([]models.Container{{ID: "container-2", IP: "10.0.0.2"}}))
+			Expect(logger).To(gbytes.Say("daemon-request-failed.*daemon-1.*potato"))
+		})
+
+		It("keeps the preferred endpoint's copy of a container both list", func() {
+			first.ListContainersReturns([]models.Container{{ID: "container-1", IP: "10.0.0.1", App: "old-app"}}, nil)
+			second.ListContainersReturns([]models.Container{{ID: "container-1", IP: "10.0.0.1", App: "new-app"}}, nil)
+
+			Expect(client.ListContainers()).To(Equal([]models.Container{{ID: "container-1", IP: "10.0.0.1", App: "old-app"}}))
+
+			first.GetContainerReturns(models.Container{}, false, errors.New("potato"))
+			second.GetContainerReturns(models.Container{ID: "container-1"}, true, nil)
+			client.GetContainer("container-1")
+			Expect(client.Preferred()).To(Equal("daemon-2"))
+
+			Expect(client.ListContainers()).To(Equal([]models.Container{{ID: "container-1", IP: "10.0.0.1", App: "new-app"}}))
+		})
+
+		It("prefers the first endpoint to answer when the preferred one fails", func() {
+			first.ListContainersReturns(nil, errors.New("potato"))
+
+			client.ListContainers()
+			Expect(client.Preferred()).To(Equal("daemon-2"))
+			Expect(logger).To(gbytes.Say("daemon-failover.*from.*daemon-1.*to.*daemon-2"))
+		})
+
+		It("fails when every endpoint fails", func() {
+			first.ListContainersReturns(nil, errors.New("potato"))
+			second.ListContainersReturns(nil, errors.New("potato"))
+
+			_, err := client.ListContainers()
+			Expect(err).To(MatchError("potato"))
+		})
+
+		It("asks the next endpoint for a container the first does not know", func() {
+			second.GetContainerReturns(models.Container{ID: "container-2"}, true, nil)
+
+			container, found, err := client.GetContainer("container-2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(container.ID).To(Equal("container-2"))
+			Expect(client.Preferred()).To(Equal("daemon-1"))
+		})
+
+		It("reports not found when no endpoint knows the container", func() {
+			_, found, err := client.GetContainer("container-3")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,104 @@
+package resolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+)
+
+type RecordsFileConfig struct {
+	Path         string        `yaml:"path"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+// FileSource serves the instances listed in a JSON file at Path, such as
+// [{"app": "my-app", "ip": "10.0.0.5", "host_ip": "10.244.0.2"}].  The file
+// is reloaded when it changes.
+type FileSource struct {
+	Logger       lager.Logger
+	Path         string
+	PollInterval time.Duration
+
+	mutex     sync.RWMutex
+	instances []Instance
+	file      watchedFile
+}
+
+// Load replaces the served instances with the contents of Path.  If the
+// file cannot be read or is invalid, the previously loaded instances are
+// kept.
+func (s *FileSource) Load() error {
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return fmt.Errorf("stat records file: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return fmt.Errorf("read records file: %s", err)
+	}
+
+	var instances []Instance
+	if err := json.Unmarshal(data, &instances); err != nil {
+		return fmt.Errorf("parse records file: %s", err)
+	}
+	for i, instance := range instances {
+		if instance.App == "" {
+			return fmt.Errorf("parse records file: entry %d: missing app", i)
+		}
+		if net.ParseIP(instance.IP) == nil {
+			return fmt.Errorf("parse records file: entry %d: invalid ip %q", i, instance.IP)
+		}
+	}
+
+	s.mutex.Lock()
+	s.instances = instances
+	s.mutex.Unlock()
+	s.file.loaded(info)
+
+	s.Logger.Info("records-file-loaded", lager.Data{"path": s.Path, "instances": len(instances)})
+
+	return nil
+}
+
+func (s *FileSource) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	return s.file.poll(s.Logger, "records-file", s.Path, s.PollInterval, s.Load, signals, ready)
+}
+
+func (s *FileSource) Instances(app string) ([]Instance, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	instances := []Instance{}
+	for _, instance := range s.instances {
+		if instance.App == app {
+			instances = append(instances, instance)
+		}
+	}
+	return instances, false, nil
+}
+
+func (s *FileSource) InstanceByIP(ip string) (Instance, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, instance := range s.instances {
+		if instance.IP == ip {
+			return instance, true, nil
+		}
+	}
+	return Instance{}, false, nil
+}
+
+func (s *FileSource) AllInstances() ([]Instance, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return append([]Instance{}, s.instances...), nil
+}
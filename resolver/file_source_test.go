@@ -0,0 +1,129 @@
+package resolver_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("FileSource", func() {
+	var (
+		source      *resolver.FileSource
+		fakeLogger  *lagertest.TestLogger
+		tempDir     string
+		recordsPath string
+	)
+
+	writeRecords := func(contents string) {
+		Expect(ioutil.WriteFile(recordsPath, []byte(contents), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "records-file")
+		Expect(err).NotTo(HaveOccurred())
+		recordsPath = filepath.Join(tempDir, "records.json")
+
+		writeRecords(`[
+			{"app": "some-app-guid", "ip": "10.0.0.5", "host_ip": "10.244.0.2"},
+			{"app": "some-app-guid", "ip": "10.0.0.6", "host_ip": "10.244.0.3"},
+			{"app": "other-app-guid", "ip": "10.0.0.7"}
+		]`)
+
+		fakeLogger = lagertest.NewTestLogger("test")
+		source = &resolver.FileSource{
+			Logger:       fakeLogger,
+			Path:         recordsPath,
+			PollInterval: 10 * time.Millisecond,
+		}
+		Expect(source.Load()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	It("returns the app's instances", func() {
+		instances, stale, err := source.Instances("some-app-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stale).To(BeFalse())
+		Expect(instances).To(Equal([]resolver.Instance{
+			{App: "some-app-guid", IP: "10.0.0.5", HostIP: "10.244.0.2"},
+			{App: "some-app-guid", IP: "10.0.0.6", HostIP: "10.244.0.3"},
+		}))
+	})
+
+	It("returns no instances for unknown apps", func() {
+		instances, _, err := source.Instances("unknown-app-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(BeEmpty())
+	})
+
+	It("finds instances by IP", func() {
+		instance, found, err := source.InstanceByIP("10.0.0.7")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(instance).To(Equal(resolver.Instance{App: "other-app-guid", IP: "10.0.0.7"}))
+
+		_, found, err = source.InstanceByIP("10.0.0.8")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeFalse())
+	})
+
+	It("lists every instance", func() {
+		instances, err := source.AllInstances()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(HaveLen(3))
+	})
+
+	It("rejects invalid entries and keeps the loaded instances", func() {
+		writeRecords(`[{"app": "some-app-guid", "ip": "potato"}]`)
+		Expect(source.Load()).To(MatchError(`parse records file: entry 0: invalid ip "potato"`))
+
+		writeRecords(`[{"ip": "10.0.0.5"}]`)
+		Expect(source.Load()).To(MatchError("parse records file: entry 0: missing app"))
+
+		instances, err := source.AllInstances()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(HaveLen(3))
+	})
+
+	Context("when running", func() {
+		var process ifrit.Process
+
+		BeforeEach(func() {
+			process = ifrit.Invoke(source)
+		})
+
+		AfterEach(func() {
+			ginkgomon.Interrupt(process)
+		})
+
+		It("reloads the instances when the file changes", func() {
+			writeRecords(`[{"app": "new-app-guid", "ip": "10.0.0.9"}]`)
+
+			Eventually(func() []resolver.Instance {
+				instances, _, _ := source.Instances("new-app-guid")
+				return instances
+			}).Should(HaveLen(1))
+		})
+
+		It("logs invalid files and keeps serving the previous instances", func() {
+			writeRecords("not json")
+
+			Eventually(fakeLogger).Should(gbytes.Say("records-file-reload-failed"))
+			instances, _, _ := source.Instances("some-app-guid")
+			Expect(instances).To(HaveLen(2))
+		})
+	})
+})
@@ -1,6 +1,7 @@
 package resolver
 
 import (
+	"net"
 	"time"
 
 	"github.com/miekg/dns"
@@ -22,10 +23,27 @@ type exchanger interface {
 	Exchange(m *dns.Msg, a string) (r *dns.Msg, rtt time.Duration, err error)
 }
 
+// TCPFallback exchanges messages over UDP and asks the same server again
+// over TCP when the answer was truncated, as DNSSEC answers often are.
+type TCPFallback struct {
+	UDP exchanger
+	TCP exchanger
+}
+
+func (e *TCPFallback) Exchange(m *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+	resp, rtt, err := e.UDP.Exchange(m, server)
+	if err != nil || resp == nil || !resp.Truncated {
+		return resp, rtt, err
+	}
+	return e.TCP.Exchange(m, server)
+}
+
 type ForwardingResolver struct {
 	Logger    lager.Logger
 	Exchanger exchanger
 	Server    string
+	Fallbacks []string
+	Validator *Validator
 }
 
 func (h *ForwardingResolver) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
@@ -33,7 +51,19 @@ func (h *ForwardingResolver) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
 	logger.Info("resolving")
 	defer logger.Info("resolve-complete")
 
-	resp, _, err := h.Exchanger.Exchange(request, h.Server)
+	validate := h.Validator != nil && !request.CheckingDisabled
+
+	forwarded := request
+	if validate {
+		forwarded = request.Copy()
+		if opt := forwarded.IsEdns0(); opt != nil {
+			opt.SetDo()
+		} else {
+			forwarded.SetEdns0(4096, true)
+		}
+	}
+
+	resp, server, err := h.exchange(logger, forwarded)
 	if err != nil {
 		h.Logger.Error("exchange-failed", err)
 
@@ -53,7 +83,100 @@ func (h *ForwardingResolver) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
 		return
 	}
 
+	if validate {
+		secure, err := h.Validator.Validate(request.Question[0], resp, server)
+		if err != nil {
+			logger.Error("validation-failed", err)
+
+			m := &dns.Msg{}
+			m.SetRcode(request, dns.RcodeServerFailure)
+			w.WriteMsg(m)
+			return
+		}
+		resp.AuthenticatedData = secure
+		stripDNSSEC(request, resp)
+	}
+
 	logger.Info("response", lager.Data{"answer": resp.Answer})
 
+	truncateForClient(w, request, resp)
 	w.WriteMsg(resp)
 }
+
+// truncateForClient empties an answer that does not fit the UDP client's
+// buffer and sets TC, so that the client asks again over TCP.
+func truncateForClient(w dns.ResponseWriter, request, resp *dns.Msg) {
+	if _, udp := w.RemoteAddr().(*net.UDPAddr); !udp {
+		return
+	}
+
+	size := dns.MinMsgSize
+	if opt := request.IsEdns0(); opt != nil && int(opt.UDPSize()) > size {
+		size = int(opt.UDPSize())
+	}
+	if resp.Len() <= size {
+		return
+	}
+
+	resp.Truncated = true
+	resp.Answer, resp.Ns = nil, nil
+	extra := []dns.RR{}
+	for _, rr := range resp.Extra {
+		if rr.Header().Rrtype == dns.TypeOPT {
+			extra = append(extra, rr)
+		}
+	}
+	resp.Extra = extra
+}
+
+// exchange tries Server and then each of Fallbacks until one answers, and
+// returns the answer along with the server that gave it.
+func (h *ForwardingResolver) exchange(logger lager.Logger, m *dns.Msg) (*dns.Msg, string, error) {
+	servers := append([]string{h.Server}, h.Fallbacks...)
+
+	var err error
+	for i, server := range servers {
+		var resp *dns.Msg
+		resp, _, err = h.Exchanger.Exchange(m, server)
+		if err == nil {
+			return resp, server, nil
+		}
+		if i < len(servers)-1 {
+			logger.Info("trying-next-upstream", lager.Data{"server": server, "error": err.Error()})
+		}
+	}
+	return nil, "", err
+}
+
+// stripDNSSEC removes the records added by setting the DO bit on behalf of
+// a client that did not ask for them.
+func stripDNSSEC(request, resp *dns.Msg) {
+	opt := request.IsEdns0()
+	if opt != nil && opt.Do() {
+		return
+	}
+
+	qtype := request.Question[0].Qtype
+	keep := func(rrs []dns.RR) []dns.RR {
+		kept := []dns.RR{}
+		for _, rr := range rrs {
+			switch t := rr.Header().Rrtype; t {
+			case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3:
+				if t != qtype {
+					continue
+				}
+			case dns.TypeOPT:
+				if opt == nil {
+					continue
+				}
+				rr.(*dns.OPT).SetDo(false)
+			}
+			kept = append(kept, rr)
+		}
+		return kept
+	}
+
+	resp.Answer = keep(resp.Answer)
+	resp.Ns = keep(resp.Ns)
+	resp.Extra = keep(resp.Extra)
+}
@@ -2,6 +2,7 @@ package resolver_test
 
 import (
 	"errors"
+	"net"
 	"time"
 
 	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
@@ -81,4 +82,187 @@ var _ = Describe("ForwardingResolver", func() {
 			Expect(responseWriter.WriteMsgArgsForCall(0).MsgHdr.Rcode).To(Equal(dns.RcodeServerFailure))
 		})
 	})
+
+	Context("when fallback servers are configured", func() {
+		BeforeEach(func() {
+			forwardingResolver.Fallbacks = []string{"5.6.7.8:53"}
+			fakeExchanger.ExchangeStub = func(request *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+				if server == "1.2.3.4:53" {
+					return nil, 0, errors.New("potato")
+				}
+				resp := &dns.Msg{}
+				resp.SetReply(request)
+				return resp, time.Millisecond, nil
+			}
+		})
+
+		It("tries them in turn when the server fails", func() {
+			forwardingResolver.ServeDNS(responseWriter, request)
+
+			Expect(fakeExchanger.ExchangeCallCount()).To(Equal(2))
+			_, address := fakeExchanger.ExchangeArgsForCall(1)
+			Expect(address).To(Equal("5.6.7.8:53"))
+
+			Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeSuccess))
+			Expect(fakeLogger).To(gbytes.Say("trying-next-upstream.*potato.*1.2.3.4:53"))
+		})
+	})
+
+	Context("when a validator is configured", func() {
+		var zone *signedZone
+
+		BeforeEach(func() {
+			zone = newSignedZone("cloudfoundry.org.")
+			fakeExchanger.ExchangeStub = func(m *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+				resp := &dns.Msg{}
+				resp.SetReply(m)
+				switch m.Question[0].Qtype {
+				case dns.TypeDNSKEY:
+					resp.Answer = zone.sign(zone.key)
+				case dns.TypeA:
+					resp.Answer = zone.sign(testRR("cloudfoundry.org. 60 IN A 10.0.0.1"))
+				}
+				return resp, time.Millisecond, nil
+			}
+			forwardingResolver.Validator = &resolver.Validator{
+				Logger:       fakeLogger,
+				Exchanger:    fakeExchanger,
+				TrustAnchors: []*dns.DS{zone.ds()},
+			}
+		})
+
+		It("fetches the keys from the server that answered", func() {
+			forwardingResolver.Fallbacks = []string{"5.6.7.8:53"}
+			answer := fakeExchanger.ExchangeStub
+			fakeExchanger.ExchangeStub = func(m *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+				if server == "1.2.3.4:53" {
+					return nil, 0, errors.New("potato")
+				}
+				return answer(m, server)
+			}
+
+			forwardingResolver.ServeDNS(responseWriter, request)
+
+			Expect(responseWriter.WriteMsgArgsForCall(0).AuthenticatedData).To(BeTrue())
+			msg, server := fakeExchanger.ExchangeArgsForCall(2)
+			Expect(msg.Question[0].Qtype).To(Equal(dns.TypeDNSKEY))
+			Expect(server).To(Equal("5.6.7.8:53"))
+		})
+
+		It("asks the server for DNSSEC records", func() {
+			forwardingResolver.ServeDNS(responseWriter, request)
+
+			msg, _ := fakeExchanger.ExchangeArgsForCall(0)
+			Expect(msg.IsEdns0()).NotTo(BeNil())
+			Expect(msg.IsEdns0().Do()).To(BeTrue())
+			Expect(request.IsEdns0()).To(BeNil())
+		})
+
+		It("sets the AD bit on secure answers and strips the signatures", func() {
+			forwardingResolver.ServeDNS(responseWriter, request)
+
+			response := responseWriter.WriteMsgArgsForCall(0)
+			Expect(response.AuthenticatedData).To(BeTrue())
+			Expect(response.Answer).To(HaveLen(1))
+			Expect(response.IsEdns0()).To(BeNil())
+		})
+
+		It("keeps the signatures for clients that set the DO bit", func() {
+			request.SetEdns0(4096, true)
+			forwardingResolver.ServeDNS(responseWriter, request)
+
+			response := responseWriter.WriteMsgArgsForCall(0)
+			Expect(response.AuthenticatedData).To(BeTrue())
+			Expect(response.Answer).To(HaveLen(2))
+		})
+
+		Context("when the answer is bogus", func() {
+			BeforeEach(func() {
+				forwardingResolver.Validator.TrustAnchors = []*dns.DS{newSignedZone("cloudfoundry.org.").ds()}
+			})
+
+			It("responds with SERVFAIL", func() {
+				forwardingResolver.ServeDNS(responseWriter, request)
+
+				Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeServerFailure))
+				Expect(fakeLogger).To(gbytes.Say("validation-failed"))
+			})
+
+			It("passes the answer through when the client disables checking", func() {
+				request.CheckingDisabled = true
+				forwardingResolver.ServeDNS(responseWriter, request)
+
+				response := responseWriter.WriteMsgArgsForCall(0)
+				Expect(response.Rcode).To(Equal(dns.RcodeSuccess))
+				Expect(response.AuthenticatedData).To(BeFalse())
+			})
+		})
+	})
+
+	It("truncates answers too large for the UDP client's buffer", func() {
+		fakeExchanger.ExchangeStub = func(request *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+			resp := &dns.Msg{}
+			resp.SetReply(request)
+			for i := 0; i < 40; i++ {
+				resp.Answer = append(resp.Answer, &dns.A{
+					Hdr: dns.RR_Header{Name: "cloudfoundry.org.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+					A:   net.IPv4(10, 0, 0, byte(i)),
+				})
+			}
+			return resp, time.Millisecond, nil
+		}
+		responseWriter.RemoteAddrReturns(&net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5353})
+
+		forwardingResolver.ServeDNS(responseWriter, request)
+
+		response := responseWriter.WriteMsgArgsForCall(0)
+		Expect(response.Truncated).To(BeTrue())
+		Expect(response.Answer).To(BeEmpty())
+	})
+})
+
+var _ = Describe("TCPFallback", func() {
+	var (
+		udp, tcp *fakes.Exchanger
+		fallback *resolver.TCPFallback
+		request  *dns.Msg
+	)
+
+	BeforeEach(func() {
+		udp = &fakes.Exchanger{}
+		tcp = &fakes.Exchanger{}
+		fallback = &resolver.TCPFallback{UDP: udp, TCP: tcp}
+		request = &dns.Msg{}
+		request.SetQuestion("example.", dns.TypeDNSKEY)
+	})
+
+	It("answers over UDP when the answer fits", func() {
+		udp.ExchangeReturns(&dns.Msg{}, time.Millisecond, nil)
+
+		_, _, err := fallback.Exchange(request, "1.2.3.4:53")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tcp.ExchangeCallCount()).To(Equal(0))
+	})
+
+	It("asks the same server again over TCP when the answer was truncated", func() {
+		udp.ExchangeReturns(&dns.Msg{MsgHdr: dns.MsgHdr{Truncated: true}}, time.Millisecond, nil)
+		full := &dns.Msg{Answer: []dns.RR{testRR("example. 60 IN A 10.0.0.1")}}
+		tcp.ExchangeReturns(full, time.Millisecond, nil)
+
+		resp, _, err := fallback.Exchange(request, "1.2.3.4:53")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp).To(Equal(full))
+
+		m, server := tcp.ExchangeArgsForCall(0)
+		Expect(m).To(Equal(request))
+		Expect(server).To(Equal("1.2.3.4:53"))
+	})
+
+	It("does not retry failed exchanges over TCP", func() {
+		udp.ExchangeReturns(nil, 0, errors.New("potato"))
+
+		_, _, err := fallback.Exchange(request, "1.2.3.4:53")
+		Expect(err).To(MatchError("potato"))
+		Expect(tcp.ExchangeCallCount()).To(Equal(0))
+	})
 })
@@ -0,0 +1,32 @@
+package resolver
+
+import (
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+)
+
+type storedHandler struct {
+	dns.Handler
+}
+
+// HandlerSwitch serves each query with the most recently stored handler.
+// Queries already in progress finish on the handler they started with.
+type HandlerSwitch struct {
+	handler atomic.Value
+}
+
+func (s *HandlerSwitch) Store(h dns.Handler) {
+	s.handler.Store(storedHandler{h})
+}
+
+func (s *HandlerSwitch) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
+	stored, ok := s.handler.Load().(storedHandler)
+	if !ok {
+		m := &dns.Msg{}
+		m.SetRcode(request, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+		return
+	}
+	stored.ServeDNS(w, request)
+}
@@ -0,0 +1,61 @@
+package resolver_test
+
+import (
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/miekg/dns"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HandlerSwitch", func() {
+	var (
+		handlerSwitch  *resolver.HandlerSwitch
+		responseWriter *fakes.ResponseWriter
+		request        *dns.Msg
+	)
+
+	BeforeEach(func() {
+		handlerSwitch = &resolver.HandlerSwitch{}
+		responseWriter = &fakes.ResponseWriter{}
+		request = &dns.Msg{}
+		request.SetQuestion("app.potato.", dns.TypeA)
+	})
+
+	It("serves queries with the stored handler", func() {
+		first := &fakes.Handler{}
+		handlerSwitch.Store(first)
+		handlerSwitch.ServeDNS(responseWriter, request)
+
+		Expect(first.ServeDNSCallCount()).To(Equal(1))
+		w, r := first.ServeDNSArgsForCall(0)
+		Expect(w).To(Equal(responseWriter))
+		Expect(r).To(Equal(request))
+	})
+
+	It("lets queries in progress finish on the previous handler", func() {
+		second := &fakes.Handler{}
+		first := &fakes.Handler{}
+		first.ServeDNSStub = func(w dns.ResponseWriter, r *dns.Msg) {
+			handlerSwitch.Store(second)
+			w.WriteMsg(r)
+		}
+
+		handlerSwitch.Store(first)
+		handlerSwitch.ServeDNS(responseWriter, request)
+		Expect(responseWriter.WriteMsgCallCount()).To(Equal(1))
+		Expect(second.ServeDNSCallCount()).To(Equal(0))
+
+		handlerSwitch.ServeDNS(responseWriter, request)
+		Expect(first.ServeDNSCallCount()).To(Equal(1))
+		Expect(second.ServeDNSCallCount()).To(Equal(1))
+	})
+
+	It("answers SERVFAIL before a handler is stored", func() {
+		handlerSwitch.ServeDNS(responseWriter, request)
+
+		Expect(responseWriter.WriteMsgCallCount()).To(Equal(1))
+		Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeServerFailure))
+	})
+})
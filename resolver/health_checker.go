@@ -0,0 +1,288 @@
+package resolver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/metrics"
+	"github.com/pivotal-golang/lager"
+)
+
+//go:generate counterfeiter -o ../fakes/health_checker.go --fake-name HealthChecker . healthChecker
+type healthChecker interface {
+	Healthy(instances []Instance) []Instance
+}
+
+//go:generate counterfeiter -o ../fakes/prober.go --fake-name Prober . prober
+type prober interface {
+	Probe(address string) error
+}
+
+const healthCheckQueueSize = 1024
+
+type HealthCheckConfig struct {
+	Type     string        `yaml:"type"`
+	Port     int           `yaml:"port"`
+	Path     string        `yaml:"path"`
+	Timeout  time.Duration `yaml:"timeout"`
+	Interval time.Duration `yaml:"interval"`
+	Workers  int           `yaml:"workers"`
+}
+
+func NewHealthChecker(logger lager.Logger, config HealthCheckConfig) *HealthChecker {
+	var p prober = &TCPProber{Timeout: config.Timeout}
+	if config.Type == "http" {
+		p = &HTTPProber{
+			Client: &http.Client{Timeout: config.Timeout},
+			Path:   config.Path,
+		}
+	}
+
+	return &HealthChecker{
+		Logger:   logger.Session("health-checker"),
+		Prober:   p,
+		Port:     config.Port,
+		Workers:  config.Workers,
+		CacheTTL: config.Interval,
+	}
+}
+
+type TCPProber struct {
+	Timeout time.Duration
+}
+
+func (p *TCPProber) Probe(address string) error {
+	conn, err := net.DialTimeout("tcp", address, p.Timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+type HTTPProber struct {
+	Client *http.Client
+	Path   string
+}
+
+func (p *HTTPProber) Probe(address string) error {
+	resp, err := p.Client.Get("http://" + address + p.Path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 399 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+type healthResult struct {
+	healthy   bool
+	checkedAt time.Time
+}
+
+// HealthChecker answers from the results of earlier probes, which Workers
+// goroutines started by Run refresh in the background.
+type HealthChecker struct {
+	Logger   lager.Logger
+	Prober   prober
+	Port     int
+	Workers  int
+	CacheTTL time.Duration
+	Metrics  *metrics.Metrics
+
+	mutex   sync.Mutex
+	cache   map[string]healthResult
+	pending map[string]bool
+	queue   chan string
+}
+
+// Healthy returns the instances that are not known to be unhealthy.
+// Instances without a result, or whose result is older than CacheTTL, are
+// queued for a probe and answered from what is known until it completes.
+func (h *HealthChecker) Healthy(instances []Instance) []Instance {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.init()
+
+	now := time.Now()
+	healthy := []Instance{}
+	for _, instance := range instances {
+		address := net.JoinHostPort(instance.IP, strconv.Itoa(h.Port))
+
+		result, ok := h.cache[address]
+		if ok && now.Sub(result.checkedAt) < h.CacheTTL {
+			h.Metrics.CacheHit("health_check")
+		} else {
+			h.Metrics.CacheMiss("health_check")
+			h.enqueue(address)
+		}
+
+		if !ok || result.healthy {
+			healthy = append(healthy, instance)
+		}
+	}
+
+	return healthy
+}
+
+func (h *HealthChecker) init() {
+	if h.cache == nil {
+		h.cache = map[string]healthResult{}
+	}
+	if h.pending == nil {
+		h.pending = map[string]bool{}
+	}
+	if h.queue == nil {
+		h.queue = make(chan string, healthCheckQueueSize)
+	}
+}
+
+// enqueue queues a probe of address unless one is already queued.  When
+// the queue is full the address is left for a later lookup to queue.
+func (h *HealthChecker) enqueue(address string) {
+	if h.pending[address] {
+		return
+	}
+
+	select {
+	case h.queue <- address:
+		h.pending[address] = true
+	default:
+		h.Logger.Info("health-check-queue-full", lager.Data{"address": address})
+	}
+}
+
+func (h *HealthChecker) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	h.mutex.Lock()
+	h.init()
+	queue := h.queue
+	h.mutex.Unlock()
+
+	workers := h.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				case address := <-queue:
+					h.probe(address)
+				}
+			}
+		}()
+	}
+
+	close(ready)
+	<-signals
+
+	close(done)
+	wg.Wait()
+	return nil
+}
+
+func (h *HealthChecker) probe(address string) {
+	err := h.Prober.Probe(address)
+	if err != nil {
+		h.Logger.Info("instance-unhealthy", lager.Data{"address": address, "error": err.Error()})
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// The address was flushed or is gone while it was being probed.
+	if !h.pending[address] {
+		return
+	}
+	h.cache[address] = healthResult{healthy: err == nil, checkedAt: time.Now()}
+	delete(h.pending, address)
+}
+
+// Retain drops the results and pending probes of every address that does
+// not belong to one of instances, the overlay's current instances, so that
+// instances that are gone do not stay in the cache.
+func (h *HealthChecker) Retain(instances []Instance) {
+	current := map[string]bool{}
+	for _, instance := range instances {
+		current[net.JoinHostPort(instance.IP, strconv.Itoa(h.Port))] = true
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for address := range h.cache {
+		if !current[address] {
+			delete(h.cache, address)
+		}
+	}
+	for address := range h.pending {
+		if !current[address] {
+			delete(h.pending, address)
+		}
+	}
+}
+
+func (h *HealthChecker) Entries() []CacheEntry {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entries := []CacheEntry{}
+	for address, result := range h.cache {
+		value := "unhealthy"
+		if result.healthy {
+			value = "healthy"
+		}
+		entries = append(entries, CacheEntry{Key: address, Value: value, Expires: result.checkedAt.Add(h.CacheTTL)})
+	}
+	sort.Sort(byKey(entries))
+	return entries
+}
+
+// Flush drops the cached result and any pending probe for an instance
+// address or IP, or every one when name is empty, so that the next lookup
+// probes again.
+func (h *HealthChecker) Flush(name string) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	matches := func(address string) bool {
+		host, _, _ := net.SplitHostPort(address)
+		return name == "" || name == address || name == host
+	}
+
+	flushed := 0
+	for address := range h.cache {
+		if matches(address) {
+			delete(h.cache, address)
+			flushed++
+		}
+	}
+	for address := range h.pending {
+		if matches(address) {
+			delete(h.pending, address)
+		}
+	}
+	return flushed
+}
+
+type byKey []CacheEntry
+
+func (b byKey) Len() int           { return len(b) }
+func (b byKey) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byKey) Less(i, j int) bool { return b[i].Key < b[j].Key }
@@ -0,0 +1,265 @@
+package resolver_test
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("HealthChecker", func() {
+	var (
+		healthChecker *resolver.HealthChecker
+		fakeProber    *fakes.Prober
+		fakeLogger    *lagertest.TestLogger
+		instances     []resolver.Instance
+	)
+
+	BeforeEach(func() {
+		fakeLogger = lagertest.NewTestLogger("test")
+		fakeProber = &fakes.Prober{}
+		fakeProber.ProbeStub = func(address string) error {
+			if strings.HasPrefix(address, "10.0.0.2:") {
+				return errors.New("connection refused")
+			}
+			return nil
+		}
+
+		healthChecker = &resolver.HealthChecker{
+			Logger:   fakeLogger,
+			Prober:   fakeProber,
+			Port:     8080,
+			Workers:  2,
+			CacheTTL: time.Minute,
+		}
+
+		instances = []resolver.Instance{
+			{IP: "10.0.0.1", App: "some-app"},
+			{IP: "10.0.0.2", App: "some-app"},
+			{IP: "10.0.0.3", App: "some-app"},
+		}
+	})
+
+	It("treats instances it has not probed as healthy", func() {
+		Expect(healthChecker.Healthy(instances)).To(Equal(instances))
+		Expect(fakeProber.ProbeCallCount()).To(Equal(0))
+	})
+
+	It("queues flushed instances for a probe again", func() {
+		healthChecker.Healthy(instances)
+		healthChecker.Flush("")
+		healthChecker.Healthy(instances)
+
+		process := ifrit.Invoke(healthChecker)
+		defer ginkgomon.Interrupt(process)
+
+		Eventually(fakeProber.ProbeCallCount).Should(Equal(6))
+	})
+
+	Context("when running", func() {
+		var process ifrit.Process
+
+		JustBeforeEach(func() {
+			process = ifrit.Invoke(healthChecker)
+		})
+
+		AfterEach(func() {
+			ginkgomon.Interrupt(process)
+		})
+
+		healthy := func() []resolver.Instance {
+			return healthChecker.Healthy(instances)
+		}
+
+		It("probes every instance on the configured port in the background", func() {
+			Expect(healthChecker.Healthy(instances)).To(HaveLen(3))
+
+			Eventually(fakeProber.ProbeCallCount).Should(Equal(3))
+			addresses := []string{}
+			for i := 0; i < 3; i++ {
+				addresses = append(addresses, fakeProber.ProbeArgsForCall(i))
+			}
+			Expect(addresses).To(ConsistOf("10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.3:8080"))
+		})
+
+		It("returns only the healthy instances once they are probed", func() {
+			Eventually(healthy).Should(Equal([]resolver.Instance{instances[0], instances[2]}))
+		})
+
+		It("logs unhealthy instances", func() {
+			healthChecker.Healthy(instances)
+
+			Eventually(fakeLogger).Should(gbytes.Say("instance-unhealthy.*10.0.0.2:8080.*connection refused"))
+		})
+
+		It("caches the health of each instance", func() {
+			Eventually(healthy).Should(HaveLen(2))
+			Expect(healthChecker.Healthy(instances)).To(HaveLen(2))
+
+			Consistently(fakeProber.ProbeCallCount).Should(Equal(3))
+		})
+
+		It("probes each instance once while its probe is queued", func() {
+			fakeProber.ProbeStub = func(string) error {
+				time.Sleep(10 * time.Millisecond)
+				return nil
+			}
+
+			healthChecker.Healthy(instances)
+			healthChecker.Healthy(instances)
+
+			Eventually(fakeProber.ProbeCallCount).Should(Equal(3))
+			Consistently(fakeProber.ProbeCallCount).Should(Equal(3))
+		})
+
+		Context("when the cached results have expired", func() {
+			BeforeEach(func() {
+				healthChecker.CacheTTL = 0
+			})
+
+			It("answers from them while probing the instances again", func() {
+				Eventually(healthy).Should(HaveLen(2))
+				calls := fakeProber.ProbeCallCount()
+
+				Expect(healthChecker.Healthy(instances)).To(HaveLen(2))
+				Eventually(fakeProber.ProbeCallCount).Should(BeNumerically(">", calls))
+			})
+		})
+
+		It("lists and flushes cached results", func() {
+			Eventually(healthy).Should(HaveLen(2))
+
+			entries := healthChecker.Entries()
+			Expect(entries).To(HaveLen(3))
+			Expect(entries[1].Key).To(Equal("10.0.0.2:8080"))
+			Expect(entries[1].Value).To(Equal("unhealthy"))
+
+			Expect(healthChecker.Flush("10.0.0.2")).To(Equal(1))
+			Expect(healthChecker.Entries()).To(HaveLen(2))
+			Expect(healthChecker.Flush("")).To(Equal(2))
+			Expect(healthChecker.Entries()).To(BeEmpty())
+		})
+
+		It("forgets the instances that are no longer listed", func() {
+			Eventually(healthy).Should(HaveLen(2))
+
+			healthChecker.Retain(instances[:1])
+
+			entries := healthChecker.Entries()
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Key).To(Equal("10.0.0.1:8080"))
+		})
+
+		Context("with more instances than workers", func() {
+			var (
+				lock        sync.Mutex
+				inFlight    int
+				maxInFlight int
+			)
+
+			BeforeEach(func() {
+				inFlight, maxInFlight = 0, 0
+				fakeProber.ProbeStub = func(string) error {
+					lock.Lock()
+					inFlight++
+					if inFlight > maxInFlight {
+						maxInFlight = inFlight
+					}
+					lock.Unlock()
+
+					time.Sleep(10 * time.Millisecond)
+
+					lock.Lock()
+					inFlight--
+					lock.Unlock()
+					return nil
+				}
+			})
+
+			It("bounds the number of concurrent probes", func() {
+				healthChecker.Healthy(append(instances, resolver.Instance{IP: "10.0.0.4"}, resolver.Instance{IP: "10.0.0.5"}))
+
+				Eventually(fakeProber.ProbeCallCount).Should(Equal(5))
+				lock.Lock()
+				defer lock.Unlock()
+				Expect(maxInFlight).To(BeNumerically("<=", 2))
+			})
+		})
+	})
+})
+
+var _ = Describe("TCPProber", func() {
+	It("succeeds when the address accepts connections", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer listener.Close()
+
+		prober := &resolver.TCPProber{Timeout: time.Second}
+		Expect(prober.Probe(listener.Addr().String())).To(Succeed())
+	})
+
+	It("fails when nothing is listening", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		address := listener.Addr().String()
+		listener.Close()
+
+		prober := &resolver.TCPProber{Timeout: time.Second}
+		Expect(prober.Probe(address)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("HTTPProber", func() {
+	var (
+		server *httptest.Server
+		status int
+		prober *resolver.HTTPProber
+	)
+
+	BeforeEach(func() {
+		status = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/health" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(status)
+		}))
+		prober = &resolver.HTTPProber{
+			Client: http.DefaultClient,
+			Path:   "/health",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("succeeds when the instance responds with a success status", func() {
+		Expect(prober.Probe(strings.TrimPrefix(server.URL, "http://"))).To(Succeed())
+	})
+
+	Context("when the instance responds with an error status", func() {
+		BeforeEach(func() {
+			status = http.StatusServiceUnavailable
+		})
+
+		It("returns an error", func() {
+			err := prober.Probe(strings.TrimPrefix(server.URL, "http://"))
+			Expect(err).To(MatchError("unexpected status code: 503"))
+		})
+	})
+})
@@ -1,12 +1,16 @@
 package resolver
 
 import (
+	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
-	"github.com/cloudfoundry-incubator/ducati-daemon/client"
 	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/cloudfoundry-incubator/ducati-dns/tlsclient"
+	"github.com/cloudfoundry-incubator/ducati-dns/uaa"
 	"github.com/miekg/dns"
 	"github.com/pivotal-golang/lager"
 )
@@ -14,30 +18,223 @@ import (
 //go:generate counterfeiter -o ../fakes/ducati_daemon_client.go --fake-name DucatiDaemonClient . ducatiDaemonClient
 type ducatiDaemonClient interface {
 	ListContainers() ([]models.Container, error)
+	ListAppContainers(appGUID string) ([]models.Container, error)
+	GetContainer(id string) (models.Container, bool, error)
+	GetContainerByIP(ip string) (models.Container, bool, error)
 }
 
 type Config struct {
-	DucatiSuffix string
-	DucatiAPI    string
+	DucatiSuffix       string            `yaml:"suffix"`
+	DucatiAPIs         Endpoints         `yaml:"api"`
+	LocalInstancesOnly bool              `yaml:"local_instances_only"`
+	HealthCheck        HealthCheckConfig `yaml:"health_check"`
+	Fallthrough        bool              `yaml:"fallthrough"`
+	TTL                int               `yaml:"ttl"`
+	UAA                uaa.Config        `yaml:"uaa"`
+	TLS                tlsclient.Config  `yaml:"tls"`
+	Daemon             DaemonConfig      `yaml:"daemon"`
+	Snapshot           SnapshotConfig    `yaml:"snapshot"`
+	RecordsFile        RecordsFileConfig `yaml:"records_file"`
+	Clusters           []Cluster         `yaml:"clusters"`
 }
 
-func NewHTTPResolver(logger lager.Logger, config Config) *HTTPResolver {
-	ducatiDaemonClient := client.New(
-		config.DucatiAPI,
-		http.DefaultClient,
-	)
-	return &HTTPResolver{
-		Logger:       logger.Session("http-resolver"),
-		Suffix:       config.DucatiSuffix,
-		DaemonClient: ducatiDaemonClient,
+// Cluster is a further overlay network, served under its own suffix from
+// its own ducati API.  A zero TTL and an unset UAA or TLS are taken from the
+// main overlay, as are the settings it does not list.  UAA and TLS are
+// replaced as a whole when set.
+type Cluster struct {
+	Suffix     string           `yaml:"suffix"`
+	DucatiAPIs Endpoints        `yaml:"api"`
+	TTL        int              `yaml:"ttl"`
+	UAA        uaa.Config       `yaml:"uaa"`
+	TLS        tlsclient.Config `yaml:"tls"`
+}
+
+// ForCluster returns the configuration of the resolver for cluster.
+func (c Config) ForCluster(cluster Cluster) Config {
+	c.DucatiSuffix = cluster.Suffix
+	c.DucatiAPIs = cluster.DucatiAPIs
+	if cluster.TTL != 0 {
+		c.TTL = cluster.TTL
+	}
+	if cluster.UAA.URL != "" {
+		c.UAA = cluster.UAA
+	}
+	if cluster.TLS.Enabled() {
+		c.TLS = cluster.TLS
+	}
+	if c.Snapshot.Path != "" {
+		c.Snapshot.Path += "." + cluster.Suffix
+	}
+	c.RecordsFile.Path = ""
+	c.Clusters = nil
+	return c
+}
+
+type DaemonConfig struct {
+	Timeout          time.Duration `yaml:"timeout"`
+	Retries          int           `yaml:"retries"`
+	RetryBackoff     time.Duration `yaml:"retry_backoff"`
+	BreakerThreshold int           `yaml:"breaker_threshold"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown"`
+	MaxStale         time.Duration `yaml:"max_stale"`
+	Merge            bool          `yaml:"merge"`
+	ListingMaxAge    time.Duration `yaml:"listing_max_age"`
+}
+
+// Endpoints is a list of URLs that may also be written as a single string.
+type Endpoints []string
+
+func (e *Endpoints) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var single string
+	if err := unmarshal(&single); err == nil {
+		*e = Endpoints{single}
+		return nil
+	}
+
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*e = list
+	return nil
+}
+
+func NewHTTPResolver(logger lager.Logger, config Config) (*HTTPResolver, error) {
+	httpClient := &http.Client{Timeout: config.Daemon.Timeout}
+	var transport http.RoundTripper
+	if config.TLS.Enabled() {
+		tlsTransport := &tlsclient.Transport{
+			Logger: logger.Session("tls"),
+			Config: config.TLS,
+		}
+		if err := tlsTransport.Load(); err != nil {
+			return nil, fmt.Errorf("daemon tls: %s", err)
+		}
+		transport = tlsTransport
+	}
+	if config.UAA.URL != "" {
+		transport = &uaa.Transport{
+			Tokens: &uaa.Client{
+				Logger: logger.Session("uaa"),
+				Config: config.UAA,
+			},
+			Base: transport,
+		}
+	}
+	httpClient.Transport = transport
+
+	httpResolver := &HTTPResolver{
+		Logger:   logger.Session("http-resolver"),
+		Suffix:   config.DucatiSuffix,
+		TTL:      config.TTL,
+		StaleTTL: config.Snapshot.TTL,
+		Locality: &Locality{
+			LocalOnly: config.LocalInstancesOnly,
+		},
 	}
+
+	if config.HealthCheck.Type != "" {
+		httpResolver.HealthChecker = NewHealthChecker(logger, config.HealthCheck)
+	}
+
+	sources := []RecordSource{}
+	if len(config.DucatiAPIs) > 0 {
+		sources = append(sources, newDaemonSource(logger, config, httpClient))
+	}
+
+	if config.RecordsFile.Path != "" {
+		fileSource := &FileSource{
+			Logger:       logger.Session("records-file"),
+			Path:         config.RecordsFile.Path,
+			PollInterval: config.RecordsFile.PollInterval,
+		}
+		if err := fileSource.Load(); err != nil {
+			return nil, fmt.Errorf("records file: %s", err)
+		}
+		sources = append(sources, fileSource)
+	}
+
+	if len(sources) == 1 {
+		httpResolver.Source = sources[0]
+	} else {
+		httpResolver.Source = &MergeSource{
+			Logger:  logger.Session("merge-source"),
+			Sources: sources,
+		}
+	}
+
+	return httpResolver, nil
 }
 
+func newDaemonSource(logger lager.Logger, config Config, httpClient *http.Client) *DaemonSource {
+	ducatiDaemonClient := &FailoverClient{
+		Logger: logger.Session("failover"),
+		Merge:  config.Daemon.Merge,
+	}
+	for _, api := range config.DucatiAPIs {
+		ducatiDaemonClient.Endpoints = append(ducatiDaemonClient.Endpoints, DaemonEndpoint{
+			Name:   endpointName(api),
+			Client: NewDaemonClient(api, httpClient),
+		})
+	}
+
+	source := &DaemonSource{
+		Logger: logger.Session("daemon-source"),
+		Client: &CoalescingClient{
+			MaxAge: config.Daemon.ListingMaxAge,
+			Client: &CircuitBreaker{
+				Logger: logger.Session("circuit-breaker"),
+				Client: &RetryingClient{
+					Logger:  logger.Session("daemon-client"),
+					Client:  ducatiDaemonClient,
+					Retries: config.Daemon.Retries,
+					Backoff: config.Daemon.RetryBackoff,
+				},
+				Threshold: config.Daemon.BreakerThreshold,
+				Cooldown:  config.Daemon.BreakerCooldown,
+				MaxStale:  config.Daemon.MaxStale,
+			},
+		},
+	}
+
+	if config.Snapshot.Path != "" {
+		source.Snapshot = &Snapshot{
+			Logger:   logger.Session("snapshot"),
+			Path:     config.Snapshot.Path,
+			Interval: config.Snapshot.Interval,
+		}
+		source.Client.(*CoalescingClient).OnListing = source.Snapshot.Record
+		if err := source.Snapshot.Load(); err != nil {
+			source.Snapshot.Logger.Info("no-snapshot", lager.Data{"error": err.Error()})
+		}
+	}
+
+	return source
+}
+
+// HTTPResolver answers for the names under Suffix with the instances its
+// Source has for them.  Stale instances are answered with StaleTTL.
 type HTTPResolver struct {
-	DaemonClient ducatiDaemonClient
-	TTL          int
-	Suffix       string
-	Logger       lager.Logger
+	Source        RecordSource
+	TTL           int
+	StaleTTL      int
+	Suffix        string
+	Logger        lager.Logger
+	Locality      *Locality
+	HealthChecker healthChecker
+	Fallthrough   dns.Handler
+	Registry      *Registry
+	Aliases       *AliasStore
+}
+
+// endpointName identifies a daemon in logs without its credentials.
+func endpointName(api string) string {
+	u, err := url.Parse(api)
+	if err != nil || u.Host == "" {
+		return api
+	}
+	return u.Host
 }
 
 func (r *HTTPResolver) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
@@ -48,16 +245,74 @@ func (r *HTTPResolver) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
 	m := &dns.Msg{}
 
 	requestedName := request.Question[0].Name
+	registered := r.registered(requestedName, request.Question[0].Qtype)
+
+	if request.Question[0].Qtype != dns.TypeA && len(registered) > 0 {
+		m.SetReply(request)
+		m.Answer = registered
+		logger.Info("response", lager.Data{"answer": m.Answer})
+		w.WriteMsg(m)
+		return
+	}
+
+	if r.Fallthrough != nil && request.Question[0].Qtype != dns.TypeA {
+		logger.Info("fallthrough", lager.Data{"qtype": dns.TypeToString[request.Question[0].Qtype]})
+		markForwarded(w)
+		r.Fallthrough.ServeDNS(w, request)
+		return
+	}
+
 	fullyQualifiedSuffix := "." + r.Suffix + "."
 	appGuid := strings.TrimSuffix(requestedName, fullyQualifiedSuffix)
 	if appGuid == requestedName {
+		if r.Fallthrough != nil {
+			logger.Info("fallthrough", lager.Data{"requested_name": requestedName})
+			markForwarded(w)
+			r.Fallthrough.ServeDNS(w, request)
+			return
+		}
 		m.SetRcode(request, dns.RcodeNameError)
 		w.WriteMsg(m)
 		r.Logger.Info("unknown-name", lager.Data{"requested_name": requestedName})
 		return
 	}
 
-	containers, err := r.DaemonClient.ListContainers()
+	var cname *dns.CNAME
+	if r.Aliases != nil {
+		if target, ok := r.Aliases.Get(appGuid); ok {
+			cname = &dns.CNAME{
+				Hdr: dns.RR_Header{
+					Name:   requestedName,
+					Rrtype: dns.TypeCNAME,
+					Class:  dns.ClassINET,
+					Ttl:    uint32(r.TTL),
+				},
+				Target: target + fullyQualifiedSuffix,
+			}
+			appGuid = target
+		}
+	}
+
+	ttl := r.TTL
+	instances, stale, err := r.Source.Instances(appGuid)
+	if stale {
+		ttl = r.StaleTTL
+		if cname != nil {
+			cname.Hdr.Ttl = uint32(ttl)
+		}
+	}
+	if err != nil && len(registered) > 0 {
+		// The registered records are all that is known for certain, so
+		// they are answered without the alias or the daemon's instances.
+		logger.Info("serving-registered", lager.Data{"error": err.Error()})
+		instances, cname, err = nil, nil, nil
+	}
+	if err == ErrCircuitOpen {
+		m.SetRcode(request, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+		logger.Info("circuit-open")
+		return
+	}
 	if err != nil {
 		m.SetRcode(request, dns.RcodeServerFailure)
 		w.WriteMsg(m)
@@ -65,34 +320,88 @@ func (r *HTTPResolver) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
 		return
 	}
 
-	var container models.Container
-	for _, c := range containers {
-		if c.App == appGuid {
-			container = c
+	if len(instances) == 0 && len(registered) == 0 && cname == nil {
+		if r.Fallthrough != nil {
+			logger.Info("fallthrough", lager.Data{"requested_name": requestedName})
+			markForwarded(w)
+			r.Fallthrough.ServeDNS(w, request)
+			return
 		}
-	}
-
-	if container == (models.Container{}) {
 		m.SetRcode(request, dns.RcodeNameError)
 		w.WriteMsg(m)
 		r.Logger.Info("record-not-found", lager.Data{"requested_name": requestedName})
 		return
 	}
 
+	if r.HealthChecker != nil && len(instances) > 0 {
+		healthy := r.HealthChecker.Healthy(instances)
+		if len(healthy) > 0 {
+			instances = healthy
+		} else {
+			logger.Info("no-healthy-instances", lager.Data{"requested_name": requestedName})
+		}
+	}
+
+	if r.Locality != nil && len(instances) > 1 {
+		hostIP := ""
+		if !stale {
+			hostIP = r.clientHostIP(logger, remoteIP(w))
+		}
+		instances = r.Locality.Order(instances, hostIP)
+	}
+
 	m.SetReply(request)
 
-	m.Answer = []dns.RR{
-		&dns.A{
+	answerName := requestedName
+	if cname != nil {
+		m.Answer = append(m.Answer, cname)
+		answerName = cname.Target
+	}
+
+	for _, instance := range instances {
+		m.Answer = append(m.Answer, &dns.A{
 			Hdr: dns.RR_Header{
-				Name:   requestedName,
+				Name:   answerName,
 				Rrtype: dns.TypeA,
 				Class:  dns.ClassINET,
-				Ttl:    uint32(r.TTL),
+				Ttl:    uint32(ttl),
 			},
-			A: net.ParseIP(container.IP)},
+			A: net.ParseIP(instance.IP),
+		})
 	}
+	m.Answer = append(m.Answer, registered...)
 
 	logger.Info("response", lager.Data{"answer": m.Answer})
 
 	w.WriteMsg(m)
 }
+
+// clientHostIP returns the host of the instance with the client's IP.  A
+// client that is not an instance may be a host itself.
+func (r *HTTPResolver) clientHostIP(logger lager.Logger, clientIP string) string {
+	if clientIP == "" {
+		return ""
+	}
+
+	instance, found, err := r.Source.InstanceByIP(clientIP)
+	if err != nil {
+		logger.Error("client-lookup-failed", err)
+		return clientIP
+	}
+	if !found {
+		return clientIP
+	}
+	return instance.HostIP
+}
+
+func (r *HTTPResolver) registered(name string, qtype uint16) []dns.RR {
+	if r.Registry == nil {
+		return nil
+	}
+
+	rrs := r.Registry.Lookup(name, qtype)
+	if len(rrs) == 0 && qtype != dns.TypeCNAME {
+		rrs = r.Registry.Lookup(name, dns.TypeCNAME)
+	}
+	return rrs
+}
@@ -2,12 +2,18 @@ package resolver_test
 
 import (
 	"errors"
+	"io/ioutil"
 	"math/rand"
 	"net"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/cloudfoundry-incubator/ducati-daemon/models"
 	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
 	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/cloudfoundry-incubator/ducati-dns/tlsclient"
+	"github.com/cloudfoundry-incubator/ducati-dns/uaa"
 	"github.com/miekg/dns"
 
 	. "github.com/onsi/ginkgo"
@@ -24,6 +30,8 @@ var _ = Describe("HTTPResolver", func() {
 		request          *dns.Msg
 		fakeLogger       *lagertest.TestLogger
 		fakeDaemonClient *fakes.DucatiDaemonClient
+		daemonSource     *resolver.DaemonSource
+		containers       []models.Container
 	)
 
 	BeforeEach(func() {
@@ -34,15 +42,37 @@ var _ = Describe("HTTPResolver", func() {
 		}
 		request.SetQuestion(dns.Fqdn("some-app-guid.potato"), dns.TypeA)
 		fakeLogger = lagertest.NewTestLogger("test")
-		fakeDaemonClient = &fakes.DucatiDaemonClient{}
-		fakeDaemonClient.ListContainersReturns([]models.Container{
+		containers = []models.Container{
 			{IP: "10.11.12.13", App: "some-app-guid"},
-		}, nil)
+		}
+		fakeDaemonClient = &fakes.DucatiDaemonClient{}
+		fakeDaemonClient.ListAppContainersStub = func(appGUID string) ([]models.Container, error) {
+			instances := []models.Container{}
+			for _, c := range containers {
+				if c.App == appGUID {
+					instances = append(instances, c)
+				}
+			}
+			return instances, nil
+		}
+		fakeDaemonClient.GetContainerByIPStub = func(ip string) (models.Container, bool, error) {
+			for _, c := range containers {
+				if c.IP == ip {
+					return c, true, nil
+				}
+			}
+			return models.Container{}, false, nil
+		}
+		daemonSource = &resolver.DaemonSource{
+			Logger: fakeLogger,
+			Client: fakeDaemonClient,
+		}
 		httpResolver = &resolver.HTTPResolver{
-			Suffix:       "potato",
-			DaemonClient: fakeDaemonClient,
-			TTL:          42,
-			Logger:       fakeLogger,
+			Suffix:   "potato",
+			Source:   daemonSource,
+			TTL:      42,
+			StaleTTL: 5,
+			Logger:   fakeLogger,
 		}
 		responseWriter = &fakes.ResponseWriter{}
 	})
@@ -50,7 +80,9 @@ var _ = Describe("HTTPResolver", func() {
 	It("resolves DNS queries by using the ducati daemon client", func() {
 		httpResolver.ServeDNS(responseWriter, request)
 
-		Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(1))
+		Expect(fakeDaemonClient.ListAppContainersCallCount()).To(Equal(1))
+		Expect(fakeDaemonClient.ListAppContainersArgsForCall(0)).To(Equal("some-app-guid"))
+		Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(0))
 
 		Expect(responseWriter.WriteMsgCallCount()).To(Equal(1))
 
@@ -75,6 +107,322 @@ var _ = Describe("HTTPResolver", func() {
 		Expect(fakeLogger.Buffer()).To(gbytes.Say("test.serve-dns.resolve-complete"))
 	})
 
+	Context("when the app has several instances", func() {
+		BeforeEach(func() {
+			containers = []models.Container{
+				{IP: "10.11.12.13", App: "some-app-guid", HostIP: "192.168.0.1"},
+				{IP: "10.11.12.14", App: "some-other-app-guid", HostIP: "192.168.0.2"},
+				{IP: "10.11.12.15", App: "some-app-guid", HostIP: "192.168.0.2"},
+			}
+		})
+
+		It("answers with a record for every instance", func() {
+			httpResolver.ServeDNS(responseWriter, request)
+
+			Expect(responseWriter.WriteMsgCallCount()).To(Equal(1))
+			answer := responseWriter.WriteMsgArgsForCall(0).Answer
+			Expect(answer).To(HaveLen(2))
+			Expect([]string{
+				answer[0].(*dns.A).A.String(),
+				answer[1].(*dns.A).A.String(),
+			}).To(ConsistOf("10.11.12.13", "10.11.12.15"))
+		})
+
+		Context("when locality is configured", func() {
+			BeforeEach(func() {
+				httpResolver.Locality = &resolver.Locality{}
+				responseWriter.RemoteAddrReturns(&net.UDPAddr{IP: net.ParseIP("10.11.12.14"), Port: 5353})
+			})
+
+			It("lists the instances on the client's host first", func() {
+				httpResolver.ServeDNS(responseWriter, request)
+
+				answer := responseWriter.WriteMsgArgsForCall(0).Answer
+				Expect(answer).To(HaveLen(2))
+				Expect(answer[0].(*dns.A).A.String()).To(Equal("10.11.12.15"))
+				Expect(fakeDaemonClient.GetContainerByIPArgsForCall(0)).To(Equal("10.11.12.14"))
+			})
+
+			It("recognizes clients querying from the host itself", func() {
+				responseWriter.RemoteAddrReturns(&net.UDPAddr{IP: net.ParseIP("192.168.0.1"), Port: 5353})
+				httpResolver.ServeDNS(responseWriter, request)
+
+				answer := responseWriter.WriteMsgArgsForCall(0).Answer
+				Expect(answer[0].(*dns.A).A.String()).To(Equal("10.11.12.13"))
+			})
+
+			Context("when the client lookup fails", func() {
+				BeforeEach(func() {
+					fakeDaemonClient.GetContainerByIPReturns(models.Container{}, false, errors.New("potato"))
+				})
+
+				It("still answers with every instance", func() {
+					httpResolver.ServeDNS(responseWriter, request)
+
+					Expect(responseWriter.WriteMsgArgsForCall(0).Answer).To(HaveLen(2))
+					Expect(fakeLogger).To(gbytes.Say("client-lookup-failed"))
+				})
+			})
+
+			Context("when only local instances are requested", func() {
+				BeforeEach(func() {
+					httpResolver.Locality.LocalOnly = true
+				})
+
+				It("answers with only the local instances", func() {
+					httpResolver.ServeDNS(responseWriter, request)
+
+					answer := responseWriter.WriteMsgArgsForCall(0).Answer
+					Expect(answer).To(HaveLen(1))
+					Expect(answer[0].(*dns.A).A.String()).To(Equal("10.11.12.15"))
+				})
+			})
+		})
+	})
+
+	Context("when a health checker is configured", func() {
+		var fakeHealthChecker *fakes.HealthChecker
+
+		BeforeEach(func() {
+			containers = []models.Container{
+				{IP: "10.11.12.13", App: "some-app-guid"},
+				{IP: "10.11.12.15", App: "some-app-guid"},
+			}
+			fakeHealthChecker = &fakes.HealthChecker{}
+			fakeHealthChecker.HealthyReturns([]resolver.Instance{
+				{IP: "10.11.12.15", App: "some-app-guid"},
+			})
+			httpResolver.HealthChecker = fakeHealthChecker
+		})
+
+		It("checks the health of the app's instances", func() {
+			httpResolver.ServeDNS(responseWriter, request)
+
+			Expect(fakeHealthChecker.HealthyCallCount()).To(Equal(1))
+			Expect(fakeHealthChecker.HealthyArgsForCall(0)).To(Equal([]resolver.Instance{
+				{IP: "10.11.12.13", App: "some-app-guid"},
+				{IP: "10.11.12.15", App: "some-app-guid"},
+			}))
+		})
+
+		It("excludes unhealthy instances from the answer", func() {
+			httpResolver.ServeDNS(responseWriter, request)
+
+			answer := responseWriter.WriteMsgArgsForCall(0).Answer
+			Expect(answer).To(HaveLen(1))
+			Expect(answer[0].(*dns.A).A.String()).To(Equal("10.11.12.15"))
+		})
+
+		Context("when every instance is unhealthy", func() {
+			BeforeEach(func() {
+				fakeHealthChecker.HealthyReturns([]resolver.Instance{})
+			})
+
+			It("answers with all of the instances", func() {
+				httpResolver.ServeDNS(responseWriter, request)
+
+				Expect(responseWriter.WriteMsgArgsForCall(0).Answer).To(HaveLen(2))
+			})
+
+			It("logs that there were no healthy instances", func() {
+				httpResolver.ServeDNS(responseWriter, request)
+
+				Expect(fakeLogger).To(gbytes.Say("no-healthy-instances.*some-app-guid.potato"))
+			})
+		})
+	})
+
+	Context("when fallthrough is enabled", func() {
+		var fallthroughHandler *fakes.Handler
+
+		BeforeEach(func() {
+			fallthroughHandler = &fakes.Handler{}
+			httpResolver.Fallthrough = fallthroughHandler
+		})
+
+		It("still answers names known to the overlay", func() {
+			httpResolver.ServeDNS(responseWriter, request)
+
+			Expect(fallthroughHandler.ServeDNSCallCount()).To(Equal(0))
+			Expect(responseWriter.WriteMsgCallCount()).To(Equal(1))
+			Expect(responseWriter.WriteMsgArgsForCall(0).Answer).To(HaveLen(1))
+		})
+
+		Context("when the app is unknown to the overlay", func() {
+			BeforeEach(func() {
+				request.SetQuestion(dns.Fqdn("some-unknown-app.potato"), dns.TypeA)
+			})
+
+			It("passes the request to the fallthrough handler", func() {
+				httpResolver.ServeDNS(responseWriter, request)
+
+				Expect(responseWriter.WriteMsgCallCount()).To(Equal(0))
+				Expect(fallthroughHandler.ServeDNSCallCount()).To(Equal(1))
+				w, r := fallthroughHandler.ServeDNSArgsForCall(0)
+				Expect(w).To(Equal(responseWriter))
+				Expect(r).To(Equal(request))
+			})
+
+			It("logs the fallthrough", func() {
+				httpResolver.ServeDNS(responseWriter, request)
+
+				Expect(fakeLogger).To(gbytes.Say("fallthrough.*some-unknown-app.potato"))
+			})
+
+			It("keeps a signer up the chain from signing the forwarded answer", func() {
+				fallthroughHandler.ServeDNSStub = func(w dns.ResponseWriter, r *dns.Msg) {
+					m := &dns.Msg{}
+					m.SetReply(r)
+					w.WriteMsg(m)
+				}
+				signer := &resolver.Signer{
+					Logger: fakeLogger,
+					Zone:   "potato",
+					Keys:   []*resolver.SigningKey{generateKey(dns.ZONE)},
+					Next:   httpResolver,
+				}
+				request.SetEdns0(4096, true)
+
+				signer.ServeDNS(responseWriter, request)
+
+				m := responseWriter.WriteMsgArgsForCall(0)
+				Expect(m.Answer).To(BeEmpty())
+				Expect(m.Ns).To(BeEmpty())
+			})
+		})
+
+		Context("when the requestedName does not end in the suffix", func() {
+			BeforeEach(func() {
+				request.SetQuestion(dns.Fqdn("something.else.entirely"), dns.TypeA)
+			})
+
+			It("passes the request to the fallthrough handler", func() {
+				httpResolver.ServeDNS(responseWriter, request)
+
+				Expect(responseWriter.WriteMsgCallCount()).To(Equal(0))
+				Expect(fallthroughHandler.ServeDNSCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the qtype is not served by the overlay", func() {
+			BeforeEach(func() {
+				request.SetQuestion(dns.Fqdn("some-app-guid.potato"), dns.TypeMX)
+			})
+
+			It("passes the request to the fallthrough handler without asking the daemon", func() {
+				httpResolver.ServeDNS(responseWriter, request)
+
+				Expect(fakeDaemonClient.ListAppContainersCallCount()).To(Equal(0))
+				Expect(fallthroughHandler.ServeDNSCallCount()).To(Equal(1))
+			})
+		})
+	})
+
+	Context("when records have been registered dynamically", func() {
+		var registry *resolver.Registry
+
+		newRR := func(s string) dns.RR {
+			rr, err := dns.NewRR(s)
+			Expect(err).NotTo(HaveOccurred())
+			return rr
+		}
+
+		BeforeEach(func() {
+			registry = &resolver.Registry{}
+			registry.Add(newRR("some-app-guid.potato. 30 IN A 10.0.0.5"), time.Minute)
+			registry.Add(newRR("broker.potato. 30 IN A 10.0.0.6"), time.Minute)
+			registry.Add(newRR("broker.potato. 30 IN TXT \"hello\""), time.Minute)
+			httpResolver.Registry = registry
+		})
+
+		It("merges them with the daemon's records", func() {
+			httpResolver.ServeDNS(responseWriter, request)
+
+			answer := responseWriter.WriteMsgArgsForCall(0).Answer
+			Expect(answer).To(HaveLen(2))
+			Expect(answer[0].(*dns.A).A.String()).To(Equal("10.11.12.13"))
+			Expect(answer[1].(*dns.A).A.String()).To(Equal("10.0.0.5"))
+		})
+
+		It("answers registered names the daemon does not know", func() {
+			request.SetQuestion("broker.potato.", dns.TypeA)
+			httpResolver.ServeDNS(responseWriter, request)
+
+			resp := responseWriter.WriteMsgArgsForCall(0)
+			Expect(resp.Rcode).To(Equal(dns.RcodeSuccess))
+			Expect(resp.Answer).To(HaveLen(1))
+			Expect(resp.Answer[0].(*dns.A).A.String()).To(Equal("10.0.0.6"))
+		})
+
+		Context("when the daemon fails", func() {
+			BeforeEach(func() {
+				fakeDaemonClient.ListAppContainersReturns(nil, resolver.ErrCircuitOpen)
+			})
+
+			It("answers with the registered records", func() {
+				httpResolver.ServeDNS(responseWriter, request)
+
+				resp := responseWriter.WriteMsgArgsForCall(0)
+				Expect(resp.Rcode).To(Equal(dns.RcodeSuccess))
+				Expect(resp.Answer).To(HaveLen(1))
+				Expect(resp.Answer[0].(*dns.A).A.String()).To(Equal("10.0.0.5"))
+				Expect(fakeLogger).To(gbytes.Say("serving-registered.*daemon circuit breaker is open"))
+			})
+
+			It("still fails names without registered records", func() {
+				request.SetQuestion("other-app-guid.potato.", dns.TypeA)
+				httpResolver.ServeDNS(responseWriter, request)
+
+				Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeServerFailure))
+			})
+		})
+
+		It("answers registered records of other types without asking the daemon", func() {
+			request.SetQuestion("broker.potato.", dns.TypeTXT)
+			httpResolver.ServeDNS(responseWriter, request)
+
+			Expect(fakeDaemonClient.ListAppContainersCallCount()).To(Equal(0))
+			Expect(responseWriter.WriteMsgArgsForCall(0).Answer[0].(*dns.TXT).Txt).To(Equal([]string{"hello"}))
+		})
+	})
+
+	Context("when the name is an alias", func() {
+		BeforeEach(func() {
+			aliases := &resolver.AliasStore{}
+			aliases.Set("payments", "some-app-guid")
+			httpResolver.Aliases = aliases
+			request.SetQuestion(dns.Fqdn("payments.potato"), dns.TypeA)
+		})
+
+		It("answers with a CNAME and the target's records", func() {
+			httpResolver.ServeDNS(responseWriter, request)
+
+			Expect(fakeDaemonClient.ListAppContainersArgsForCall(0)).To(Equal("some-app-guid"))
+
+			answer := responseWriter.WriteMsgArgsForCall(0).Answer
+			Expect(answer).To(HaveLen(2))
+			Expect(answer[0].Header().Name).To(Equal("payments.potato."))
+			Expect(answer[0].(*dns.CNAME).Target).To(Equal("some-app-guid.potato."))
+			Expect(answer[0].Header().Ttl).To(Equal(uint32(42)))
+			Expect(answer[1].Header().Name).To(Equal("some-app-guid.potato."))
+			Expect(answer[1].(*dns.A).A.String()).To(Equal("10.11.12.13"))
+		})
+
+		Context("when the target has no instances", func() {
+			BeforeEach(func() {
+				containers = []models.Container{}
+			})
+
+			It("answers with only the CNAME", func() {
+				httpResolver.ServeDNS(responseWriter, request)
+
+				resp := responseWriter.WriteMsgArgsForCall(0)
+				Expect(resp.Rcode).To(Equal(dns.RcodeSuccess))
+				Expect(resp.Answer).To(HaveLen(1))
+			})
+		})
+	})
+
 	Context("when the requestedName does not end in the suffix", func() {
 		BeforeEach(func() {
 			request.SetQuestion(dns.Fqdn("something.else.entirely"), dns.TypeA)
@@ -97,7 +445,7 @@ var _ = Describe("HTTPResolver", func() {
 
 	Context("when there are no containers at all", func() {
 		BeforeEach(func() {
-			fakeDaemonClient.ListContainersReturns([]models.Container{}, nil)
+			containers = []models.Container{}
 		})
 
 		It("should reply with NXDOMAIN", func() {
@@ -118,7 +466,7 @@ var _ = Describe("HTTPResolver", func() {
 	Context("when getting the container from the ducati daemon errors", func() {
 		Context("when the error is something else", func() {
 			BeforeEach(func() {
-				fakeDaemonClient.ListContainersReturns([]models.Container{}, errors.New("some server failure"))
+				fakeDaemonClient.ListAppContainersReturns(nil, errors.New("some server failure"))
 			})
 
 			It("should reply with SERVFAIL", func() {
@@ -135,5 +483,168 @@ var _ = Describe("HTTPResolver", func() {
 				Expect(fakeLogger).To(gbytes.Say("ducati-client-error"))
 			})
 		})
+
+		Context("when the circuit breaker is open", func() {
+			BeforeEach(func() {
+				fakeDaemonClient.ListAppContainersReturns(nil, resolver.ErrCircuitOpen)
+			})
+
+			It("should reply with SERVFAIL", func() {
+				httpResolver.ServeDNS(responseWriter, request)
+
+				Expect(responseWriter.WriteMsgCallCount()).To(Equal(1))
+				Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeServerFailure))
+			})
+
+			It("logs that the daemon was not asked rather than an error", func() {
+				httpResolver.ServeDNS(responseWriter, request)
+
+				Expect(fakeLogger).To(gbytes.Say("serve-dns.circuit-open"))
+				Expect(fakeLogger).NotTo(gbytes.Say("ducati-client-error"))
+			})
+		})
+
+		Context("when a snapshot from a previous run was loaded", func() {
+			var dir string
+
+			BeforeEach(func() {
+				var err error
+				dir, err = ioutil.TempDir("", "snapshot")
+				Expect(err).NotTo(HaveOccurred())
+
+				path := filepath.Join(dir, "snapshot.json")
+				Expect(ioutil.WriteFile(path, []byte(`[{"app": "some-app-guid", "ip": "10.0.0.9"}]`), 0600)).To(Succeed())
+
+				daemonSource.Snapshot = &resolver.Snapshot{
+					Logger: fakeLogger,
+					Path:   path,
+				}
+				Expect(daemonSource.Snapshot.Load()).To(Succeed())
+				fakeDaemonClient.ListAppContainersReturns(nil, resolver.ErrCircuitOpen)
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(dir)
+			})
+
+			It("answers from the snapshot with the stale TTL", func() {
+				httpResolver.ServeDNS(responseWriter, request)
+
+				resp := responseWriter.WriteMsgArgsForCall(0)
+				Expect(resp.Rcode).To(Equal(dns.RcodeSuccess))
+				Expect(resp.Answer).To(HaveLen(1))
+				Expect(resp.Answer[0].(*dns.A).A.String()).To(Equal("10.0.0.9"))
+				Expect(resp.Answer[0].Header().Ttl).To(Equal(uint32(5)))
+				Expect(fakeLogger).To(gbytes.Say("serving-snapshot.*daemon circuit breaker is open"))
+			})
+
+			It("still fails names the snapshot does not know", func() {
+				request.SetQuestion(dns.Fqdn("some-unknown-app.potato"), dns.TypeA)
+				httpResolver.ServeDNS(responseWriter, request)
+
+				Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeServerFailure))
+			})
+
+			It("answers from the listings recorded since", func() {
+				daemonSource.Snapshot.Record([]models.Container{{App: "some-app-guid", IP: "10.0.0.10"}})
+
+				httpResolver.ServeDNS(responseWriter, request)
+				resp := responseWriter.WriteMsgArgsForCall(0)
+				Expect(resp.Answer).To(HaveLen(1))
+				Expect(resp.Answer[0].(*dns.A).A.String()).To(Equal("10.0.0.10"))
+			})
+		})
+	})
+
+	Context("when the records come from another source", func() {
+		var fakeSource *fakes.RecordSource
+
+		BeforeEach(func() {
+			fakeSource = &fakes.RecordSource{}
+			fakeSource.InstancesReturns([]resolver.Instance{{App: "some-app-guid", IP: "10.0.0.5"}}, false, nil)
+			httpResolver.Source = fakeSource
+		})
+
+		It("answers with the source's instances", func() {
+			httpResolver.ServeDNS(responseWriter, request)
+
+			Expect(fakeSource.InstancesArgsForCall(0)).To(Equal("some-app-guid"))
+			resp := responseWriter.WriteMsgArgsForCall(0)
+			Expect(resp.Answer).To(HaveLen(1))
+			Expect(resp.Answer[0].(*dns.A).A.String()).To(Equal("10.0.0.5"))
+			Expect(resp.Answer[0].Header().Ttl).To(Equal(uint32(42)))
+		})
+
+		It("answers stale instances with the stale TTL and without asking for the client's host", func() {
+			fakeSource.InstancesReturns([]resolver.Instance{
+				{App: "some-app-guid", IP: "10.0.0.5"},
+				{App: "some-app-guid", IP: "10.0.0.6"},
+			}, true, nil)
+			httpResolver.Locality = &resolver.Locality{}
+			responseWriter.RemoteAddrReturns(&net.UDPAddr{IP: net.ParseIP("10.0.0.9"), Port: 53})
+
+			httpResolver.ServeDNS(responseWriter, request)
+
+			resp := responseWriter.WriteMsgArgsForCall(0)
+			Expect(resp.Answer).To(HaveLen(2))
+			Expect(resp.Answer[0].Header().Ttl).To(Equal(uint32(5)))
+			Expect(fakeSource.InstanceByIPCallCount()).To(Equal(0))
+		})
+	})
+})
+
+var _ = Describe("Config", func() {
+	Describe("ForCluster", func() {
+		It("replaces the cluster's settings and keeps the shared ones", func() {
+			c := resolver.Config{
+				DucatiSuffix:       "overlay",
+				DucatiAPIs:         resolver.Endpoints{"http://daemon:4001"},
+				TTL:                30,
+				LocalInstancesOnly: true,
+				Daemon:             resolver.DaemonConfig{Retries: 3},
+				Snapshot:           resolver.SnapshotConfig{Path: "/snapshot.json"},
+				RecordsFile:        resolver.RecordsFileConfig{Path: "/records.json"},
+				Clusters:           []resolver.Cluster{{Suffix: "staging"}},
+			}
+
+			clusterConfig := c.ForCluster(resolver.Cluster{
+				Suffix:     "staging",
+				DucatiAPIs: resolver.Endpoints{"http://staging:4001"},
+				TTL:        5,
+			})
+			Expect(clusterConfig).To(Equal(resolver.Config{
+				DucatiSuffix:       "staging",
+				DucatiAPIs:         resolver.Endpoints{"http://staging:4001"},
+				TTL:                5,
+				LocalInstancesOnly: true,
+				Daemon:             resolver.DaemonConfig{Retries: 3},
+				Snapshot:           resolver.SnapshotConfig{Path: "/snapshot.json.staging"},
+			}))
+		})
+
+		It("takes the TTL, UAA and TLS the cluster does not set from the main overlay", func() {
+			c := resolver.Config{
+				DucatiSuffix: "overlay",
+				TTL:          30,
+				UAA:          uaa.Config{URL: "https://uaa", ClientID: "ducati_dns", ClientSecret: "secret"},
+				TLS:          tlsclient.Config{CACert: "/ca.pem"},
+			}
+
+			clusterConfig := c.ForCluster(resolver.Cluster{
+				Suffix:     "staging",
+				DucatiAPIs: resolver.Endpoints{"https://staging:4001"},
+			})
+			Expect(clusterConfig.TTL).To(Equal(30))
+			Expect(clusterConfig.UAA).To(Equal(c.UAA))
+			Expect(clusterConfig.TLS).To(Equal(c.TLS))
+
+			clusterConfig = c.ForCluster(resolver.Cluster{
+				Suffix: "staging",
+				UAA:    uaa.Config{URL: "https://staging-uaa", ClientID: "staging", ClientSecret: "other"},
+				TLS:    tlsclient.Config{ServerName: "staging"},
+			})
+			Expect(clusterConfig.UAA).To(Equal(uaa.Config{URL: "https://staging-uaa", ClientID: "staging", ClientSecret: "other"}))
+			Expect(clusterConfig.TLS).To(Equal(tlsclient.Config{ServerName: "staging"}))
+		})
 	})
 })
@@ -0,0 +1,58 @@
+package resolver
+
+import (
+	"math/rand"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+type Locality struct {
+	LocalOnly bool
+	Perm      func(n int) []int
+}
+
+// Order returns the instances that share a host with the client first,
+// followed by the remaining instances in shuffled order.  When LocalOnly
+// is set and the client has local instances, only those are returned.
+func (l *Locality) Order(instances []Instance, hostIP string) []Instance {
+	local := []Instance{}
+	remote := []Instance{}
+	for _, c := range instances {
+		if hostIP != "" && c.HostIP == hostIP {
+			local = append(local, c)
+		} else {
+			remote = append(remote, c)
+		}
+	}
+
+	if l.LocalOnly && len(local) > 0 {
+		return local
+	}
+
+	perm := l.Perm
+	if perm == nil {
+		perm = rand.Perm
+	}
+
+	ordered := local
+	for _, i := range perm(len(remote)) {
+		ordered = append(ordered, remote[i])
+	}
+
+	return ordered
+}
+
+func remoteIP(w dns.ResponseWriter) string {
+	addr := w.RemoteAddr()
+	if addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return ""
+	}
+
+	return host
+}
@@ -0,0 +1,99 @@
+package resolver_test
+
+import (
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Locality", func() {
+	var (
+		locality  *resolver.Locality
+		instances []resolver.Instance
+	)
+
+	BeforeEach(func() {
+		locality = &resolver.Locality{
+			Perm: func(n int) []int {
+				p := []int{}
+				for i := n - 1; i >= 0; i-- {
+					p = append(p, i)
+				}
+				return p
+			},
+		}
+
+		instances = []resolver.Instance{
+			{IP: "10.0.0.1", App: "some-app", HostIP: "192.168.0.1"},
+			{IP: "10.0.0.2", App: "some-app", HostIP: "192.168.0.2"},
+			{IP: "10.0.0.3", App: "some-app", HostIP: "192.168.0.3"},
+			{IP: "10.0.0.4", App: "some-app", HostIP: "192.168.0.2"},
+		}
+	})
+
+	It("orders instances on the client's host first and shuffles the rest", func() {
+		ordered := locality.Order(instances, "192.168.0.2")
+
+		Expect(ordered).To(Equal([]resolver.Instance{
+			instances[1],
+			instances[3],
+			instances[2],
+			instances[0],
+		}))
+	})
+
+	Context("when the client is not on any known host", func() {
+		It("returns every instance in shuffled order", func() {
+			ordered := locality.Order(instances, "172.16.0.1")
+
+			Expect(ordered).To(Equal([]resolver.Instance{
+				instances[3],
+				instances[2],
+				instances[1],
+				instances[0],
+			}))
+		})
+	})
+
+	Context("when the client's host is unknown", func() {
+		It("returns every instance", func() {
+			ordered := locality.Order(instances, "")
+
+			Expect(ordered).To(ConsistOf(instances))
+		})
+	})
+
+	Context("when only local instances are requested", func() {
+		BeforeEach(func() {
+			locality.LocalOnly = true
+		})
+
+		It("returns only the instances on the client's host", func() {
+			ordered := locality.Order(instances, "192.168.0.2")
+
+			Expect(ordered).To(Equal([]resolver.Instance{instances[1], instances[3]}))
+		})
+
+		Context("when there are no instances on the client's host", func() {
+			It("falls back to every instance", func() {
+				ordered := locality.Order(instances, "172.16.0.1")
+
+				Expect(ordered).To(ConsistOf(instances))
+			})
+		})
+	})
+
+	Context("when no permutation is provided", func() {
+		BeforeEach(func() {
+			locality.Perm = nil
+		})
+
+		It("still returns every instance", func() {
+			ordered := locality.Order(instances, "192.168.0.2")
+
+			Expect(ordered[:2]).To(ConsistOf(instances[1], instances[3]))
+			Expect(ordered).To(ConsistOf(instances))
+		})
+	})
+})
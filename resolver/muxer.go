@@ -7,15 +7,20 @@ import (
 	"github.com/pivotal-golang/lager"
 )
 
+// Muxer sends queries for the overlay Suffix to SuffixPresentHandler, for
+// the suffixes of further overlays to their handler in Overlays, for zones
+// to the zone's handler and for anything else to DefaultHandler.  The most
+// specific match wins.
 type Muxer struct {
 	Logger               lager.Logger
 	Suffix               string
 	SuffixPresentHandler dns.Handler
+	Overlays             map[string]dns.Handler
 	DefaultHandler       dns.Handler
+	Zones                map[string]dns.Handler
 }
 
 func (m *Muxer) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
-	suffix := dns.Fqdn(m.Suffix)
 	name := request.Question[0].Name
 
 	logger := m.Logger.Session("serve-dns", lager.Data{"name": name})
@@ -23,9 +28,66 @@ func (m *Muxer) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
 	logger.Info("resolving")
 	defer logger.Info("complete")
 
-	if m.Suffix != "" && strings.HasSuffix(name, suffix) {
-		m.SuffixPresentHandler.ServeDNS(w, request)
+	overlay, overlayLabels := m.overlayFor(name)
+	if zone := m.zoneFor(name, overlayLabels); zone != nil {
+		zone.ServeDNS(w, request)
+	} else if overlay != nil {
+		overlay.ServeDNS(w, request)
 	} else {
 		m.DefaultHandler.ServeDNS(w, request)
 	}
 }
+
+// overlayFor returns the handler of the most specific overlay suffix of
+// name and the number of labels in that suffix.
+func (m *Muxer) overlayFor(name string) (dns.Handler, int) {
+	var (
+		handler dns.Handler
+		labels  int
+	)
+
+	suffix := dns.Fqdn(m.Suffix)
+	if m.Suffix != "" && strings.HasSuffix(name, suffix) {
+		handler = m.SuffixPresentHandler
+		if dns.IsSubDomain(suffix, name) {
+			labels = dns.CountLabel(suffix)
+		}
+	}
+
+	for suffix, overlay := range m.Overlays {
+		suffix = dns.Fqdn(suffix)
+		if dns.IsSubDomain(suffix, name) && (handler == nil || dns.CountLabel(suffix) > labels) {
+			handler = overlay
+			labels = dns.CountLabel(suffix)
+		}
+	}
+
+	return handler, labels
+}
+
+// zoneFor returns the handler of the most specific zone containing name,
+// unless the overlay suffix of overlayLabels labels is at least as specific.
+func (m *Muxer) zoneFor(name string, overlayLabels int) dns.Handler {
+	var (
+		handler dns.Handler
+		labels  int
+	)
+
+	for origin, zone := range m.Zones {
+		origin = dns.Fqdn(origin)
+		if dns.IsSubDomain(origin, name) && (handler == nil || dns.CountLabel(origin) > labels) {
+			handler = zone
+			labels = dns.CountLabel(origin)
+		}
+	}
+
+	if handler == nil {
+		return nil
+	}
+
+	if overlayLabels >= labels {
+		return nil
+	}
+
+	return handler
+}
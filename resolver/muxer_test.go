@@ -89,6 +89,113 @@ var _ = Describe("Muxer", func() {
 		})
 	})
 
+	Context("when zones are mounted", func() {
+		var (
+			zoneHandler   *fakes.Handler
+			nestedHandler *fakes.Handler
+		)
+
+		BeforeEach(func() {
+			zoneHandler = &fakes.Handler{}
+			nestedHandler = &fakes.Handler{}
+			muxer.Zones = map[string]dns.Handler{
+				"cell.internal":       zoneHandler,
+				"inner.cell.internal": nestedHandler,
+				"overlay.potato":      zoneHandler,
+			}
+		})
+
+		It("forwards requests within a zone to the zone's handler", func() {
+			request.SetQuestion(dns.Fqdn("db.cell.internal"), dns.TypeA)
+			muxer.ServeDNS(responseWriter, request)
+
+			Expect(zoneHandler.ServeDNSCallCount()).To(Equal(1))
+			Expect(defaultHandler.ServeDNSCallCount()).To(Equal(0))
+		})
+
+		It("uses the most specific zone", func() {
+			request.SetQuestion(dns.Fqdn("db.inner.cell.internal"), dns.TypeA)
+			muxer.ServeDNS(responseWriter, request)
+
+			Expect(nestedHandler.ServeDNSCallCount()).To(Equal(1))
+			Expect(zoneHandler.ServeDNSCallCount()).To(Equal(0))
+		})
+
+		It("does not match names that only share a textual suffix", func() {
+			request.SetQuestion(dns.Fqdn("db.badcell.internal"), dns.TypeA)
+			muxer.ServeDNS(responseWriter, request)
+
+			Expect(zoneHandler.ServeDNSCallCount()).To(Equal(0))
+			Expect(defaultHandler.ServeDNSCallCount()).To(Equal(1))
+		})
+
+		It("prefers the overlay suffix over zones that are not more specific", func() {
+			muxer.Zones = map[string]dns.Handler{"potato": zoneHandler}
+			request.SetQuestion(dns.Fqdn("something.potato"), dns.TypeA)
+			muxer.ServeDNS(responseWriter, request)
+
+			Expect(suffixPresentHandler.ServeDNSCallCount()).To(Equal(1))
+			Expect(zoneHandler.ServeDNSCallCount()).To(Equal(0))
+		})
+
+		It("prefers zones more specific than the overlay suffix", func() {
+			request.SetQuestion(dns.Fqdn("something.overlay.potato"), dns.TypeA)
+			muxer.ServeDNS(responseWriter, request)
+
+			Expect(zoneHandler.ServeDNSCallCount()).To(Equal(1))
+			Expect(suffixPresentHandler.ServeDNSCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when further overlays are mounted", func() {
+		var (
+			prodHandler    *fakes.Handler
+			stagingHandler *fakes.Handler
+		)
+
+		BeforeEach(func() {
+			prodHandler = &fakes.Handler{}
+			stagingHandler = &fakes.Handler{}
+			muxer.Suffix = "overlay"
+			muxer.Overlays = map[string]dns.Handler{
+				"prod.overlay": prodHandler,
+				"staging":      stagingHandler,
+			}
+		})
+
+		It("forwards requests to the overlay with the most specific suffix", func() {
+			request.SetQuestion(dns.Fqdn("some-app.prod.overlay"), dns.TypeA)
+			muxer.ServeDNS(responseWriter, request)
+			request.SetQuestion(dns.Fqdn("some-app.staging"), dns.TypeA)
+			muxer.ServeDNS(responseWriter, request)
+			request.SetQuestion(dns.Fqdn("some-app.overlay"), dns.TypeA)
+			muxer.ServeDNS(responseWriter, request)
+
+			Expect(prodHandler.ServeDNSCallCount()).To(Equal(1))
+			Expect(stagingHandler.ServeDNSCallCount()).To(Equal(1))
+			Expect(suffixPresentHandler.ServeDNSCallCount()).To(Equal(1))
+			Expect(defaultHandler.ServeDNSCallCount()).To(Equal(0))
+		})
+
+		It("does not match names that only share a textual suffix", func() {
+			request.SetQuestion(dns.Fqdn("some-app.notstaging"), dns.TypeA)
+			muxer.ServeDNS(responseWriter, request)
+
+			Expect(stagingHandler.ServeDNSCallCount()).To(Equal(0))
+			Expect(defaultHandler.ServeDNSCallCount()).To(Equal(1))
+		})
+
+		It("prefers them over zones that are not more specific", func() {
+			zoneHandler := &fakes.Handler{}
+			muxer.Zones = map[string]dns.Handler{"staging": zoneHandler}
+			request.SetQuestion(dns.Fqdn("some-app.staging"), dns.TypeA)
+			muxer.ServeDNS(responseWriter, request)
+
+			Expect(stagingHandler.ServeDNSCallCount()).To(Equal(1))
+			Expect(zoneHandler.ServeDNSCallCount()).To(Equal(0))
+		})
+	})
+
 	Context("when the suffix is not set", func() {
 		BeforeEach(func() {
 			muxer.Suffix = ""
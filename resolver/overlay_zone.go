@@ -0,0 +1,279 @@
+package resolver
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const defaultZoneHistory = 32
+
+type zoneChange struct {
+	fromSerial uint32
+	toSerial   uint32
+	removed    []dns.RR
+	added      []dns.RR
+}
+
+// OverlayZone tracks the records the overlay would answer as a zone.  The
+// SOA serial is incremented every time the container set changes, and a
+// bounded history of those changes is kept for incremental transfers.
+type OverlayZone struct {
+	Suffix     string
+	TTL        int
+	MaxHistory int
+
+	mutex   sync.Mutex
+	serial  uint32
+	records map[string]dns.RR
+	history []zoneChange
+}
+
+func NewOverlayZone(suffix string, ttl int) *OverlayZone {
+	return &OverlayZone{
+		Suffix:     suffix,
+		TTL:        ttl,
+		MaxHistory: defaultZoneHistory,
+		serial:     uint32(time.Now().Unix()),
+	}
+}
+
+func (z *OverlayZone) origin() string {
+	return strings.ToLower(dns.Fqdn(z.Suffix))
+}
+
+// Update records the current instances and any other records the overlay
+// answers with, returning the zone serial.
+func (z *OverlayZone) Update(instances []Instance, extra ...dns.RR) uint32 {
+	records := z.build(instances, extra)
+
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	if z.records == nil {
+		z.records = records
+		return z.serial
+	}
+
+	change := zoneChange{fromSerial: z.serial}
+	for key, rr := range z.records {
+		if _, ok := records[key]; !ok {
+			change.removed = append(change.removed, rr)
+		}
+	}
+	for key, rr := range records {
+		if _, ok := z.records[key]; !ok {
+			change.added = append(change.added, rr)
+		}
+	}
+
+	if len(change.removed) == 0 && len(change.added) == 0 {
+		return z.serial
+	}
+
+	z.serial++
+	change.toSerial = z.serial
+	sortRecords(change.removed)
+	sortRecords(change.added)
+
+	z.records = records
+	z.history = append(z.history, change)
+	if z.MaxHistory > 0 && len(z.history) > z.MaxHistory {
+		z.history = z.history[len(z.history)-z.MaxHistory:]
+	}
+
+	return z.serial
+}
+
+// RecordsFor returns the records the zone would have with instances and
+// extra, sorted, without updating it.
+func (z *OverlayZone) RecordsFor(instances []Instance, extra ...dns.RR) []dns.RR {
+	records := []dns.RR{}
+	for _, rr := range z.build(instances, extra) {
+		records = append(records, rr)
+	}
+	sortRecords(records)
+	return records
+}
+
+// build returns the zone's records keyed by their text.
+func (z *OverlayZone) build(instances []Instance, extra []dns.RR) map[string]dns.RR {
+	records := map[string]dns.RR{}
+	for _, rr := range extra {
+		records[rr.String()] = rr
+	}
+	for _, c := range instances {
+		ip := net.ParseIP(c.IP)
+		if c.App == "" || ip == nil {
+			continue
+		}
+		rr := &dns.A{
+			Hdr: dns.RR_Header{
+				Name:   strings.ToLower(c.App) + "." + z.origin(),
+				Rrtype: dns.TypeA,
+				Class:  dns.ClassINET,
+				Ttl:    uint32(z.TTL),
+			},
+			A: ip,
+		}
+		records[rr.String()] = rr
+	}
+	return records
+}
+
+func (z *OverlayZone) SOA() *dns.SOA {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	return z.soa(z.serial)
+}
+
+func (z *OverlayZone) soa(serial uint32) *dns.SOA {
+	return &dns.SOA{
+		Hdr: dns.RR_Header{
+			Name:   z.origin(),
+			Rrtype: dns.TypeSOA,
+			Class:  dns.ClassINET,
+			Ttl:    uint32(z.TTL),
+		},
+		Ns:      z.nameServer(),
+		Mbox:    "hostmaster." + z.origin(),
+		Serial:  serial,
+		Refresh: 60,
+		Retry:   30,
+		Expire:  3600,
+		Minttl:  uint32(z.TTL),
+	}
+}
+
+func (z *OverlayZone) NS() *dns.NS {
+	return &dns.NS{
+		Hdr: dns.RR_Header{
+			Name:   z.origin(),
+			Rrtype: dns.TypeNS,
+			Class:  dns.ClassINET,
+			Ttl:    uint32(z.TTL),
+		},
+		Ns: z.nameServer(),
+	}
+}
+
+func (z *OverlayZone) nameServer() string {
+	return "ns." + z.origin()
+}
+
+// Loaded reports whether the zone has been updated at least once.
+func (z *OverlayZone) Loaded() bool {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	return z.records != nil
+}
+
+// Records returns the overlay's records, sorted.
+func (z *OverlayZone) Records() []dns.RR {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	records := []dns.RR{}
+	for _, rr := range z.records {
+		records = append(records, rr)
+	}
+	sortRecords(records)
+	return records
+}
+
+// Types returns the types of the records the zone has at name, sorted.
+func (z *OverlayZone) Types(name string) []uint16 {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	name = strings.ToLower(dns.Fqdn(name))
+	found := map[uint16]bool{}
+	if name == z.origin() {
+		found[dns.TypeSOA] = true
+		found[dns.TypeNS] = true
+	}
+	for _, rr := range z.records {
+		if strings.ToLower(rr.Header().Name) == name {
+			found[rr.Header().Rrtype] = true
+		}
+	}
+
+	types := []uint16{}
+	for rrtype := range found {
+		types = append(types, rrtype)
+	}
+	sort.Sort(byType(types))
+	return types
+}
+
+// AXFR returns the full zone framed by its SOA record.
+func (z *OverlayZone) AXFR() []dns.RR {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	soa := z.soa(z.serial)
+
+	records := []dns.RR{}
+	for _, rr := range z.records {
+		records = append(records, rr)
+	}
+	sortRecords(records)
+
+	rrs := []dns.RR{soa, z.NS()}
+	rrs = append(rrs, records...)
+	return append(rrs, soa)
+}
+
+// IXFR returns the changes since serial in RFC 1995 format.  The second
+// return value is false if the history no longer reaches back to serial.
+func (z *OverlayZone) IXFR(serial uint32) ([]dns.RR, bool) {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	current := z.soa(z.serial)
+	if serial == z.serial {
+		return []dns.RR{current}, true
+	}
+
+	start := -1
+	for i, change := range z.history {
+		if change.fromSerial == serial {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, false
+	}
+
+	rrs := []dns.RR{current}
+	for _, change := range z.history[start:] {
+		rrs = append(rrs, z.soa(change.fromSerial))
+		rrs = append(rrs, change.removed...)
+		rrs = append(rrs, z.soa(change.toSerial))
+		rrs = append(rrs, change.added...)
+	}
+	return append(rrs, current), true
+}
+
+func sortRecords(rrs []dns.RR) {
+	sort.Sort(byString(rrs))
+}
+
+type byType []uint16
+
+func (b byType) Len() int           { return len(b) }
+func (b byType) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byType) Less(i, j int) bool { return b[i] < b[j] }
+
+type byString []dns.RR
+
+func (b byString) Len() int           { return len(b) }
+func (b byString) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byString) Less(i, j int) bool { return b[i].String() < b[j].String() }
@@ -0,0 +1,111 @@
+package resolver_test
+
+import (
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/miekg/dns"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OverlayZone", func() {
+	var (
+		zone       *resolver.OverlayZone
+		containers []resolver.Instance
+	)
+
+	BeforeEach(func() {
+		zone = resolver.NewOverlayZone("potato", 42)
+		containers = []resolver.Instance{
+			{IP: "10.0.0.1", App: "app-a"},
+			{IP: "10.0.0.2", App: "app-a"},
+			{IP: "10.0.0.3", App: "app-b"},
+		}
+	})
+
+	It("keeps the serial when the container set is unchanged", func() {
+		serial := zone.Update(containers)
+		Expect(zone.Update(containers)).To(Equal(serial))
+	})
+
+	It("increments the serial when the container set changes", func() {
+		serial := zone.Update(containers)
+		Expect(zone.Update(containers[:2])).To(Equal(serial + 1))
+		Expect(zone.SOA().Serial).To(Equal(serial + 1))
+	})
+
+	It("lists its records", func() {
+		zone.Update(containers)
+
+		records := zone.Records()
+		Expect(records).To(HaveLen(3))
+		Expect(records[0].String()).To(ContainSubstring("app-a.potato."))
+		Expect(records[2].String()).To(ContainSubstring("10.0.0.3"))
+	})
+
+	It("lists the types it has at a name", func() {
+		zone.Update(containers)
+
+		Expect(zone.Types("app-a.potato.")).To(Equal([]uint16{dns.TypeA}))
+		Expect(zone.Types("potato")).To(Equal([]uint16{dns.TypeNS, dns.TypeSOA}))
+		Expect(zone.Types("missing.potato.")).To(BeEmpty())
+	})
+
+	Describe("AXFR", func() {
+		It("contains the SOA, NS and every app record, framed by the SOA", func() {
+			zone.Update(containers)
+			rrs := zone.AXFR()
+
+			Expect(rrs).To(HaveLen(6))
+			Expect(rrs[0]).To(Equal(zone.SOA()))
+			Expect(rrs[1].(*dns.NS).Ns).To(Equal("ns.potato."))
+			Expect(rrs[2].Header().Name).To(Equal("app-a.potato."))
+			Expect(rrs[2].(*dns.A).A.String()).To(Equal("10.0.0.1"))
+			Expect(rrs[2].Header().Ttl).To(Equal(uint32(42)))
+			Expect(rrs[4].Header().Name).To(Equal("app-b.potato."))
+			Expect(rrs[5]).To(Equal(zone.SOA()))
+		})
+	})
+
+	Describe("IXFR", func() {
+		var initialSerial uint32
+
+		BeforeEach(func() {
+			initialSerial = zone.Update(containers)
+		})
+
+		It("answers with only the SOA when the client is current", func() {
+			rrs, ok := zone.IXFR(initialSerial)
+
+			Expect(ok).To(BeTrue())
+			Expect(rrs).To(Equal([]dns.RR{zone.SOA()}))
+		})
+
+		It("answers with the differences since the client's serial", func() {
+			zone.Update(containers[1:])
+			zone.Update(append(containers[1:], resolver.Instance{IP: "10.0.0.4", App: "app-c"}))
+
+			rrs, ok := zone.IXFR(initialSerial)
+			Expect(ok).To(BeTrue())
+
+			Expect(rrs).To(HaveLen(8))
+			Expect(rrs[0].(*dns.SOA).Serial).To(Equal(initialSerial + 2))
+			Expect(rrs[1].(*dns.SOA).Serial).To(Equal(initialSerial))
+			Expect(rrs[2].(*dns.A).A.String()).To(Equal("10.0.0.1"))
+			Expect(rrs[3].(*dns.SOA).Serial).To(Equal(initialSerial + 1))
+			Expect(rrs[4].(*dns.SOA).Serial).To(Equal(initialSerial + 1))
+			Expect(rrs[5].(*dns.SOA).Serial).To(Equal(initialSerial + 2))
+			Expect(rrs[6].(*dns.A).A.String()).To(Equal("10.0.0.4"))
+			Expect(rrs[7]).To(Equal(zone.SOA()))
+		})
+
+		It("reports when the history does not reach the client's serial", func() {
+			zone.MaxHistory = 1
+			zone.Update(containers[1:])
+			zone.Update(containers[2:])
+
+			_, ok := zone.IXFR(initialSerial)
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
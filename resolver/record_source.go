@@ -0,0 +1,137 @@
+package resolver
+
+import (
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/pivotal-golang/lager"
+)
+
+// Instance is an address an overlay name resolves to.
+type Instance struct {
+	App    string `json:"app"`
+	IP     string `json:"ip"`
+	HostIP string `json:"host_ip"`
+}
+
+//go:generate counterfeiter -o ../fakes/record_source.go --fake-name RecordSource . RecordSource
+
+// RecordSource is where the HTTPResolver finds the instances it answers
+// with.  Instances reports whether the instances are stale, i.e. were kept
+// from an earlier answer because the source is currently unavailable.
+type RecordSource interface {
+	Instances(app string) ([]Instance, bool, error)
+	InstanceByIP(ip string) (Instance, bool, error)
+	AllInstances() ([]Instance, error)
+}
+
+// MergeSource answers with the instances of all its sources, so that an
+// inventory can be served alongside the ducati daemon.  Instances with the
+// same IP are only returned once, from the first source that has them.
+// When a source fails, the instances of the others are answered as stale,
+// and the error is returned if they have none.  Listings of every instance
+// fail when any source does, so that a missing source does not look like
+// its instances were removed.
+type MergeSource struct {
+	Logger  lager.Logger
+	Sources []RecordSource
+}
+
+func (m *MergeSource) Instances(app string) ([]Instance, bool, error) {
+	var merged []Instance
+	stale := false
+	err := m.merge(func(source RecordSource) error {
+		instances, sourceStale, err := source.Instances(app)
+		if err != nil {
+			return err
+		}
+		merged = appendInstances(merged, instances)
+		stale = stale || sourceStale
+		return nil
+	})
+	if err != nil {
+		if len(merged) == 0 {
+			return nil, false, err
+		}
+		stale = true
+	}
+	return merged, stale, nil
+}
+
+func (m *MergeSource) InstanceByIP(ip string) (Instance, bool, error) {
+	var found Instance
+	ok := false
+	err := m.merge(func(source RecordSource) error {
+		if ok {
+			return nil
+		}
+		instance, sourceOK, err := source.InstanceByIP(ip)
+		if err != nil {
+			return err
+		}
+		found, ok = instance, sourceOK
+		return nil
+	})
+	if err != nil && !ok {
+		return Instance{}, false, err
+	}
+	return found, ok, nil
+}
+
+func (m *MergeSource) AllInstances() ([]Instance, error) {
+	var merged []Instance
+	err := m.merge(func(source RecordSource) error {
+		instances, err := source.AllInstances()
+		if err != nil {
+			return err
+		}
+		merged = appendInstances(merged, instances)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// merge asks every source in turn and returns the last error any of them
+// gave.
+func (m *MergeSource) merge(request func(RecordSource) error) error {
+	var lastErr error
+	for i, source := range m.Sources {
+		if err := request(source); err != nil {
+			m.Logger.Error("source-failed", err, lager.Data{"source": i})
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func appendInstances(merged, instances []Instance) []Instance {
+	if merged == nil {
+		merged = []Instance{}
+	}
+	for _, instance := range instances {
+		duplicate := false
+		for _, existing := range merged {
+			if existing.IP == instance.IP {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			merged = append(merged, instance)
+		}
+	}
+	return merged
+}
+
+func containerInstances(containers []models.Container) []Instance {
+	instances := make([]Instance, len(containers))
+	for i, c := range containers {
+		instances[i] = containerInstance(c)
+	}
+	return instances
+}
+
+func containerInstance(c models.Container) Instance {
+	return Instance{App: c.App, IP: c.IP, HostIP: c.HostIP}
+}
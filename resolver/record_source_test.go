@@ -0,0 +1,128 @@
+package resolver_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("MergeSource", func() {
+	var (
+		first, second *fakes.RecordSource
+		fakeLogger    *lagertest.TestLogger
+		source        *resolver.MergeSource
+	)
+
+	BeforeEach(func() {
+		first = &fakes.RecordSource{}
+		first.InstancesReturns([]resolver.Instance{
+			{App: "some-app-guid", IP: "10.0.0.1", HostIP: "10.244.0.2"},
+		}, false, nil)
+		first.AllInstancesReturns([]resolver.Instance{
+			{App: "some-app-guid", IP: "10.0.0.1", HostIP: "10.244.0.2"},
+		}, nil)
+
+		second = &fakes.RecordSource{}
+		second.InstancesReturns([]resolver.Instance{
+			{App: "some-app-guid", IP: "10.0.0.1"},
+			{App: "some-app-guid", IP: "10.0.0.2"},
+		}, false, nil)
+		second.AllInstancesReturns([]resolver.Instance{
+			{App: "other-app-guid", IP: "10.0.0.3"},
+		}, nil)
+
+		fakeLogger = lagertest.NewTestLogger("test")
+		source = &resolver.MergeSource{
+			Logger:  fakeLogger,
+			Sources: []resolver.RecordSource{first, second},
+		}
+	})
+
+	It("returns the instances of every source once, preferring earlier sources", func() {
+		instances, stale, err := source.Instances("some-app-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stale).To(BeFalse())
+		Expect(instances).To(Equal([]resolver.Instance{
+			{App: "some-app-guid", IP: "10.0.0.1", HostIP: "10.244.0.2"},
+			{App: "some-app-guid", IP: "10.0.0.2"},
+		}))
+		Expect(first.InstancesArgsForCall(0)).To(Equal("some-app-guid"))
+		Expect(second.InstancesArgsForCall(0)).To(Equal("some-app-guid"))
+	})
+
+	It("is stale when any source is", func() {
+		second.InstancesReturns([]resolver.Instance{{App: "some-app-guid", IP: "10.0.0.2"}}, true, nil)
+
+		_, stale, err := source.Instances("some-app-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stale).To(BeTrue())
+	})
+
+	It("lists the instances of every source", func() {
+		instances, err := source.AllInstances()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(HaveLen(2))
+	})
+
+	It("finds an instance by IP in the first source that has it", func() {
+		second.InstanceByIPReturns(resolver.Instance{App: "some-app-guid", IP: "10.0.0.2"}, true, nil)
+
+		instance, found, err := source.InstanceByIP("10.0.0.2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(instance.App).To(Equal("some-app-guid"))
+		Expect(first.InstanceByIPCallCount()).To(Equal(1))
+	})
+
+	It("answers from the other sources as stale when one fails", func() {
+		first.InstancesReturns(nil, false, errors.New("potato"))
+
+		instances, stale, err := source.Instances("some-app-guid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(HaveLen(2))
+		Expect(stale).To(BeTrue())
+		Expect(fakeLogger).To(gbytes.Say("source-failed.*potato"))
+	})
+
+	It("fails when a source fails and the others have no instances", func() {
+		first.InstancesReturns(nil, false, errors.New("potato"))
+		second.InstancesReturns([]resolver.Instance{}, false, nil)
+
+		_, _, err := source.Instances("some-app-guid")
+		Expect(err).To(MatchError("potato"))
+	})
+
+	It("fails to find an instance by IP when a source fails and the others do not have it", func() {
+		first.InstanceByIPReturns(resolver.Instance{}, false, errors.New("potato"))
+
+		_, _, err := source.InstanceByIP("10.0.0.2")
+		Expect(err).To(MatchError("potato"))
+
+		second.InstanceByIPReturns(resolver.Instance{App: "some-app-guid", IP: "10.0.0.2"}, true, nil)
+		instance, found, err := source.InstanceByIP("10.0.0.2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(instance.App).To(Equal("some-app-guid"))
+	})
+
+	It("fails to list every instance when any source fails", func() {
+		second.AllInstancesReturns(nil, errors.New("potato"))
+
+		_, err := source.AllInstances()
+		Expect(err).To(MatchError("potato"))
+	})
+
+	It("fails when every source fails", func() {
+		first.InstancesReturns(nil, false, errors.New("potato"))
+		second.InstancesReturns(nil, false, resolver.ErrCircuitOpen)
+
+		_, _, err := source.Instances("some-app-guid")
+		Expect(err).To(Equal(resolver.ErrCircuitOpen))
+	})
+})
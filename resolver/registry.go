@@ -0,0 +1,146 @@
+package resolver
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type registeredRecord struct {
+	rr      dns.RR
+	expires time.Time
+}
+
+// Registry holds records registered through dynamic updates.  Each record
+// is held for the duration of its lease and then silently dropped: a name's
+// expired records are deleted whenever the name is read, and every add
+// sweeps the names nobody reads any more.
+type Registry struct {
+	mutex   sync.Mutex
+	records map[string][]registeredRecord
+}
+
+func (r *Registry) Add(rr dns.RR, lease time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.records == nil {
+		r.records = map[string][]registeredRecord{}
+	}
+
+	r.sweep()
+
+	name := strings.ToLower(rr.Header().Name)
+	expires := time.Now().Add(lease)
+
+	existing := r.live(name)
+	for i, record := range existing {
+		if dns.IsDuplicate(record.rr, rr) {
+			existing[i] = registeredRecord{rr: rr, expires: expires}
+			r.records[name] = existing
+			return
+		}
+	}
+
+	r.records[name] = append(existing, registeredRecord{rr: rr, expires: expires})
+}
+
+// Remove deletes the records at name matching rrtype, or every record at
+// name when rrtype is dns.TypeANY.
+func (r *Registry) Remove(name string, rrtype uint16) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.filter(name, func(record registeredRecord) bool {
+		return rrtype != dns.TypeANY && record.rr.Header().Rrtype != rrtype
+	})
+}
+
+// RemoveRecord deletes a single record, matching on its rdata.
+func (r *Registry) RemoveRecord(rr dns.RR) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	target := dns.Copy(rr)
+	target.Header().Class = dns.ClassINET
+
+	r.filter(rr.Header().Name, func(record registeredRecord) bool {
+		return !dns.IsDuplicate(record.rr, target)
+	})
+}
+
+func (r *Registry) Lookup(name string, qtype uint16) []dns.RR {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	rrs := []dns.RR{}
+	for _, record := range r.live(strings.ToLower(name)) {
+		if qtype == dns.TypeANY || record.rr.Header().Rrtype == qtype {
+			answer := dns.Copy(record.rr)
+			answer.Header().Name = name
+			rrs = append(rrs, answer)
+		}
+	}
+	return rrs
+}
+
+func (r *Registry) Records() []dns.RR {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	rrs := []dns.RR{}
+	for name := range r.records {
+		for _, record := range r.live(name) {
+			rrs = append(rrs, record.rr)
+		}
+	}
+	sortRecords(rrs)
+	return rrs
+}
+
+func (r *Registry) filter(name string, keep func(registeredRecord) bool) {
+	name = strings.ToLower(name)
+	kept := []registeredRecord{}
+	for _, record := range r.live(name) {
+		if keep(record) {
+			kept = append(kept, record)
+		}
+	}
+	if len(kept) == 0 {
+		delete(r.records, name)
+		return
+	}
+	r.records[name] = kept
+}
+
+func (r *Registry) sweep() {
+	for name := range r.records {
+		r.live(name)
+	}
+}
+
+// live returns the records at name whose lease has not expired, deleting
+// the others.
+func (r *Registry) live(name string) []registeredRecord {
+	records, ok := r.records[name]
+	if !ok {
+		return []registeredRecord{}
+	}
+
+	now := time.Now()
+	live := []registeredRecord{}
+	for _, record := range records {
+		if now.Before(record.expires) {
+			live = append(live, record)
+		}
+	}
+
+	if len(live) == 0 {
+		delete(r.records, name)
+	} else if len(live) < len(records) {
+		r.records[name] = live
+	}
+	return live
+}
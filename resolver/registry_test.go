@@ -0,0 +1,98 @@
+package resolver_test
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/miekg/dns"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	var (
+		registry *resolver.Registry
+		broker   dns.RR
+		sidecar  dns.RR
+		srv      dns.RR
+	)
+
+	newRR := func(s string) dns.RR {
+		rr, err := dns.NewRR(s)
+		Expect(err).NotTo(HaveOccurred())
+		return rr
+	}
+
+	BeforeEach(func() {
+		registry = &resolver.Registry{}
+		broker = newRR("broker.potato. 30 IN A 10.0.0.5")
+		sidecar = newRR("broker.potato. 30 IN A 10.0.0.6")
+		srv = newRR("broker.potato. 30 IN SRV 0 0 8080 broker.potato.")
+
+		registry.Add(broker, time.Minute)
+		registry.Add(sidecar, time.Minute)
+		registry.Add(srv, time.Minute)
+	})
+
+	It("looks up registered records by name and type", func() {
+		Expect(registry.Lookup("broker.potato.", dns.TypeA)).To(ConsistOf(broker, sidecar))
+		Expect(registry.Lookup("broker.potato.", dns.TypeSRV)).To(ConsistOf(srv))
+		Expect(registry.Lookup("broker.potato.", dns.TypeANY)).To(HaveLen(3))
+	})
+
+	It("matches names case-insensitively and answers with the queried name", func() {
+		rrs := registry.Lookup("Broker.Potato.", dns.TypeSRV)
+
+		Expect(rrs).To(HaveLen(1))
+		Expect(rrs[0].Header().Name).To(Equal("Broker.Potato."))
+	})
+
+	It("does not duplicate records that are registered again", func() {
+		registry.Add(newRR("broker.potato. 60 IN A 10.0.0.5"), time.Minute)
+
+		Expect(registry.Lookup("broker.potato.", dns.TypeA)).To(HaveLen(2))
+	})
+
+	It("forgets records once their lease expires", func() {
+		registry.Add(newRR("short.potato. 30 IN A 10.0.0.7"), 10*time.Millisecond)
+
+		Expect(registry.Lookup("short.potato.", dns.TypeA)).To(HaveLen(1))
+		Eventually(func() []dns.RR { return registry.Lookup("short.potato.", dns.TypeA) }).Should(BeEmpty())
+	})
+
+	It("leaves expired records out of the listing once another record is added", func() {
+		short := newRR("short.potato. 30 IN A 10.0.0.7")
+		registry.Add(short, 10*time.Millisecond)
+		Expect(registry.Records()).To(ContainElement(short))
+
+		time.Sleep(20 * time.Millisecond)
+		registry.Add(newRR("other.potato. 30 IN A 10.0.0.8"), time.Minute)
+
+		Expect(registry.Records()).NotTo(ContainElement(short))
+		Expect(registry.Records()).To(HaveLen(4))
+	})
+
+	It("removes an RRset", func() {
+		registry.Remove("broker.potato.", dns.TypeA)
+
+		Expect(registry.Lookup("broker.potato.", dns.TypeANY)).To(ConsistOf(srv))
+	})
+
+	It("removes every record at a name", func() {
+		registry.Remove("broker.potato.", dns.TypeANY)
+
+		Expect(registry.Records()).To(BeEmpty())
+	})
+
+	It("removes a single record", func() {
+		removal := newRR("broker.potato. 0 NONE A 10.0.0.6")
+		registry.RemoveRecord(removal)
+
+		Expect(registry.Lookup("broker.potato.", dns.TypeA)).To(ConsistOf(broker))
+	})
+
+	It("lists every registered record", func() {
+		Expect(registry.Records()).To(ConsistOf(broker, sidecar, srv))
+	})
+})
@@ -0,0 +1,73 @@
+package resolver
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/pivotal-golang/lager"
+)
+
+// RetryingClient retries failed daemon requests up to Retries times,
+// sleeping a random duration of up to Backoff, doubled after each attempt,
+// in between.
+type RetryingClient struct {
+	Logger  lager.Logger
+	Client  ducatiDaemonClient
+	Retries int
+	Backoff time.Duration
+}
+
+func (c *RetryingClient) ListContainers() ([]models.Container, error) {
+	var containers []models.Container
+	err := c.retry("list-containers", func() (err error) {
+		containers, err = c.Client.ListContainers()
+		return err
+	})
+	return containers, err
+}
+
+func (c *RetryingClient) ListAppContainers(appGUID string) ([]models.Container, error) {
+	var containers []models.Container
+	err := c.retry("list-app-containers", func() (err error) {
+		containers, err = c.Client.ListAppContainers(appGUID)
+		return err
+	})
+	return containers, err
+}
+
+func (c *RetryingClient) GetContainer(id string) (models.Container, bool, error) {
+	var container models.Container
+	var found bool
+	err := c.retry("get-container", func() (err error) {
+		container, found, err = c.Client.GetContainer(id)
+		return err
+	})
+	return container, found, err
+}
+
+func (c *RetryingClient) GetContainerByIP(ip string) (models.Container, bool, error) {
+	var container models.Container
+	var found bool
+	err := c.retry("get-container-by-ip", func() (err error) {
+		container, found, err = c.Client.GetContainerByIP(ip)
+		return err
+	})
+	return container, found, err
+}
+
+func (c *RetryingClient) retry(action string, request func() error) error {
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		err := request()
+		if err == nil || attempt >= c.Retries {
+			return err
+		}
+
+		c.Logger.Info(action+"-retry", lager.Data{"attempt": attempt + 1, "error": err.Error()})
+		if backoff > 0 {
+			time.Sleep(time.Duration(rand.Int63n(int64(backoff)) + 1))
+			backoff *= 2
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package resolver_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RetryingClient", func() {
+	var (
+		fakeDaemonClient *fakes.DucatiDaemonClient
+		logger           *lagertest.TestLogger
+		client           *resolver.RetryingClient
+		containers       []models.Container
+	)
+
+	BeforeEach(func() {
+		containers = []models.Container{{App: "some-app-guid", IP: "10.11.12.13"}}
+		fakeDaemonClient = &fakes.DucatiDaemonClient{}
+		fakeDaemonClient.ListContainersReturns(containers, nil)
+		logger = lagertest.NewTestLogger("test")
+		client = &resolver.RetryingClient{
+			Logger:  logger,
+			Client:  fakeDaemonClient,
+			Retries: 2,
+			Backoff: time.Millisecond,
+		}
+	})
+
+	It("returns the containers from the first successful call", func() {
+		Expect(client.ListContainers()).To(Equal(containers))
+		Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(1))
+	})
+
+	Context("when calls fail", func() {
+		var failures int
+
+		BeforeEach(func() {
+			failures = 2
+			fakeDaemonClient.ListContainersStub = func() ([]models.Container, error) {
+				if failures > 0 {
+					failures--
+					return nil, errors.New("potato")
+				}
+				return containers, nil
+			}
+		})
+
+		It("retries them", func() {
+			Expect(client.ListContainers()).To(Equal(containers))
+			Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(3))
+			Expect(logger).To(gbytes.Say("list-containers-retry.*attempt.*1.*potato"))
+			Expect(logger).To(gbytes.Say("list-containers-retry.*attempt.*2.*potato"))
+		})
+
+		It("gives up after the configured number of retries", func() {
+			client.Retries = 1
+
+			_, err := client.ListContainers()
+			Expect(err).To(MatchError("potato"))
+			Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(2))
+		})
+
+		It("backs off between attempts", func() {
+			client.Backoff = 20 * time.Millisecond
+			failures = 1
+
+			start := time.Now()
+			client.ListContainers()
+			Expect(time.Since(start)).To(BeNumerically("<", 100*time.Millisecond))
+			Expect(fakeDaemonClient.ListContainersCallCount()).To(Equal(2))
+		})
+
+		It("retries targeted lookups too", func() {
+			failures = 1
+			fakeDaemonClient.ListAppContainersStub = func(appGUID string) ([]models.Container, error) {
+				if failures > 0 {
+					failures--
+					return nil, errors.New("potato")
+				}
+				return containers, nil
+			}
+
+			Expect(client.ListAppContainers("some-app-guid")).To(Equal(containers))
+			Expect(fakeDaemonClient.ListAppContainersCallCount()).To(Equal(2))
+			Expect(logger).To(gbytes.Say("list-app-containers-retry.*attempt.*1.*potato"))
+		})
+	})
+})
@@ -0,0 +1,469 @@
+package resolver
+
+import (
+	"container/list"
+	"crypto"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/metrics"
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager"
+)
+
+const (
+	defaultSignatureValidity = 7 * 24 * time.Hour
+	signatureInceptionSkew   = time.Hour
+	maxCachedSignatures      = 10000
+)
+
+type SigningKey struct {
+	DNSKEY  *dns.DNSKEY
+	Private crypto.Signer
+}
+
+// LoadSigningKey reads a key pair in the format written by dnssec-keygen,
+// given the path without the .key/.private extension.
+func LoadSigningKey(basePath string) (*SigningKey, error) {
+	public, err := os.Open(basePath + ".key")
+	if err != nil {
+		return nil, fmt.Errorf("open public key: %s", err)
+	}
+	defer public.Close()
+
+	var dnskey *dns.DNSKEY
+	for token := range dns.ParseZone(public, "", basePath+".key") {
+		if token.Error != nil {
+			return nil, fmt.Errorf("parse public key: %s", token.Error)
+		}
+		if k, ok := token.RR.(*dns.DNSKEY); ok && dnskey == nil {
+			dnskey = k
+		}
+	}
+	if dnskey == nil {
+		return nil, fmt.Errorf("parse public key: no DNSKEY record in %s.key", basePath)
+	}
+
+	private, err := os.Open(basePath + ".private")
+	if err != nil {
+		return nil, fmt.Errorf("open private key: %s", err)
+	}
+	defer private.Close()
+
+	privateKey, err := dnskey.ReadPrivateKey(private, basePath+".private")
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %s", err)
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("parse private key: unsupported key type %T", privateKey)
+	}
+
+	return &SigningKey{DNSKEY: dnskey, Private: signer}, nil
+}
+
+type cachedSignature struct {
+	key     string
+	name    string
+	rrsig   *dns.RRSIG
+	refresh time.Time
+}
+
+// Signer signs the answers of Next for clients that set the DO bit.  Answers
+// Next forwarded elsewhere, such as fallthrough answers from a delegated
+// zone, are passed on untouched.  The DNSKEY RRset is served at the apex, and negative answers are proven with
+// NSEC "black lies": an NSEC record at the query name itself, which turns
+// NXDOMAIN into NODATA without revealing the rest of the zone.  When
+// OverlayZone is set, negative answers carry its SOA record and the NSEC
+// records of existing names list the types it has for them.
+type Signer struct {
+	Logger      lager.Logger
+	Zone        string
+	OverlayZone *OverlayZone
+	Keys        []*SigningKey
+	Validity    time.Duration
+	Metrics     *metrics.Metrics
+	Next        dns.Handler
+
+	mutex sync.Mutex
+	cache map[string]*list.Element
+	lru   *list.List
+}
+
+func (s *Signer) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
+	question := request.Question[0]
+	if request.Opcode != dns.OpcodeQuery || question.Qtype == dns.TypeAXFR || question.Qtype == dns.TypeIXFR {
+		s.Next.ServeDNS(w, request)
+		return
+	}
+
+	var m *dns.Msg
+	if question.Qtype == dns.TypeDNSKEY && strings.ToLower(question.Name) == s.zone() {
+		m = &dns.Msg{}
+		m.SetReply(request)
+		m.Authoritative = true
+		m.Answer = s.dnskeys()
+	} else {
+		capture := &capturingWriter{ResponseWriter: w}
+		s.Next.ServeDNS(capture, request)
+		if capture.msg == nil {
+			return
+		}
+		if capture.forwarded {
+			w.WriteMsg(capture.msg)
+			return
+		}
+		m = capture.msg
+	}
+
+	opt := request.IsEdns0()
+	if opt == nil || !opt.Do() || m.Rcode == dns.RcodeServerFailure {
+		w.WriteMsg(m)
+		return
+	}
+
+	if err := s.sign(m, question); err != nil {
+		s.Logger.Error("signing-failed", err, lager.Data{"name": question.Name})
+		fail := &dns.Msg{}
+		fail.SetRcode(request, dns.RcodeServerFailure)
+		w.WriteMsg(fail)
+		return
+	}
+
+	if existing := m.IsEdns0(); existing != nil {
+		existing.SetUDPSize(opt.UDPSize())
+		existing.SetDo()
+	} else {
+		m.SetEdns0(opt.UDPSize(), true)
+	}
+	w.WriteMsg(m)
+}
+
+func (s *Signer) zone() string {
+	return strings.ToLower(dns.Fqdn(s.Zone))
+}
+
+func (s *Signer) dnskeys() []dns.RR {
+	rrs := []dns.RR{}
+	for _, key := range s.Keys {
+		rrs = append(rrs, key.DNSKEY)
+	}
+	return rrs
+}
+
+func (s *Signer) sign(m *dns.Msg, question dns.Question) error {
+	inZone := dns.IsSubDomain(s.zone(), strings.ToLower(question.Name))
+	if inZone && len(m.Answer) == 0 && (m.Rcode == dns.RcodeNameError || m.Rcode == dns.RcodeSuccess) {
+		exists := m.Rcode == dns.RcodeSuccess
+		m.Rcode = dns.RcodeSuccess
+
+		soa := findSOA(m.Ns)
+		if soa == nil && s.OverlayZone != nil {
+			soa = s.OverlayZone.SOA()
+			m.Ns = append(m.Ns, soa)
+		}
+		m.Ns = append(m.Ns, s.denial(question, exists, negativeTTL(soa)))
+	}
+
+	var err error
+	if m.Answer, err = s.signSection(m.Answer); err != nil {
+		return err
+	}
+	m.Ns, err = s.signSection(m.Ns)
+	return err
+}
+
+// denial returns the NSEC record at the query name.  A name that does not
+// exist only has the NSEC and its signature; one that does lists the types
+// it has other than the one asked for.
+func (s *Signer) denial(question dns.Question, exists bool, ttl uint32) dns.RR {
+	types := []uint16{dns.TypeRRSIG, dns.TypeNSEC}
+	if exists {
+		for _, rrtype := range s.types(strings.ToLower(question.Name)) {
+			if rrtype != question.Qtype {
+				types = append(types, rrtype)
+			}
+		}
+	}
+	sort.Sort(byType(types))
+
+	return &dns.NSEC{
+		Hdr: dns.RR_Header{
+			Name:   question.Name,
+			Rrtype: dns.TypeNSEC,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		NextDomain: "\\000." + question.Name,
+		TypeBitMap: types,
+	}
+}
+
+// types returns the types name has.  Names the overlay zone does not know
+// are app names, which have A records.
+func (s *Signer) types(name string) []uint16 {
+	var types []uint16
+	if s.OverlayZone != nil {
+		types = s.OverlayZone.Types(name)
+	}
+	if len(types) == 0 {
+		if name == s.zone() {
+			types = []uint16{dns.TypeSOA, dns.TypeNS}
+		} else {
+			types = []uint16{dns.TypeA}
+		}
+	}
+	if name == s.zone() {
+		types = append(types, dns.TypeDNSKEY)
+	}
+	return types
+}
+
+func findSOA(authority []dns.RR) *dns.SOA {
+	for _, rr := range authority {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa
+		}
+	}
+	return nil
+}
+
+// negativeTTL is the lesser of the SOA record's TTL and its minimum, as in
+// RFC 2308.
+func negativeTTL(soa *dns.SOA) uint32 {
+	if soa == nil {
+		return defaultStaticTTL
+	}
+	if soa.Minttl < soa.Hdr.Ttl {
+		return soa.Minttl
+	}
+	return soa.Hdr.Ttl
+}
+
+func (s *Signer) signSection(rrs []dns.RR) ([]dns.RR, error) {
+	signed := []dns.RR{}
+	for _, rrset := range groupRRsets(rrs) {
+		signed = append(signed, rrset...)
+
+		name := strings.ToLower(rrset[0].Header().Name)
+		if !dns.IsSubDomain(s.zone(), name) {
+			continue
+		}
+
+		for _, key := range s.keysFor(rrset[0].Header().Rrtype) {
+			rrsig, err := s.signature(key, rrset)
+			if err != nil {
+				return nil, err
+			}
+			signed = append(signed, rrsig)
+		}
+	}
+	return signed, nil
+}
+
+// keysFor returns the key-signing keys for the DNSKEY RRset and the
+// zone-signing keys for everything else.  A single key signs both.
+func (s *Signer) keysFor(rrtype uint16) []*SigningKey {
+	ksks := []*SigningKey{}
+	zsks := []*SigningKey{}
+	for _, key := range s.Keys {
+		if key.DNSKEY.Flags&dns.SEP != 0 {
+			ksks = append(ksks, key)
+		} else {
+			zsks = append(zsks, key)
+		}
+	}
+
+	if len(ksks) == 0 || len(zsks) == 0 {
+		return s.Keys
+	}
+	if rrtype == dns.TypeDNSKEY {
+		return ksks
+	}
+	return zsks
+}
+
+func (s *Signer) signature(key *SigningKey, rrset []dns.RR) (*dns.RRSIG, error) {
+	cacheKey := signatureCacheKey(key, rrset)
+	now := time.Now()
+
+	cached, ok := s.cached(cacheKey)
+	if ok && now.Before(cached.refresh) {
+		s.Metrics.CacheHit("rrsig")
+		rrsig := *cached.rrsig
+		rrsig.Hdr.Name = rrset[0].Header().Name
+		return &rrsig, nil
+	}
+
+	s.Metrics.CacheMiss("rrsig")
+
+	validity := s.Validity
+	if validity == 0 {
+		validity = defaultSignatureValidity
+	}
+
+	hdr := rrset[0].Header()
+	rrsig := &dns.RRSIG{
+		Hdr: dns.RR_Header{
+			Name:   hdr.Name,
+			Rrtype: dns.TypeRRSIG,
+			Class:  dns.ClassINET,
+			Ttl:    hdr.Ttl,
+		},
+		TypeCovered: hdr.Rrtype,
+		Algorithm:   key.DNSKEY.Algorithm,
+		Labels:      uint8(dns.CountLabel(hdr.Name)),
+		OrigTtl:     hdr.Ttl,
+		Expiration:  uint32(now.Add(validity).Unix()),
+		Inception:   uint32(now.Add(-signatureInceptionSkew).Unix()),
+		KeyTag:      key.DNSKEY.KeyTag(),
+		SignerName:  s.zone(),
+	}
+	if err := rrsig.Sign(key.Private, rrset); err != nil {
+		return nil, err
+	}
+
+	s.store(cachedSignature{
+		key:     cacheKey,
+		name:    strings.ToLower(hdr.Name),
+		rrsig:   rrsig,
+		refresh: now.Add(validity / 2),
+	})
+
+	return rrsig, nil
+}
+
+// signatureCacheKey identifies an RRset independently of the order of its
+// records, which the Locality shuffles.
+func signatureCacheKey(key *SigningKey, rrset []dns.RR) string {
+	records := []string{}
+	for _, rr := range rrset {
+		records = append(records, strings.ToLower(rr.String()))
+	}
+	sort.Strings(records)
+	return fmt.Sprintf("%d|%s", key.DNSKEY.KeyTag(), strings.Join(records, "|"))
+}
+
+func (s *Signer) cached(cacheKey string) (cachedSignature, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	element, ok := s.cache[cacheKey]
+	if !ok {
+		return cachedSignature{}, false
+	}
+	s.lru.MoveToFront(element)
+	return element.Value.(cachedSignature), true
+}
+
+// store caches a signature, evicting the least recently used ones beyond
+// maxCachedSignatures.
+func (s *Signer) store(signature cachedSignature) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.cache == nil {
+		s.cache = map[string]*list.Element{}
+		s.lru = list.New()
+	}
+
+	if element, ok := s.cache[signature.key]; ok {
+		element.Value = signature
+		s.lru.MoveToFront(element)
+		return
+	}
+	s.cache[signature.key] = s.lru.PushFront(signature)
+
+	for s.lru.Len() > maxCachedSignatures {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.cache, oldest.Value.(cachedSignature).key)
+	}
+}
+
+func groupRRsets(rrs []dns.RR) [][]dns.RR {
+	rrsets := [][]dns.RR{}
+	index := map[string]int{}
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == dns.TypeRRSIG || rr.Header().Rrtype == dns.TypeOPT {
+			continue
+		}
+		key := fmt.Sprintf("%s|%d|%d", strings.ToLower(rr.Header().Name), rr.Header().Rrtype, rr.Header().Class)
+		if i, ok := index[key]; ok {
+			rrsets[i] = append(rrsets[i], rr)
+			continue
+		}
+		index[key] = len(rrsets)
+		rrsets = append(rrsets, []dns.RR{rr})
+	}
+	return rrsets
+}
+
+type capturingWriter struct {
+	dns.ResponseWriter
+	msg       *dns.Msg
+	forwarded bool
+}
+
+func (c *capturingWriter) WriteMsg(m *dns.Msg) error {
+	c.msg = m
+	return nil
+}
+
+func (c *capturingWriter) MarkForwarded() {
+	c.forwarded = true
+	markForwarded(c.ResponseWriter)
+}
+
+type forwardedMarker interface {
+	MarkForwarded()
+}
+
+// markForwarded tells a Signer or metrics handler up the chain that the
+// answer written to w comes from another server, so that it is not signed
+// and is counted as a fallthrough.
+func markForwarded(w dns.ResponseWriter) {
+	if marker, ok := w.(forwardedMarker); ok {
+		marker.MarkForwarded()
+	}
+}
+
+func (s *Signer) Entries() []CacheEntry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries := []CacheEntry{}
+	for _, element := range s.cache {
+		cached := element.Value.(cachedSignature)
+		entries = append(entries, CacheEntry{
+			Key:     cached.name + " " + dns.TypeToString[cached.rrsig.TypeCovered],
+			Value:   cached.rrsig.String(),
+			Expires: cached.refresh,
+		})
+	}
+	sort.Sort(byKey(entries))
+	return entries
+}
+
+// Flush drops the signatures cached for name, or every signature when name
+// is empty.
+func (s *Signer) Flush(name string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	name = strings.ToLower(name)
+	flushed := 0
+	for key, element := range s.cache {
+		if name == "" || dns.Fqdn(name) == element.Value.(cachedSignature).name {
+			s.lru.Remove(element)
+			delete(s.cache, key)
+			flushed++
+		}
+	}
+	return flushed
+}
@@ -0,0 +1,366 @@
+package resolver_test
+
+import (
+	"crypto"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func generateKey(flags uint16) *resolver.SigningKey {
+	dnskey := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: "potato.", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     flags,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	private, err := dnskey.Generate(256)
+	Expect(err).NotTo(HaveOccurred())
+	return &resolver.SigningKey{DNSKEY: dnskey, Private: private.(crypto.Signer)}
+}
+
+func signaturesFor(rrs []dns.RR, rrtype uint16) []*dns.RRSIG {
+	sigs := []*dns.RRSIG{}
+	for _, rr := range rrs {
+		if sig, ok := rr.(*dns.RRSIG); ok && sig.TypeCovered == rrtype {
+			sigs = append(sigs, sig)
+		}
+	}
+	return sigs
+}
+
+var _ = Describe("Signer", func() {
+	var (
+		signer         *resolver.Signer
+		zsk            *resolver.SigningKey
+		ksk            *resolver.SigningKey
+		nextHandler    *fakes.Handler
+		responseWriter *fakes.ResponseWriter
+		request        *dns.Msg
+		nextResponse   *dns.Msg
+		aRecord        *dns.A
+	)
+
+	BeforeEach(func() {
+		zsk = generateKey(dns.ZONE)
+		ksk = generateKey(dns.ZONE | dns.SEP)
+
+		aRecord = &dns.A{
+			Hdr: dns.RR_Header{Name: "app-a.potato.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 42},
+			A:   net.ParseIP("10.0.0.1"),
+		}
+
+		request = &dns.Msg{}
+		request.SetQuestion("app-a.potato.", dns.TypeA)
+		request.SetEdns0(4096, true)
+
+		nextResponse = &dns.Msg{}
+		nextResponse.SetReply(request)
+		nextResponse.Answer = []dns.RR{aRecord}
+
+		nextHandler = &fakes.Handler{}
+		nextHandler.ServeDNSStub = func(w dns.ResponseWriter, r *dns.Msg) {
+			w.WriteMsg(nextResponse)
+		}
+
+		responseWriter = &fakes.ResponseWriter{}
+
+		signer = &resolver.Signer{
+			Logger: lagertest.NewTestLogger("test"),
+			Zone:   "potato",
+			Keys:   []*resolver.SigningKey{ksk, zsk},
+			Next:   nextHandler,
+		}
+	})
+
+	It("signs answers with the zone-signing key", func() {
+		signer.ServeDNS(responseWriter, request)
+
+		Expect(responseWriter.WriteMsgCallCount()).To(Equal(1))
+		m := responseWriter.WriteMsgArgsForCall(0)
+		Expect(m.Answer).To(HaveLen(2))
+
+		sigs := signaturesFor(m.Answer, dns.TypeA)
+		Expect(sigs).To(HaveLen(1))
+		Expect(sigs[0].KeyTag).To(Equal(zsk.DNSKEY.KeyTag()))
+		Expect(sigs[0].SignerName).To(Equal("potato."))
+		Expect(sigs[0].Verify(zsk.DNSKEY, []dns.RR{aRecord})).To(Succeed())
+		Expect(sigs[0].ValidityPeriod(time.Now())).To(BeTrue())
+
+		Expect(m.IsEdns0()).NotTo(BeNil())
+		Expect(m.IsEdns0().Do()).To(BeTrue())
+	})
+
+	It("reuses cached signatures for the same RRset", func() {
+		signer.ServeDNS(responseWriter, request)
+		signer.ServeDNS(responseWriter, request)
+
+		first := signaturesFor(responseWriter.WriteMsgArgsForCall(0).Answer, dns.TypeA)[0]
+		second := signaturesFor(responseWriter.WriteMsgArgsForCall(1).Answer, dns.TypeA)[0]
+		Expect(second.Signature).To(Equal(first.Signature))
+	})
+
+	It("reuses cached signatures when the RRset comes in another order", func() {
+		other := &dns.A{
+			Hdr: dns.RR_Header{Name: "app-a.potato.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 42},
+			A:   net.ParseIP("10.0.0.2"),
+		}
+		nextResponse.Answer = []dns.RR{aRecord, other}
+		signer.ServeDNS(responseWriter, request)
+		nextResponse.Answer = []dns.RR{other, aRecord}
+		signer.ServeDNS(responseWriter, request)
+
+		first := signaturesFor(responseWriter.WriteMsgArgsForCall(0).Answer, dns.TypeA)[0]
+		second := signaturesFor(responseWriter.WriteMsgArgsForCall(1).Answer, dns.TypeA)[0]
+		Expect(second.Signature).To(Equal(first.Signature))
+		Expect(signer.Entries()).To(HaveLen(1))
+	})
+
+	It("lists and flushes cached signatures by name", func() {
+		signer.ServeDNS(responseWriter, request)
+
+		entries := signer.Entries()
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Key).To(Equal("app-a.potato. A"))
+
+		Expect(signer.Flush("other.potato.")).To(Equal(0))
+		Expect(signer.Flush("APP-A.potato")).To(Equal(1))
+		Expect(signer.Entries()).To(BeEmpty())
+	})
+
+	It("keeps a single OPT record when the answer already has one", func() {
+		nextResponse.SetEdns0(1232, false)
+
+		signer.ServeDNS(responseWriter, request)
+
+		m := responseWriter.WriteMsgArgsForCall(0)
+		opts := 0
+		for _, rr := range m.Extra {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				opts++
+			}
+		}
+		Expect(opts).To(Equal(1))
+		Expect(m.IsEdns0().Do()).To(BeTrue())
+		Expect(m.IsEdns0().UDPSize()).To(Equal(uint16(4096)))
+	})
+
+	Context("when the client does not set the DO bit", func() {
+		BeforeEach(func() {
+			request = &dns.Msg{}
+			request.SetQuestion("app-a.potato.", dns.TypeA)
+		})
+
+		It("passes the answer through unsigned", func() {
+			signer.ServeDNS(responseWriter, request)
+
+			m := responseWriter.WriteMsgArgsForCall(0)
+			Expect(m.Answer).To(Equal([]dns.RR{aRecord}))
+		})
+	})
+
+	Context("when asked for the DNSKEY RRset at the apex", func() {
+		BeforeEach(func() {
+			request = &dns.Msg{}
+			request.SetQuestion("potato.", dns.TypeDNSKEY)
+			request.SetEdns0(4096, true)
+		})
+
+		It("answers with the keys signed by the key-signing key", func() {
+			signer.ServeDNS(responseWriter, request)
+
+			Expect(nextHandler.ServeDNSCallCount()).To(Equal(0))
+			m := responseWriter.WriteMsgArgsForCall(0)
+			Expect(m.Authoritative).To(BeTrue())
+			Expect(m.Answer).To(ContainElement(ksk.DNSKEY))
+			Expect(m.Answer).To(ContainElement(zsk.DNSKEY))
+
+			sigs := signaturesFor(m.Answer, dns.TypeDNSKEY)
+			Expect(sigs).To(HaveLen(1))
+			Expect(sigs[0].KeyTag).To(Equal(ksk.DNSKEY.KeyTag()))
+			Expect(sigs[0].Verify(ksk.DNSKEY, []dns.RR{ksk.DNSKEY, zsk.DNSKEY})).To(Succeed())
+		})
+	})
+
+	Context("when the name does not exist", func() {
+		BeforeEach(func() {
+			nextResponse.Answer = nil
+			nextResponse.Rcode = dns.RcodeNameError
+		})
+
+		It("answers NODATA with a signed NSEC record at the query name", func() {
+			signer.ServeDNS(responseWriter, request)
+
+			m := responseWriter.WriteMsgArgsForCall(0)
+			Expect(m.Rcode).To(Equal(dns.RcodeSuccess))
+			Expect(m.Answer).To(BeEmpty())
+			Expect(m.Ns).To(HaveLen(2))
+
+			nsec, ok := m.Ns[0].(*dns.NSEC)
+			Expect(ok).To(BeTrue())
+			Expect(nsec.Hdr.Name).To(Equal("app-a.potato."))
+			Expect(nsec.NextDomain).To(Equal("\\000.app-a.potato."))
+			Expect(nsec.TypeBitMap).NotTo(ContainElement(dns.TypeA))
+
+			sigs := signaturesFor(m.Ns, dns.TypeNSEC)
+			Expect(sigs).To(HaveLen(1))
+			Expect(sigs[0].Verify(zsk.DNSKEY, []dns.RR{nsec})).To(Succeed())
+		})
+	})
+
+	Context("when the name has no records of the requested type", func() {
+		BeforeEach(func() {
+			request.SetQuestion("app-a.potato.", dns.TypeTXT)
+			nextResponse.Answer = nil
+		})
+
+		It("lists the types the name has in the NSEC record", func() {
+			signer.ServeDNS(responseWriter, request)
+
+			m := responseWriter.WriteMsgArgsForCall(0)
+			nsec, ok := m.Ns[0].(*dns.NSEC)
+			Expect(ok).To(BeTrue())
+			Expect(nsec.TypeBitMap).To(Equal([]uint16{dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC}))
+		})
+	})
+
+	Context("when an overlay zone is given", func() {
+		var zone *resolver.OverlayZone
+
+		BeforeEach(func() {
+			zone = resolver.NewOverlayZone("potato", 30)
+			zone.Update([]resolver.Instance{{App: "app-a", IP: "10.0.0.1"}})
+			signer.OverlayZone = zone
+
+			nextResponse.Answer = nil
+			nextResponse.Rcode = dns.RcodeNameError
+		})
+
+		It("carries the signed SOA record in negative answers", func() {
+			signer.ServeDNS(responseWriter, request)
+
+			m := responseWriter.WriteMsgArgsForCall(0)
+			soa, ok := m.Ns[0].(*dns.SOA)
+			Expect(ok).To(BeTrue())
+			Expect(soa.Serial).To(Equal(zone.SOA().Serial))
+
+			sigs := signaturesFor(m.Ns, dns.TypeSOA)
+			Expect(sigs).To(HaveLen(1))
+			Expect(sigs[0].Verify(zsk.DNSKEY, []dns.RR{soa})).To(Succeed())
+
+			nsec := m.Ns[2].(*dns.NSEC)
+			Expect(nsec.Hdr.Ttl).To(Equal(uint32(30)))
+			Expect(nsec.TypeBitMap).To(Equal([]uint16{dns.TypeRRSIG, dns.TypeNSEC}))
+		})
+
+		It("lists the types the zone has at the apex", func() {
+			request.SetQuestion("potato.", dns.TypeTXT)
+			nextResponse.Rcode = dns.RcodeSuccess
+
+			signer.ServeDNS(responseWriter, request)
+
+			m := responseWriter.WriteMsgArgsForCall(0)
+			nsec := m.Ns[2].(*dns.NSEC)
+			Expect(nsec.TypeBitMap).To(Equal([]uint16{dns.TypeNS, dns.TypeSOA, dns.TypeRRSIG, dns.TypeNSEC, dns.TypeDNSKEY}))
+		})
+	})
+
+	Context("when the answer is outside the zone", func() {
+		BeforeEach(func() {
+			request = &dns.Msg{}
+			request.SetQuestion("example.com.", dns.TypeA)
+			request.SetEdns0(4096, true)
+			nextResponse.Answer = []dns.RR{&dns.A{
+				Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 42},
+				A:   net.ParseIP("10.0.0.9"),
+			}}
+		})
+
+		It("does not sign it", func() {
+			signer.ServeDNS(responseWriter, request)
+
+			m := responseWriter.WriteMsgArgsForCall(0)
+			Expect(m.Answer).To(HaveLen(1))
+		})
+	})
+
+	Context("when the next handler forwarded the query", func() {
+		BeforeEach(func() {
+			request = &dns.Msg{}
+			request.SetQuestion("www.delegated.potato.", dns.TypeA)
+			request.SetEdns0(4096, true)
+			nextResponse = &dns.Msg{}
+			nextResponse.SetReply(request)
+			nextHandler.ServeDNSStub = func(w dns.ResponseWriter, r *dns.Msg) {
+				w.(interface {
+					MarkForwarded()
+				}).MarkForwarded()
+				w.WriteMsg(nextResponse)
+			}
+		})
+
+		It("passes the answer through without signing or denying it", func() {
+			signer.ServeDNS(responseWriter, request)
+
+			m := responseWriter.WriteMsgArgsForCall(0)
+			Expect(m).To(Equal(nextResponse))
+			Expect(m.Ns).To(BeEmpty())
+		})
+	})
+
+	Context("when the request is a zone transfer", func() {
+		BeforeEach(func() {
+			request = &dns.Msg{}
+			request.SetAxfr("potato.")
+		})
+
+		It("hands it to the next handler untouched", func() {
+			signer.ServeDNS(responseWriter, request)
+
+			Expect(nextHandler.ServeDNSCallCount()).To(Equal(1))
+			w, r := nextHandler.ServeDNSArgsForCall(0)
+			Expect(w).To(Equal(responseWriter))
+			Expect(r).To(Equal(request))
+		})
+	})
+
+	Describe("LoadSigningKey", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "signer")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("loads a key pair written by dnssec-keygen", func() {
+			base := filepath.Join(dir, "Kpotato.+013+12345")
+			Expect(ioutil.WriteFile(base+".key", []byte(zsk.DNSKEY.String()+"\n"), 0600)).To(Succeed())
+			Expect(ioutil.WriteFile(base+".private", []byte(zsk.DNSKEY.PrivateKeyString(zsk.Private)), 0600)).To(Succeed())
+
+			key, err := resolver.LoadSigningKey(base)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(key.DNSKEY.KeyTag()).To(Equal(zsk.DNSKEY.KeyTag()))
+			Expect(key.Private).NotTo(BeNil())
+		})
+
+		It("returns an error when the files are missing", func() {
+			_, err := resolver.LoadSigningKey(filepath.Join(dir, "missing"))
+			Expect(err).To(MatchError(ContainSubstring("open public key")))
+		})
+	})
+})
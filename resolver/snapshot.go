@@ -0,0 +1,139 @@
+package resolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/pivotal-golang/lager"
+)
+
+type SnapshotConfig struct {
+	Path     string        `yaml:"path"`
+	Interval time.Duration `yaml:"interval"`
+	TTL      int           `yaml:"ttl"`
+}
+
+// Snapshot keeps the last known container listing in a file at Path.  It
+// is handed every listing the daemon answers through Record, and writes the
+// latest to the file every Interval if it changed, so it costs the daemon
+// no listings of its own.  While the daemon fails, the listing recorded
+// last, or loaded from the file, can be served in its place.
+type Snapshot struct {
+	Logger   lager.Logger
+	Path     string
+	Interval time.Duration
+
+	mutex      sync.RWMutex
+	containers []models.Container
+	saved      []models.Container
+	recorded   bool
+}
+
+// Load reads the listing written by a previous run.
+func (s *Snapshot) Load() error {
+	data, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return fmt.Errorf("read snapshot: %s", err)
+	}
+
+	var containers []models.Container
+	if err := json.Unmarshal(data, &containers); err != nil {
+		return fmt.Errorf("parse snapshot: %s", err)
+	}
+
+	s.mutex.Lock()
+	s.containers = containers
+	s.saved = containers
+	s.mutex.Unlock()
+
+	s.Logger.Info("snapshot-loaded", lager.Data{"path": s.Path, "containers": len(containers)})
+	return nil
+}
+
+// Record replaces the listing with one the daemon answered with.
+func (s *Snapshot) Record(containers []models.Container) {
+	s.mutex.Lock()
+	first := !s.recorded
+	s.containers = containers
+	s.recorded = true
+	s.mutex.Unlock()
+
+	if first {
+		s.Logger.Info("snapshot-recorded", lager.Data{"containers": len(containers)})
+	}
+}
+
+// Save writes the recorded listing to Path if it changed since it was last
+// written or loaded.
+func (s *Snapshot) Save() error {
+	s.mutex.RLock()
+	containers := s.containers
+	changed := s.recorded && !reflect.DeepEqual(containers, s.saved)
+	s.mutex.RUnlock()
+
+	if !changed {
+		return nil
+	}
+	if err := s.save(containers); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.saved = containers
+	s.mutex.Unlock()
+	return nil
+}
+
+func (s *Snapshot) save(containers []models.Container) error {
+	data, err := json.Marshal(containers)
+	if err != nil {
+		return fmt.Errorf("marshal snapshot: %s", err)
+	}
+	if err := writeFileAtomic(s.Path, data); err != nil {
+		return fmt.Errorf("write snapshot: %s", err)
+	}
+
+	s.Logger.Info("snapshot-saved", lager.Data{"path": s.Path, "containers": len(containers)})
+	return nil
+}
+
+// Run saves the recorded listing every Interval, and once more when it is
+// signalled.
+func (s *Snapshot) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	close(ready)
+
+	for {
+		select {
+		case <-signals:
+			if err := s.Save(); err != nil {
+				s.Logger.Error("snapshot-save-failed", err)
+			}
+			return nil
+		case <-ticker.C:
+			if err := s.Save(); err != nil {
+				s.Logger.Error("snapshot-save-failed", err)
+			}
+		}
+	}
+}
+
+// AppContainers returns the app's containers from the last recorded or
+// loaded listing.
+func (s *Snapshot) AppContainers(appGUID string) ([]models.Container, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.containers == nil {
+		return nil, false
+	}
+	return filterContainers(s.containers, func(c models.Container) bool { return c.App == appGUID }), true
+}
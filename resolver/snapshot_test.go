@@ -0,0 +1,149 @@
+package resolver_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-daemon/models"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Snapshot", func() {
+	var (
+		dir        string
+		logger     *lagertest.TestLogger
+		snapshot   *resolver.Snapshot
+		containers []models.Container
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "snapshot")
+		Expect(err).NotTo(HaveOccurred())
+
+		containers = []models.Container{
+			{ID: "container-1", App: "some-app-guid", IP: "10.0.0.1"},
+			{ID: "container-2", App: "some-other-app-guid", IP: "10.0.0.2"},
+		}
+		logger = lagertest.NewTestLogger("test")
+		snapshot = &resolver.Snapshot{
+			Logger: logger,
+			Path:   filepath.Join(dir, "snapshot.json"),
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	newSnapshot := func() *resolver.Snapshot {
+		return &resolver.Snapshot{
+			Logger: logger,
+			Path:   snapshot.Path,
+		}
+	}
+
+	It("saves recorded listings for the next run to load", func() {
+		snapshot.Record(containers)
+		Expect(snapshot.Save()).To(Succeed())
+
+		next := newSnapshot()
+		Expect(next.Load()).To(Succeed())
+		instances, ok := next.AppContainers("some-app-guid")
+		Expect(ok).To(BeTrue())
+		Expect(instances).To(Equal([]models.Container{containers[0]}))
+		Expect(logger).To(gbytes.Say("snapshot-loaded.*containers.*2"))
+	})
+
+	It("leaves no temporary files behind", func() {
+		snapshot.Record(containers)
+		Expect(snapshot.Save()).To(Succeed())
+
+		files, err := ioutil.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		Expect(files[0].Name()).To(Equal("snapshot.json"))
+	})
+
+	It("only rewrites the file when the listing changed", func() {
+		snapshot.Record(containers)
+		Expect(snapshot.Save()).To(Succeed())
+		Expect(os.Remove(snapshot.Path)).To(Succeed())
+
+		snapshot.Record(append([]models.Container{}, containers...))
+		Expect(snapshot.Save()).To(Succeed())
+		Expect(snapshot.Path).NotTo(BeAnExistingFile())
+
+		snapshot.Record(containers[:1])
+		Expect(snapshot.Save()).To(Succeed())
+		Expect(snapshot.Path).To(BeAnExistingFile())
+	})
+
+	It("does not rewrite the loaded file before a listing is recorded", func() {
+		snapshot.Record(containers)
+		Expect(snapshot.Save()).To(Succeed())
+		next := newSnapshot()
+		Expect(next.Load()).To(Succeed())
+		Expect(os.Remove(snapshot.Path)).To(Succeed())
+
+		Expect(next.Save()).To(Succeed())
+		Expect(snapshot.Path).NotTo(BeAnExistingFile())
+	})
+
+	It("serves the latest recorded listing in place of the loaded one", func() {
+		snapshot.Record(containers)
+		Expect(snapshot.Save()).To(Succeed())
+		next := newSnapshot()
+		Expect(next.Load()).To(Succeed())
+
+		moved := []models.Container{{ID: "container-1", App: "some-app-guid", IP: "10.0.0.3"}}
+		next.Record(moved)
+		instances, ok := next.AppContainers("some-app-guid")
+		Expect(ok).To(BeTrue())
+		Expect(instances).To(Equal(moved))
+	})
+
+	It("has nothing to serve without a snapshot file", func() {
+		Expect(snapshot.Load()).To(MatchError(HavePrefix("read snapshot:")))
+
+		_, ok := snapshot.AppContainers("some-app-guid")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("rejects corrupt snapshot files", func() {
+		Expect(ioutil.WriteFile(snapshot.Path, []byte("potato"), 0600)).To(Succeed())
+
+		Expect(snapshot.Load()).To(MatchError(HavePrefix("parse snapshot:")))
+	})
+
+	It("saves every interval while running", func() {
+		snapshot.Interval = 10 * time.Millisecond
+		process := ifrit.Invoke(snapshot)
+		defer func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive())
+		}()
+
+		snapshot.Record(containers)
+		Eventually(snapshot.Path).Should(BeAnExistingFile())
+	})
+
+	It("saves once more when signalled", func() {
+		snapshot.Interval = time.Hour
+		process := ifrit.Invoke(snapshot)
+
+		snapshot.Record(containers)
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+
+		Expect(snapshot.Path).To(BeAnExistingFile())
+	})
+})
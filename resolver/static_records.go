@@ -0,0 +1,234 @@
+package resolver
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager"
+)
+
+const (
+	defaultStaticTTL = 60
+	maxCNAMEChain    = 8
+)
+
+// StaticRecords answers the names listed in the file at Path and passes
+// other names to Next.  A CNAME chain that leaves the static records for a
+// name under Suffix is followed through Next, so that the response code is
+// that of the final target (RFC 6604); targets elsewhere are left for the
+// client to follow.
+type StaticRecords struct {
+	Logger       lager.Logger
+	Path         string
+	PollInterval time.Duration
+	Suffix       string
+	Next         dns.Handler
+
+	mutex   sync.RWMutex
+	records map[string][]dns.RR
+	file    watchedFile
+}
+
+// Load replaces the served records with the contents of Path.  If the file
+// cannot be read or fails validation, the previously loaded records are kept.
+func (s *StaticRecords) Load() error {
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return fmt.Errorf("stat static records: %s", err)
+	}
+
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return fmt.Errorf("open static records: %s", err)
+	}
+	defer f.Close()
+
+	records, err := ParseStaticRecords(f)
+	if err != nil {
+		return fmt.Errorf("parse static records: %s", err)
+	}
+
+	s.mutex.Lock()
+	s.records = records
+	s.mutex.Unlock()
+	s.file.loaded(info)
+
+	s.Logger.Info("static-records-loaded", lager.Data{"path": s.Path, "names": len(records)})
+
+	return nil
+}
+
+func (s *StaticRecords) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	return s.file.poll(s.Logger, "static-records", s.Path, s.PollInterval, s.Load, signals, ready)
+}
+
+func (s *StaticRecords) lookup(name string) ([]dns.RR, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	rrs, ok := s.records[strings.ToLower(name)]
+	return rrs, ok
+}
+
+func (s *StaticRecords) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
+	question := request.Question[0]
+
+	rrs, ok := s.lookup(question.Name)
+	if !ok {
+		s.Next.ServeDNS(w, request)
+		return
+	}
+
+	logger := s.Logger.Session("serve-dns", lager.Data{"name": question.Name})
+
+	m := &dns.Msg{}
+	m.SetReply(request)
+	m.Authoritative = true
+
+	name := question.Name
+	for i := 0; i < maxCNAMEChain; i++ {
+		cname, answers := matchRecords(rrs, name, question.Qtype)
+		m.Answer = append(m.Answer, answers...)
+		if cname == nil {
+			break
+		}
+
+		m.Answer = append(m.Answer, cname)
+		name = cname.Target
+		if rrs, ok = s.lookup(name); !ok {
+			s.chase(w, m, request, name)
+			break
+		}
+	}
+
+	logger.Info("static-response", lager.Data{"answer": m.Answer, "rcode": dns.RcodeToString[m.Rcode]})
+
+	w.WriteMsg(m)
+}
+
+// chase adds Next's answer for a CNAME target under Suffix, and its rcode.
+func (s *StaticRecords) chase(w dns.ResponseWriter, m *dns.Msg, request *dns.Msg, target string) {
+	if s.Suffix == "" || !dns.IsSubDomain(strings.ToLower(dns.Fqdn(s.Suffix)), strings.ToLower(target)) {
+		return
+	}
+
+	query := request.Copy()
+	query.Question[0].Name = target
+	capture := &capturingWriter{ResponseWriter: w}
+	s.Next.ServeDNS(capture, query)
+	if capture.msg == nil {
+		return
+	}
+
+	m.Answer = append(m.Answer, capture.msg.Answer...)
+	m.Rcode = capture.msg.Rcode
+}
+
+func matchRecords(rrs []dns.RR, name string, qtype uint16) (*dns.CNAME, []dns.RR) {
+	answers := []dns.RR{}
+	for _, rr := range rrs {
+		if cname, ok := rr.(*dns.CNAME); ok && qtype != dns.TypeCNAME {
+			c := *cname
+			c.Hdr.Name = name
+			return &c, nil
+		}
+		if rr.Header().Rrtype == qtype || qtype == dns.TypeANY {
+			answer := dns.Copy(rr)
+			answer.Header().Name = name
+			answers = append(answers, answer)
+		}
+	}
+	return nil, answers
+}
+
+// ParseStaticRecords reads one record per line.  A line is either a
+// hosts-file entry ("10.0.0.5 db.example legacy-db.example") or a resource
+// record in master-file format ("db.example. 60 IN A 10.0.0.5").  Blank lines
+// and lines starting with '#' are ignored.
+func ParseStaticRecords(r io.Reader) (map[string][]dns.RR, error) {
+	records := map[string][]dns.RR{}
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rrs, err := parseStaticLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+		}
+
+		for _, rr := range rrs {
+			name := strings.ToLower(rr.Header().Name)
+			records[name] = append(records[name], rr)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for name, rrs := range records {
+		for _, rr := range rrs {
+			if _, ok := rr.(*dns.CNAME); ok && len(rrs) > 1 {
+				return nil, fmt.Errorf("%s: CNAME cannot coexist with other records", name)
+			}
+		}
+	}
+
+	return records, nil
+}
+
+func parseStaticLine(line string) ([]dns.RR, error) {
+	fields := strings.Fields(line)
+
+	if ip := net.ParseIP(fields[0]); ip != nil {
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("no names for address %s", fields[0])
+		}
+
+		rrs := []dns.RR{}
+		for _, name := range fields[1:] {
+			if _, ok := dns.IsDomainName(name); !ok {
+				return nil, fmt.Errorf("invalid name %q", name)
+			}
+			hdr := dns.RR_Header{
+				Name:  dns.Fqdn(name),
+				Class: dns.ClassINET,
+				Ttl:   defaultStaticTTL,
+			}
+			if ip.To4() != nil {
+				hdr.Rrtype = dns.TypeA
+				rrs = append(rrs, &dns.A{Hdr: hdr, A: ip.To4()})
+			} else {
+				hdr.Rrtype = dns.TypeAAAA
+				rrs = append(rrs, &dns.AAAA{Hdr: hdr, AAAA: ip})
+			}
+		}
+		return rrs, nil
+	}
+
+	rr, err := dns.NewRR(line)
+	if err != nil {
+		return nil, err
+	}
+	if rr == nil {
+		return nil, fmt.Errorf("no record found")
+	}
+
+	switch rr.Header().Rrtype {
+	case dns.TypeA, dns.TypeAAAA, dns.TypeCNAME, dns.TypeSRV, dns.TypeTXT:
+	default:
+		return nil, fmt.Errorf("unsupported record type %s", dns.TypeToString[rr.Header().Rrtype])
+	}
+
+	return []dns.RR{rr}, nil
+}
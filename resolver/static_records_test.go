@@ -0,0 +1,245 @@
+package resolver_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("StaticRecords", func() {
+	var (
+		staticRecords  *resolver.StaticRecords
+		nextHandler    *fakes.Handler
+		responseWriter *fakes.ResponseWriter
+		request        *dns.Msg
+		fakeLogger     *lagertest.TestLogger
+		tempDir        string
+		recordsPath    string
+	)
+
+	writeRecords := func(contents string) {
+		Expect(ioutil.WriteFile(recordsPath, []byte(contents), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "static-records")
+		Expect(err).NotTo(HaveOccurred())
+		recordsPath = filepath.Join(tempDir, "records")
+
+		writeRecords(`
+# pinned names
+10.0.0.5 db.potato legacy-db.potato
+fd00::5 db6.potato
+alias.potato. 30 IN CNAME db.potato.
+external.potato. 30 IN CNAME www.example.com.
+overlay.potato. 30 IN CNAME some-app-guid.potato.
+_sql._tcp.potato. 30 IN SRV 10 0 5432 db.potato.
+info.potato. 30 IN TXT "hello"
+`)
+
+		fakeLogger = lagertest.NewTestLogger("test")
+		nextHandler = &fakes.Handler{}
+		staticRecords = &resolver.StaticRecords{
+			Logger:       fakeLogger,
+			Path:         recordsPath,
+			PollInterval: 10 * time.Millisecond,
+			Suffix:       "potato",
+			Next:         nextHandler,
+		}
+		Expect(staticRecords.Load()).To(Succeed())
+
+		request = &dns.Msg{}
+		responseWriter = &fakes.ResponseWriter{}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	answerFor := func(name string, qtype uint16) []dns.RR {
+		request.SetQuestion(name, qtype)
+		staticRecords.ServeDNS(responseWriter, request)
+		Expect(responseWriter.WriteMsgCallCount()).To(BeNumerically(">", 0))
+		return responseWriter.WriteMsgArgsForCall(responseWriter.WriteMsgCallCount() - 1).Answer
+	}
+
+	It("answers hosts-file style entries", func() {
+		answer := answerFor("legacy-db.potato.", dns.TypeA)
+
+		Expect(answer).To(HaveLen(1))
+		Expect(answer[0].(*dns.A).A.String()).To(Equal("10.0.0.5"))
+		Expect(answer[0].Header().Name).To(Equal("legacy-db.potato."))
+	})
+
+	It("answers AAAA entries", func() {
+		answer := answerFor("db6.potato.", dns.TypeAAAA)
+
+		Expect(answer).To(HaveLen(1))
+		Expect(answer[0].(*dns.AAAA).AAAA.String()).To(Equal("fd00::5"))
+	})
+
+	It("answers SRV and TXT records", func() {
+		Expect(answerFor("_sql._tcp.potato.", dns.TypeSRV)[0].(*dns.SRV).Port).To(Equal(uint16(5432)))
+		Expect(answerFor("info.potato.", dns.TypeTXT)[0].(*dns.TXT).Txt).To(Equal([]string{"hello"}))
+	})
+
+	It("matches names case-insensitively", func() {
+		Expect(answerFor("DB.Potato.", dns.TypeA)).To(HaveLen(1))
+	})
+
+	It("sets the authoritative bit", func() {
+		answerFor("db.potato.", dns.TypeA)
+
+		Expect(responseWriter.WriteMsgArgsForCall(0).Authoritative).To(BeTrue())
+	})
+
+	It("follows CNAMEs within the static records", func() {
+		answer := answerFor("alias.potato.", dns.TypeA)
+
+		Expect(answer).To(HaveLen(2))
+		Expect(answer[0].(*dns.CNAME).Target).To(Equal("db.potato."))
+		Expect(answer[1].(*dns.A).A.String()).To(Equal("10.0.0.5"))
+	})
+
+	It("answers with only the CNAME when the target is not static", func() {
+		answer := answerFor("external.potato.", dns.TypeA)
+
+		Expect(answer).To(HaveLen(1))
+		Expect(answer[0].(*dns.CNAME).Target).To(Equal("www.example.com."))
+		Expect(nextHandler.ServeDNSCallCount()).To(Equal(0))
+	})
+
+	Context("when the target is not static but under the suffix", func() {
+		It("follows the CNAME through the next handler", func() {
+			nextHandler.ServeDNSStub = func(w dns.ResponseWriter, r *dns.Msg) {
+				m := &dns.Msg{}
+				m.SetReply(r)
+				m.Answer = []dns.RR{testRR("some-app-guid.potato. 5 IN A 10.255.0.9")}
+				w.WriteMsg(m)
+			}
+
+			answer := answerFor("overlay.potato.", dns.TypeA)
+
+			Expect(answer).To(HaveLen(2))
+			Expect(answer[1].(*dns.A).A.String()).To(Equal("10.255.0.9"))
+			_, r := nextHandler.ServeDNSArgsForCall(0)
+			Expect(r.Question[0].Name).To(Equal("some-app-guid.potato."))
+			Expect(request.Question[0].Name).To(Equal("overlay.potato."))
+		})
+
+		It("answers with the target's rcode, such as NXDOMAIN", func() {
+			nextHandler.ServeDNSStub = func(w dns.ResponseWriter, r *dns.Msg) {
+				m := &dns.Msg{}
+				m.SetRcode(r, dns.RcodeNameError)
+				w.WriteMsg(m)
+			}
+
+			answer := answerFor("overlay.potato.", dns.TypeA)
+
+			Expect(answer).To(HaveLen(1))
+			resp := responseWriter.WriteMsgArgsForCall(0)
+			Expect(resp.Rcode).To(Equal(dns.RcodeNameError))
+			Expect(resp.Question[0].Name).To(Equal("overlay.potato."))
+		})
+	})
+
+	It("answers NODATA when the name has no records of the requested type", func() {
+		answer := answerFor("db.potato.", dns.TypeMX)
+
+		Expect(answer).To(BeEmpty())
+		Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeSuccess))
+		Expect(nextHandler.ServeDNSCallCount()).To(Equal(0))
+	})
+
+	Context("when the name is not a static record", func() {
+		It("passes the request to the next handler", func() {
+			request.SetQuestion("some-app-guid.potato.", dns.TypeA)
+			staticRecords.ServeDNS(responseWriter, request)
+
+			Expect(responseWriter.WriteMsgCallCount()).To(Equal(0))
+			Expect(nextHandler.ServeDNSCallCount()).To(Equal(1))
+			w, r := nextHandler.ServeDNSArgsForCall(0)
+			Expect(w).To(Equal(responseWriter))
+			Expect(r).To(Equal(request))
+		})
+	})
+
+	Describe("Load", func() {
+		Context("when the file is invalid", func() {
+			BeforeEach(func() {
+				writeRecords("db.potato. 30 IN MX 10 mail.potato.\n")
+			})
+
+			It("returns a meaningful error and keeps the previous records", func() {
+				Expect(staticRecords.Load()).To(MatchError(ContainSubstring("line 1: unsupported record type MX")))
+				Expect(answerFor("db.potato.", dns.TypeA)).To(HaveLen(1))
+			})
+		})
+
+		Context("when the file does not exist", func() {
+			BeforeEach(func() {
+				staticRecords.Path = filepath.Join(tempDir, "missing")
+			})
+
+			It("returns an error", func() {
+				Expect(staticRecords.Load()).To(MatchError(ContainSubstring("stat static records")))
+			})
+		})
+	})
+
+	Describe("Run", func() {
+		var process ifrit.Process
+
+		BeforeEach(func() {
+			process = ifrit.Invoke(staticRecords)
+		})
+
+		AfterEach(func() {
+			ginkgomon.Interrupt(process)
+		})
+
+		It("reloads the records when the file changes", func() {
+			writeRecords("10.0.0.6 db.potato new.potato\n")
+
+			Eventually(func() int {
+				request.SetQuestion("new.potato.", dns.TypeA)
+				staticRecords.ServeDNS(responseWriter, request)
+				return responseWriter.WriteMsgCallCount()
+			}).Should(BeNumerically(">", 0))
+			Expect(answerFor("db.potato.", dns.TypeA)[0].(*dns.A).A.String()).To(Equal("10.0.0.6"))
+		})
+
+		It("logs validation errors and keeps serving the previous records", func() {
+			writeRecords("not a valid line at all\n")
+
+			Eventually(fakeLogger).Should(gbytes.Say("static-records-reload-failed"))
+			Expect(answerFor("db.potato.", dns.TypeA)).To(HaveLen(1))
+		})
+	})
+})
+
+var _ = Describe("ParseStaticRecords", func() {
+	It("rejects CNAMEs that share a name with other records", func() {
+		_, err := resolver.ParseStaticRecords(strings.NewReader("10.0.0.5 db.potato\ndb.potato. IN CNAME other.potato.\n"))
+		Expect(err).To(MatchError("db.potato.: CNAME cannot coexist with other records"))
+	})
+
+	It("rejects hosts entries without names", func() {
+		_, err := resolver.ParseStaticRecords(strings.NewReader("10.0.0.5\n"))
+		Expect(err).To(MatchError("line 1: no names for address 10.0.0.5"))
+	})
+})
@@ -0,0 +1,114 @@
+package resolver
+
+import (
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager"
+)
+
+// UpdateHandler applies TSIG-authenticated RFC 2136 updates under the
+// overlay suffix to a Registry.  Other messages go to Next.
+type UpdateHandler struct {
+	Logger       lager.Logger
+	Registry     *Registry
+	Suffix       string
+	DefaultLease time.Duration
+	MaxLease     time.Duration
+	Next         dns.Handler
+}
+
+func (u *UpdateHandler) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
+	if request.Opcode != dns.OpcodeUpdate {
+		u.Next.ServeDNS(w, request)
+		return
+	}
+
+	logger := u.Logger.Session("update", lager.Data{"client": remoteIP(w)})
+
+	rcode := u.update(logger, w, request)
+
+	m := &dns.Msg{}
+	m.SetRcode(request, rcode)
+	signReply(w, request, m)
+	w.WriteMsg(m)
+}
+
+func (u *UpdateHandler) update(logger lager.Logger, w dns.ResponseWriter, request *dns.Msg) int {
+	if request.IsTsig() == nil || w.TsigStatus() != nil {
+		logger.Info("update-not-authorized")
+		return dns.RcodeNotAuth
+	}
+
+	zone := strings.ToLower(dns.Fqdn(u.Suffix))
+	if len(request.Question) != 1 || strings.ToLower(request.Question[0].Name) != zone {
+		logger.Info("update-not-zone")
+		return dns.RcodeNotZone
+	}
+
+	if len(request.Answer) > 0 {
+		logger.Info("update-prerequisites-not-supported")
+		return dns.RcodeNotImplemented
+	}
+
+	for _, rr := range request.Ns {
+		hdr := rr.Header()
+		if !dns.IsSubDomain(zone, strings.ToLower(hdr.Name)) || strings.ToLower(hdr.Name) == zone {
+			logger.Info("update-not-zone", lager.Data{"name": hdr.Name})
+			return dns.RcodeNotZone
+		}
+		if hdr.Class == dns.ClassINET && !registrable(hdr.Rrtype) {
+			logger.Info("update-unsupported-type", lager.Data{"type": dns.TypeToString[hdr.Rrtype]})
+			return dns.RcodeRefused
+		}
+		if hdr.Class != dns.ClassINET && hdr.Class != dns.ClassANY && hdr.Class != dns.ClassNONE {
+			return dns.RcodeFormatError
+		}
+	}
+
+	for _, rr := range request.Ns {
+		hdr := rr.Header()
+		switch hdr.Class {
+		case dns.ClassINET:
+			lease := u.lease(hdr.Ttl)
+			u.Registry.Add(rr, lease)
+			logger.Info("registered", lager.Data{"record": rr.String(), "lease": lease.String()})
+		case dns.ClassANY:
+			u.Registry.Remove(hdr.Name, hdr.Rrtype)
+			logger.Info("deregistered", lager.Data{"name": hdr.Name, "type": dns.TypeToString[hdr.Rrtype]})
+		case dns.ClassNONE:
+			u.Registry.RemoveRecord(rr)
+			logger.Info("deregistered", lager.Data{"record": rr.String()})
+		}
+	}
+
+	return dns.RcodeSuccess
+}
+
+func (u *UpdateHandler) lease(ttl uint32) time.Duration {
+	lease := time.Duration(ttl) * time.Second
+	if lease == 0 {
+		lease = u.DefaultLease
+	}
+	if u.MaxLease > 0 && lease > u.MaxLease {
+		lease = u.MaxLease
+	}
+	return lease
+}
+
+func registrable(rrtype uint16) bool {
+	switch rrtype {
+	case dns.TypeA, dns.TypeAAAA, dns.TypeCNAME, dns.TypeSRV, dns.TypeTXT:
+		return true
+	}
+	return false
+}
+
+// signReply signs m with the request's TSIG key when the request carried a
+// valid signature, so that the server signs the response on the way out.
+func signReply(w dns.ResponseWriter, request *dns.Msg, m *dns.Msg) {
+	if tsig := request.IsTsig(); tsig != nil && w.TsigStatus() == nil {
+		m.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsig.Fudge, time.Now().Unix())
+	}
+}
@@ -0,0 +1,191 @@
+package resolver_test
+
+import (
+	"net"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("UpdateHandler", func() {
+	var (
+		updateHandler  *resolver.UpdateHandler
+		registry       *resolver.Registry
+		nextHandler    *fakes.Handler
+		responseWriter *fakes.ResponseWriter
+		request        *dns.Msg
+		fakeLogger     *lagertest.TestLogger
+	)
+
+	newRR := func(s string) dns.RR {
+		rr, err := dns.NewRR(s)
+		Expect(err).NotTo(HaveOccurred())
+		return rr
+	}
+
+	BeforeEach(func() {
+		fakeLogger = lagertest.NewTestLogger("test")
+		registry = &resolver.Registry{}
+		nextHandler = &fakes.Handler{}
+		updateHandler = &resolver.UpdateHandler{
+			Logger:       fakeLogger,
+			Registry:     registry,
+			Suffix:       "potato",
+			DefaultLease: time.Hour,
+			MaxLease:     2 * time.Hour,
+			Next:         nextHandler,
+		}
+
+		responseWriter = &fakes.ResponseWriter{}
+		responseWriter.RemoteAddrReturns(&net.UDPAddr{IP: net.ParseIP("10.0.0.9"), Port: 5300})
+
+		request = &dns.Msg{}
+		request.SetUpdate("potato.")
+		request.Insert([]dns.RR{newRR("broker.potato. 300 IN A 10.0.0.5")})
+		request.SetTsig("update-key.", dns.HmacSHA256, 300, time.Now().Unix())
+	})
+
+	It("registers inserted records", func() {
+		updateHandler.ServeDNS(responseWriter, request)
+
+		Expect(responseWriter.WriteMsgCallCount()).To(Equal(1))
+		resp := responseWriter.WriteMsgArgsForCall(0)
+		Expect(resp.Rcode).To(Equal(dns.RcodeSuccess))
+		Expect(registry.Lookup("broker.potato.", dns.TypeA)).To(HaveLen(1))
+	})
+
+	It("signs the response", func() {
+		updateHandler.ServeDNS(responseWriter, request)
+
+		Expect(responseWriter.WriteMsgArgsForCall(0).IsTsig()).NotTo(BeNil())
+	})
+
+	It("logs the registration", func() {
+		updateHandler.ServeDNS(responseWriter, request)
+
+		Expect(fakeLogger).To(gbytes.Say("update.registered.*lease.*5m0s.*broker.potato"))
+	})
+
+	It("bounds the lease by the maximum", func() {
+		request.Ns = []dns.RR{newRR("broker.potato. 86400 IN A 10.0.0.5")}
+		updateHandler.ServeDNS(responseWriter, request)
+
+		Expect(fakeLogger).To(gbytes.Say("lease.*2h0m0s"))
+	})
+
+	It("uses the default lease for records with a TTL of zero", func() {
+		request.Ns = []dns.RR{newRR("broker.potato. 0 IN A 10.0.0.5")}
+		updateHandler.ServeDNS(responseWriter, request)
+
+		Expect(fakeLogger).To(gbytes.Say("lease.*1h0m0s"))
+	})
+
+	It("deregisters records", func() {
+		updateHandler.ServeDNS(responseWriter, request)
+
+		removal := &dns.Msg{}
+		removal.SetUpdate("potato.")
+		removal.RemoveName([]dns.RR{newRR("broker.potato. 300 IN A 10.0.0.5")})
+		removal.SetTsig("update-key.", dns.HmacSHA256, 300, time.Now().Unix())
+		updateHandler.ServeDNS(responseWriter, removal)
+
+		Expect(responseWriter.WriteMsgArgsForCall(1).Rcode).To(Equal(dns.RcodeSuccess))
+		Expect(registry.Records()).To(BeEmpty())
+	})
+
+	Context("when the request is not signed", func() {
+		BeforeEach(func() {
+			request.Extra = nil
+		})
+
+		It("responds with NOTAUTH and does not register anything", func() {
+			updateHandler.ServeDNS(responseWriter, request)
+
+			Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeNotAuth))
+			Expect(registry.Records()).To(BeEmpty())
+		})
+	})
+
+	Context("when the signature does not verify", func() {
+		BeforeEach(func() {
+			responseWriter.TsigStatusReturns(dns.ErrSig)
+		})
+
+		It("responds with NOTAUTH", func() {
+			updateHandler.ServeDNS(responseWriter, request)
+
+			Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeNotAuth))
+			Expect(registry.Records()).To(BeEmpty())
+		})
+	})
+
+	Context("when the update is for another zone", func() {
+		BeforeEach(func() {
+			request.Question[0].Name = "example.com."
+		})
+
+		It("responds with NOTZONE", func() {
+			updateHandler.ServeDNS(responseWriter, request)
+
+			Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeNotZone))
+		})
+	})
+
+	Context("when a record is outside of the overlay suffix", func() {
+		BeforeEach(func() {
+			request.Ns = append(request.Ns, newRR("www.example.com. 300 IN A 10.0.0.6"))
+		})
+
+		It("responds with NOTZONE and applies none of the update", func() {
+			updateHandler.ServeDNS(responseWriter, request)
+
+			Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeNotZone))
+			Expect(registry.Records()).To(BeEmpty())
+		})
+	})
+
+	Context("when a record type cannot be registered", func() {
+		BeforeEach(func() {
+			request.Ns = []dns.RR{newRR("broker.potato. 300 IN MX 10 mail.potato.")}
+		})
+
+		It("refuses the update", func() {
+			updateHandler.ServeDNS(responseWriter, request)
+
+			Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeRefused))
+		})
+	})
+
+	Context("when the update has prerequisites", func() {
+		BeforeEach(func() {
+			request.NameNotUsed([]dns.RR{newRR("broker.potato. 300 IN A 10.0.0.5")})
+		})
+
+		It("responds with NOTIMP", func() {
+			updateHandler.ServeDNS(responseWriter, request)
+
+			Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeNotImplemented))
+		})
+	})
+
+	Context("when the message is a query", func() {
+		BeforeEach(func() {
+			request = &dns.Msg{}
+			request.SetQuestion("broker.potato.", dns.TypeA)
+		})
+
+		It("passes it to the next handler", func() {
+			updateHandler.ServeDNS(responseWriter, request)
+
+			Expect(nextHandler.ServeDNSCallCount()).To(Equal(1))
+			Expect(responseWriter.WriteMsgCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,83 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// UpstreamHealth wraps an exchanger and remembers which upstream servers
+// answered recently.
+type UpstreamHealth struct {
+	Exchanger exchanger
+	Servers   []string
+	Window    time.Duration
+
+	mutex       sync.Mutex
+	lastSuccess map[string]time.Time
+}
+
+func (u *UpstreamHealth) Exchange(m *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+	resp, rtt, err := u.Exchanger.Exchange(m, server)
+	if err == nil {
+		u.mutex.Lock()
+		if u.lastSuccess == nil {
+			u.lastSuccess = map[string]time.Time{}
+		}
+		u.lastSuccess[server] = time.Now()
+		u.mutex.Unlock()
+	}
+	return resp, rtt, err
+}
+
+// Check succeeds if any server answered within Window, and otherwise asks
+// each server for the root NS records until one of them answers.
+func (u *UpstreamHealth) Check() error {
+	u.mutex.Lock()
+	for _, server := range u.Servers {
+		if time.Since(u.lastSuccess[server]) < u.Window {
+			u.mutex.Unlock()
+			return nil
+		}
+	}
+	u.mutex.Unlock()
+
+	failures := []string{}
+	for _, server := range u.Servers {
+		probe := &dns.Msg{}
+		probe.SetQuestion(".", dns.TypeNS)
+		if _, _, err := u.Exchange(probe, server); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", server, err))
+			continue
+		}
+		return nil
+	}
+
+	return fmt.Errorf("no healthy upstream: %s", strings.Join(failures, "; "))
+}
+
+type UpstreamStatus struct {
+	Server      string    `json:"server"`
+	Healthy     bool      `json:"healthy"`
+	LastSuccess time.Time `json:"last_success"`
+}
+
+// Status reports each server and whether it answered within Window.
+func (u *UpstreamHealth) Status() []UpstreamStatus {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	statuses := []UpstreamStatus{}
+	for _, server := range u.Servers {
+		last := u.lastSuccess[server]
+		statuses = append(statuses, UpstreamStatus{
+			Server:      server,
+			Healthy:     time.Since(last) < u.Window,
+			LastSuccess: last,
+		})
+	}
+	return statuses
+}
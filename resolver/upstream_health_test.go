@@ -0,0 +1,80 @@
+package resolver_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/miekg/dns"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UpstreamHealth", func() {
+	var (
+		upstreamHealth *resolver.UpstreamHealth
+		fakeExchanger  *fakes.Exchanger
+	)
+
+	BeforeEach(func() {
+		fakeExchanger = &fakes.Exchanger{}
+		fakeExchanger.ExchangeReturns(&dns.Msg{}, time.Millisecond, nil)
+		upstreamHealth = &resolver.UpstreamHealth{
+			Exchanger: fakeExchanger,
+			Servers:   []string{"1.2.3.4:53", "5.6.7.8:53"},
+			Window:    time.Minute,
+		}
+	})
+
+	It("does not probe when an upstream answered recently", func() {
+		upstreamHealth.Exchange(&dns.Msg{}, "5.6.7.8:53")
+
+		Expect(upstreamHealth.Check()).To(Succeed())
+		Expect(fakeExchanger.ExchangeCallCount()).To(Equal(1))
+	})
+
+	It("probes the upstreams for the root name servers otherwise", func() {
+		Expect(upstreamHealth.Check()).To(Succeed())
+
+		Expect(fakeExchanger.ExchangeCallCount()).To(Equal(1))
+		probe, server := fakeExchanger.ExchangeArgsForCall(0)
+		Expect(server).To(Equal("1.2.3.4:53"))
+		Expect(probe.Question[0].Name).To(Equal("."))
+		Expect(probe.Question[0].Qtype).To(Equal(dns.TypeNS))
+	})
+
+	It("succeeds if any upstream answers the probe", func() {
+		fakeExchanger.ExchangeStub = func(m *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+			if server == "1.2.3.4:53" {
+				return nil, 0, errors.New("timeout")
+			}
+			return &dns.Msg{}, time.Millisecond, nil
+		}
+
+		Expect(upstreamHealth.Check()).To(Succeed())
+		Expect(fakeExchanger.ExchangeCallCount()).To(Equal(2))
+	})
+
+	It("reports the status of each upstream", func() {
+		upstreamHealth.Exchange(&dns.Msg{}, "5.6.7.8:53")
+
+		statuses := upstreamHealth.Status()
+		Expect(statuses).To(HaveLen(2))
+		Expect(statuses[0].Server).To(Equal("1.2.3.4:53"))
+		Expect(statuses[0].Healthy).To(BeFalse())
+		Expect(statuses[1].Server).To(Equal("5.6.7.8:53"))
+		Expect(statuses[1].Healthy).To(BeTrue())
+	})
+
+	Context("when no upstream answers", func() {
+		BeforeEach(func() {
+			fakeExchanger.ExchangeReturns(nil, 0, errors.New("timeout"))
+		})
+
+		It("fails the check with each upstream's error", func() {
+			Expect(upstreamHealth.Check()).To(MatchError("no healthy upstream: 1.2.3.4:53: timeout; 5.6.7.8:53: timeout"))
+		})
+	})
+})
@@ -0,0 +1,696 @@
+package resolver
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/metrics"
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager"
+)
+
+// LoadTrustAnchors reads DS or DNSKEY records from a file in master file
+// format.  DNSKEY records are converted to SHA-256 DS records.
+func LoadTrustAnchors(path string) ([]*dns.DS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open trust anchors: %s", err)
+	}
+	defer f.Close()
+
+	anchors := []*dns.DS{}
+	for token := range dns.ParseZone(f, ".", path) {
+		if token.Error != nil {
+			return nil, fmt.Errorf("parse trust anchors: %s", token.Error)
+		}
+		switch rr := token.RR.(type) {
+		case *dns.DS:
+			anchors = append(anchors, rr)
+		case *dns.DNSKEY:
+			anchors = append(anchors, rr.ToDS(dns.SHA256))
+		}
+	}
+
+	if len(anchors) == 0 {
+		return nil, fmt.Errorf("parse trust anchors: no DS or DNSKEY records in %s", path)
+	}
+	return anchors, nil
+}
+
+const nsec3OptOut = 1
+
+type validatedKeys struct {
+	keys    []*dns.DNSKEY
+	expires time.Time
+}
+
+// Validator checks forwarded answers against a chain of trust that starts
+// at the configured trust anchors.  DNSKEY and DS records are fetched from
+// the same upstream server that answered the query.  Names that are not
+// below any trust anchor, or that sit below a provably unsigned
+// delegation, are insecure rather than bogus.
+type Validator struct {
+	Logger       lager.Logger
+	Exchanger    exchanger
+	TrustAnchors []*dns.DS
+	Metrics      *metrics.Metrics
+
+	mutex         sync.Mutex
+	keys          map[string]validatedKeys
+	insecureZones map[string]time.Time
+}
+
+// Validate returns true if the response that server gave is secure and an
+// error if it is bogus.  Answers expanded from a wildcard and NXDOMAIN
+// answers must carry the NSEC or NSEC3 records that prove no closer name
+// or wildcard exists, as in RFC 4035 sections 5.3.4 and 5.4.
+func (v *Validator) Validate(question dns.Question, response *dns.Msg, server string) (bool, error) {
+	anchor := v.anchorFor(question.Name)
+	if anchor == "" {
+		return false, nil
+	}
+	if response.Rcode != dns.RcodeSuccess && response.Rcode != dns.RcodeNameError {
+		return false, nil
+	}
+
+	expansions := map[string]int{}
+	for s, section := range [][]dns.RR{response.Answer, response.Ns} {
+		for _, rrset := range groupRRsets(section) {
+			owner := strings.ToLower(rrset[0].Header().Name)
+			if !dns.IsSubDomain(anchor, owner) {
+				return false, nil
+			}
+
+			sigs := signaturesCovering(section, owner, rrset[0].Header().Rrtype)
+			if len(sigs) == 0 {
+				insecure, err := v.insecure(owner, anchor, server)
+				if err != nil {
+					return false, err
+				}
+				if insecure {
+					return false, nil
+				}
+				return false, fmt.Errorf("missing signature for %s %s", owner, dns.TypeToString[rrset[0].Header().Rrtype])
+			}
+
+			sig, err := v.verify(rrset, sigs, anchor, server)
+			if err != nil {
+				return false, err
+			}
+			if s == 0 && int(sig.Labels) < ownerLabels(owner) {
+				expansions[owner] = int(sig.Labels)
+			}
+		}
+	}
+
+	for owner, labels := range expansions {
+		if err := provesNoCloserMatch(response.Ns, owner, labels); err != nil {
+			return false, err
+		}
+	}
+
+	name := finalName(question.Name, response.Answer)
+	if !dns.IsSubDomain(anchor, name) {
+		return false, nil
+	}
+	if response.Rcode == dns.RcodeSuccess && hasAnswer(response.Answer, name, question.Qtype) {
+		return true, nil
+	}
+
+	if err := provesDenial(response, name, question.Qtype); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (v *Validator) anchorFor(name string) string {
+	name = strings.ToLower(dns.Fqdn(name))
+	anchor := ""
+	for _, ds := range v.TrustAnchors {
+		zone := strings.ToLower(ds.Hdr.Name)
+		if dns.IsSubDomain(zone, name) && dns.CountLabel(zone) >= dns.CountLabel(anchor) {
+			anchor = zone
+		}
+	}
+	return anchor
+}
+
+func (v *Validator) anchors(zone string) []*dns.DS {
+	anchors := []*dns.DS{}
+	for _, ds := range v.TrustAnchors {
+		if strings.ToLower(ds.Hdr.Name) == zone {
+			anchors = append(anchors, ds)
+		}
+	}
+	return anchors
+}
+
+func (v *Validator) query(name string, qtype uint16, server string) (*dns.Msg, error) {
+	m := &dns.Msg{}
+	m.SetQuestion(name, qtype)
+	m.SetEdns0(4096, true)
+	m.CheckingDisabled = true
+
+	resp, _, err := v.Exchanger.Exchange(m, server)
+	if err != nil {
+		return nil, fmt.Errorf("query %s %s: %s", name, dns.TypeToString[qtype], err)
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("query %s %s: no response", name, dns.TypeToString[qtype])
+	}
+	return resp, nil
+}
+
+// verify checks that at least one of sigs is a currently valid signature
+// over rrset by a key in the chain of trust, and returns it.
+func (v *Validator) verify(rrset []dns.RR, sigs []*dns.RRSIG, anchor, server string) (*dns.RRSIG, error) {
+	owner := strings.ToLower(rrset[0].Header().Name)
+	err := fmt.Errorf("no valid signature for %s %s", owner, dns.TypeToString[rrset[0].Header().Rrtype])
+
+	for _, sig := range sigs {
+		signer := strings.ToLower(sig.SignerName)
+		if !dns.IsSubDomain(anchor, signer) || !dns.IsSubDomain(signer, owner) {
+			continue
+		}
+		if sig.TypeCovered == dns.TypeDS && signer == owner {
+			continue
+		}
+		if !sig.ValidityPeriod(time.Now()) {
+			err = fmt.Errorf("signature for %s %s is outside its validity period", owner, dns.TypeToString[sig.TypeCovered])
+			continue
+		}
+
+		keys, keyErr := v.zoneKeys(signer, anchor, server)
+		if keyErr != nil {
+			err = keyErr
+			continue
+		}
+
+		for _, key := range keys {
+			if key.KeyTag() == sig.KeyTag && key.Algorithm == sig.Algorithm && sig.Verify(key, rrset) == nil {
+				return sig, nil
+			}
+		}
+	}
+	return nil, err
+}
+
+// zoneKeys returns the DNSKEY RRset of zone once it has been authenticated
+// by the zone's DS records, which are in turn authenticated by the parent.
+func (v *Validator) zoneKeys(zone, anchor, server string) ([]*dns.DNSKEY, error) {
+	v.mutex.Lock()
+	cached, ok := v.keys[zone]
+	v.mutex.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		v.Metrics.CacheHit("dnskey")
+		return cached.keys, nil
+	}
+	v.Metrics.CacheMiss("dnskey")
+
+	var dsSet []*dns.DS
+	if zone == anchor {
+		dsSet = v.anchors(zone)
+	} else {
+		resp, err := v.query(zone, dns.TypeDS, server)
+		if err != nil {
+			return nil, err
+		}
+		rrset := recordsOf(resp.Answer, zone, dns.TypeDS)
+		if len(rrset) == 0 {
+			return nil, fmt.Errorf("no DS records for %s", zone)
+		}
+		if _, err := v.verify(rrset, signaturesCovering(resp.Answer, zone, dns.TypeDS), anchor, server); err != nil {
+			return nil, err
+		}
+		for _, rr := range rrset {
+			dsSet = append(dsSet, rr.(*dns.DS))
+		}
+	}
+
+	resp, err := v.query(zone, dns.TypeDNSKEY, server)
+	if err != nil {
+		return nil, err
+	}
+	rrset := recordsOf(resp.Answer, zone, dns.TypeDNSKEY)
+	sigs := signaturesCovering(resp.Answer, zone, dns.TypeDNSKEY)
+
+	keys := []*dns.DNSKEY{}
+	for _, rr := range rrset {
+		keys = append(keys, rr.(*dns.DNSKEY))
+	}
+
+	for _, key := range keys {
+		if !matchesDS(key, dsSet) {
+			continue
+		}
+		for _, sig := range sigs {
+			if sig.KeyTag != key.KeyTag() || !sig.ValidityPeriod(time.Now()) || sig.Verify(key, rrset) != nil {
+				continue
+			}
+
+			expires := time.Now().Add(time.Duration(rrset[0].Header().Ttl) * time.Second)
+			if sigExpiry := time.Unix(int64(sig.Expiration), 0); sigExpiry.Before(expires) {
+				expires = sigExpiry
+			}
+
+			v.mutex.Lock()
+			if v.keys == nil {
+				v.keys = map[string]validatedKeys{}
+			}
+			v.keys[zone] = validatedKeys{keys: keys, expires: expires}
+			v.mutex.Unlock()
+
+			v.Logger.Info("zone-keys-validated", lager.Data{"zone": zone, "expires": expires})
+
+			return keys, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no DNSKEY for %s matches its DS records", zone)
+}
+
+// insecure walks down from the trust anchor towards name looking for a
+// delegation that is proven to have no DS records, either by an NSEC or
+// NSEC3 record at the delegation or by an opt-out NSEC3 covering it.
+// Proven delegations are remembered for the TTL of their proof.
+func (v *Validator) insecure(name, anchor, server string) (bool, error) {
+	labels := dns.SplitDomainName(name)
+	first := len(labels) - dns.CountLabel(anchor) - 1
+
+	for i := first; i >= 0; i-- {
+		if v.provenInsecure(dns.Fqdn(strings.Join(labels[i:], "."))) {
+			return true, nil
+		}
+	}
+
+	for i := first; i >= 0; i-- {
+		candidate := dns.Fqdn(strings.Join(labels[i:], "."))
+
+		resp, err := v.query(candidate, dns.TypeDS, server)
+		if err != nil {
+			return false, err
+		}
+
+		if rrset := recordsOf(resp.Answer, candidate, dns.TypeDS); len(rrset) > 0 {
+			if _, err := v.verify(rrset, signaturesCovering(resp.Answer, candidate, dns.TypeDS), anchor, server); err != nil {
+				return false, err
+			}
+			continue
+		}
+
+		proof := []dns.RR{}
+		nsec3s := []*dns.NSEC3{}
+		for _, rrset := range groupRRsets(resp.Ns) {
+			owner := strings.ToLower(rrset[0].Header().Name)
+			rrtype := rrset[0].Header().Rrtype
+			if rrtype != dns.TypeNSEC && rrtype != dns.TypeNSEC3 {
+				continue
+			}
+			if _, err := v.verify(rrset, signaturesCovering(resp.Ns, owner, rrtype), anchor, server); err != nil {
+				return false, err
+			}
+			for _, rr := range rrset {
+				proof = append(proof, rr)
+				if nsec3, ok := rr.(*dns.NSEC3); ok {
+					nsec3s = append(nsec3s, nsec3)
+				}
+			}
+		}
+
+		for _, rr := range proof {
+			if unsignedDelegation(rr, candidate) {
+				v.rememberInsecure(candidate, proof)
+				return true, nil
+			}
+		}
+		if optOutDelegation(nsec3s, candidate) {
+			v.rememberInsecure(candidate, proof)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (v *Validator) provenInsecure(zone string) bool {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	expires, ok := v.insecureZones[zone]
+	return ok && time.Now().Before(expires)
+}
+
+func (v *Validator) rememberInsecure(zone string, proof []dns.RR) {
+	ttl := proof[0].Header().Ttl
+	for _, rr := range proof {
+		if rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+	expires := time.Now().Add(time.Duration(ttl) * time.Second)
+
+	v.mutex.Lock()
+	if v.insecureZones == nil {
+		v.insecureZones = map[string]time.Time{}
+	}
+	v.insecureZones[zone] = expires
+	v.mutex.Unlock()
+
+	v.Logger.Info("insecure-delegation", lager.Data{"zone": zone, "expires": expires})
+}
+
+func unsignedDelegation(rr dns.RR, name string) bool {
+	var bitmap []uint16
+	switch nsec := rr.(type) {
+	case *dns.NSEC:
+		if strings.ToLower(nsec.Hdr.Name) != name {
+			return false
+		}
+		bitmap = nsec.TypeBitMap
+	case *dns.NSEC3:
+		if !nsec.Match(name) {
+			return false
+		}
+		bitmap = nsec.TypeBitMap
+	default:
+		return false
+	}
+	return hasType(bitmap, dns.TypeNS) && !hasType(bitmap, dns.TypeDS) && !hasType(bitmap, dns.TypeSOA)
+}
+
+// optOutDelegation reports whether the NSEC3 records prove, as in RFC 5155
+// section 8.6, that name may be an unsigned delegation: an NSEC3 matches
+// its closest encloser and one with the opt-out flag covers the next closer
+// name.
+func optOutDelegation(nsec3s []*dns.NSEC3, name string) bool {
+	_, nextCloser, ok := closestEncloser(nsec3s, name)
+	if !ok {
+		return false
+	}
+	for _, nsec3 := range nsec3s {
+		if nsec3.Flags&nsec3OptOut != 0 && nsec3.Cover(nextCloser) {
+			return true
+		}
+	}
+	return false
+}
+
+// provesDenial checks the (already verified) NSEC or NSEC3 records in the
+// authority section for proof that name has no records of qtype, or, for
+// NXDOMAIN responses, that neither name nor a wildcard that could have
+// matched it exists.
+func provesDenial(response *dns.Msg, name string, qtype uint16) error {
+	nsecs, nsec3s := denialRecords(response.Ns)
+
+	if response.Rcode == dns.RcodeSuccess {
+		for _, nsec := range nsecs {
+			if strings.ToLower(nsec.Hdr.Name) == name && !hasType(nsec.TypeBitMap, qtype) && !hasType(nsec.TypeBitMap, dns.TypeCNAME) {
+				return nil
+			}
+		}
+		for _, nsec3 := range nsec3s {
+			if nsec3.Match(name) && !hasType(nsec3.TypeBitMap, qtype) && !hasType(nsec3.TypeBitMap, dns.TypeCNAME) {
+				return nil
+			}
+		}
+	} else {
+		for _, nsec := range nsecs {
+			if !canonicalCovers(nsec.Hdr.Name, nsec.NextDomain, name) {
+				continue
+			}
+			common := dns.CompareDomainName(name, nsec.Hdr.Name)
+			if next := dns.CompareDomainName(name, nsec.NextDomain); next > common {
+				common = next
+			}
+			if nsecCovers(nsecs, wildcardAt(lastLabels(name, common))) {
+				return nil
+			}
+		}
+		if encloser, nextCloser, ok := closestEncloser(nsec3s, name); ok {
+			if nsec3Covers(nsec3s, nextCloser) && nsec3Covers(nsec3s, wildcardAt(encloser)) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("no proof of nonexistence for %s %s", name, dns.TypeToString[qtype])
+}
+
+// provesNoCloserMatch checks that the answer for owner, which its signature
+// of labels labels shows to be expanded from a wildcard, could not have
+// come from a closer match: an NSEC must cover owner, or an NSEC3 the next
+// closer name below the wildcard's parent.
+func provesNoCloserMatch(ns []dns.RR, owner string, labels int) error {
+	nsecs, nsec3s := denialRecords(ns)
+	if nsecCovers(nsecs, owner) || nsec3Covers(nsec3s, lastLabels(owner, labels+1)) {
+		return nil
+	}
+	return fmt.Errorf("no proof that %s does not exist for its wildcard expansion", owner)
+}
+
+func denialRecords(rrs []dns.RR) ([]*dns.NSEC, []*dns.NSEC3) {
+	nsecs := []*dns.NSEC{}
+	nsec3s := []*dns.NSEC3{}
+	for _, rr := range rrs {
+		switch nsec := rr.(type) {
+		case *dns.NSEC:
+			nsecs = append(nsecs, nsec)
+		case *dns.NSEC3:
+			nsec3s = append(nsec3s, nsec)
+		}
+	}
+	return nsecs, nsec3s
+}
+
+func nsecCovers(nsecs []*dns.NSEC, name string) bool {
+	for _, nsec := range nsecs {
+		if canonicalCovers(nsec.Hdr.Name, nsec.NextDomain, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func nsec3Covers(nsec3s []*dns.NSEC3, name string) bool {
+	for _, nsec3 := range nsec3s {
+		if nsec3.Cover(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// closestEncloser finds the longest ancestor of name that an NSEC3 record
+// matches and returns it along with the next closer name, the ancestor's
+// child on the way to name.
+func closestEncloser(nsec3s []*dns.NSEC3, name string) (string, string, bool) {
+	labels := dns.SplitDomainName(name)
+	for i := 1; i < len(labels); i++ {
+		encloser := dns.Fqdn(strings.Join(labels[i:], "."))
+		for _, nsec3 := range nsec3s {
+			if nsec3.Match(encloser) {
+				return encloser, dns.Fqdn(strings.Join(labels[i-1:], ".")), true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// ownerLabels counts the labels of owner as the RRSIG labels field does,
+// without the asterisk of a wildcard.
+func ownerLabels(owner string) int {
+	labels := dns.CountLabel(owner)
+	if strings.HasPrefix(owner, "*.") {
+		labels--
+	}
+	return labels
+}
+
+// lastLabels returns the ancestor of name made of its last n labels.
+func lastLabels(name string, n int) string {
+	labels := dns.SplitDomainName(name)
+	if n <= 0 {
+		return "."
+	}
+	if n > len(labels) {
+		n = len(labels)
+	}
+	return dns.Fqdn(strings.Join(labels[len(labels)-n:], "."))
+}
+
+func wildcardAt(encloser string) string {
+	if encloser == "." {
+		return "*."
+	}
+	return "*." + encloser
+}
+
+func matchesDS(key *dns.DNSKEY, dsSet []*dns.DS) bool {
+	if key.Flags&dns.ZONE == 0 {
+		return false
+	}
+	for _, ds := range dsSet {
+		if ds.KeyTag != key.KeyTag() || ds.Algorithm != key.Algorithm {
+			continue
+		}
+		if computed := key.ToDS(ds.DigestType); computed != nil && strings.EqualFold(computed.Digest, ds.Digest) {
+			return true
+		}
+	}
+	return false
+}
+
+func signaturesCovering(rrs []dns.RR, owner string, rrtype uint16) []*dns.RRSIG {
+	sigs := []*dns.RRSIG{}
+	for _, rr := range rrs {
+		if sig, ok := rr.(*dns.RRSIG); ok && sig.TypeCovered == rrtype && strings.ToLower(sig.Hdr.Name) == owner {
+			sigs = append(sigs, sig)
+		}
+	}
+	return sigs
+}
+
+func recordsOf(rrs []dns.RR, owner string, rrtype uint16) []dns.RR {
+	matched := []dns.RR{}
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == rrtype && strings.ToLower(rr.Header().Name) == owner {
+			matched = append(matched, rr)
+		}
+	}
+	return matched
+}
+
+func finalName(name string, answer []dns.RR) string {
+	name = strings.ToLower(name)
+	for i := 0; i < maxCNAMEChain; i++ {
+		cname, ok := firstCNAME(answer, name)
+		if !ok {
+			break
+		}
+		name = strings.ToLower(cname.Target)
+	}
+	return name
+}
+
+func firstCNAME(answer []dns.RR, name string) (*dns.CNAME, bool) {
+	for _, rr := range answer {
+		if cname, ok := rr.(*dns.CNAME); ok && strings.ToLower(cname.Hdr.Name) == name {
+			return cname, true
+		}
+	}
+	return nil, false
+}
+
+func hasAnswer(answer []dns.RR, name string, qtype uint16) bool {
+	for _, rr := range answer {
+		if strings.ToLower(rr.Header().Name) == name && (qtype == dns.TypeANY || rr.Header().Rrtype == qtype) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasType(bitmap []uint16, rrtype uint16) bool {
+	for _, t := range bitmap {
+		if t == rrtype {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalCovers reports whether name falls between owner and next in
+// canonical DNS name order, allowing for the last NSEC wrapping to the apex.
+func canonicalCovers(owner, next, name string) bool {
+	if canonicalCompare(owner, name) >= 0 {
+		return false
+	}
+	return canonicalCompare(name, next) < 0 || canonicalCompare(next, owner) <= 0
+}
+
+func canonicalCompare(a, b string) int {
+	la := dns.SplitDomainName(strings.ToLower(a))
+	lb := dns.SplitDomainName(strings.ToLower(b))
+	for i := 1; i <= len(la) && i <= len(lb); i++ {
+		if c := bytes.Compare(unescapeLabel(la[len(la)-i]), unescapeLabel(lb[len(lb)-i])); c != 0 {
+			return c
+		}
+	}
+	return len(la) - len(lb)
+}
+
+func unescapeLabel(label string) []byte {
+	out := []byte{}
+	for i := 0; i < len(label); i++ {
+		if label[i] != '\\' || i+1 >= len(label) {
+			out = append(out, label[i])
+			continue
+		}
+		if i+3 < len(label) && isDigit(label[i+1]) && isDigit(label[i+2]) && isDigit(label[i+3]) {
+			out = append(out, (label[i+1]-'0')*100+(label[i+2]-'0')*10+(label[i+3]-'0'))
+			i += 3
+			continue
+		}
+		out = append(out, label[i+1])
+		i++
+	}
+	return out
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func (v *Validator) Entries() []CacheEntry {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	entries := []CacheEntry{}
+	for zone, validated := range v.keys {
+		tags := []string{}
+		for _, key := range validated.keys {
+			tags = append(tags, fmt.Sprintf("%d", key.KeyTag()))
+		}
+		entries = append(entries, CacheEntry{
+			Key:     zone,
+			Value:   "DNSKEY " + strings.Join(tags, " "),
+			Expires: validated.expires,
+		})
+	}
+	for zone, expires := range v.insecureZones {
+		entries = append(entries, CacheEntry{
+			Key:     zone,
+			Value:   "insecure",
+			Expires: expires,
+		})
+	}
+	sort.Sort(byKey(entries))
+	return entries
+}
+
+// Flush drops the validated keys and insecure delegation of zone, or of
+// every zone when zone is empty.
+func (v *Validator) Flush(zone string) int {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	zone = strings.ToLower(zone)
+	flushed := 0
+	for cached := range v.keys {
+		if zone == "" || dns.Fqdn(zone) == cached {
+			delete(v.keys, cached)
+			flushed++
+		}
+	}
+	for cached := range v.insecureZones {
+		if zone == "" || dns.Fqdn(zone) == cached {
+			delete(v.insecureZones, cached)
+			flushed++
+		}
+	}
+	return flushed
+}
@@ -0,0 +1,423 @@
+package resolver_test
+
+import (
+	"crypto"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type signedZone struct {
+	origin  string
+	key     *dns.DNSKEY
+	private crypto.Signer
+}
+
+func newSignedZone(origin string) *signedZone {
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: origin, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	private, err := key.Generate(256)
+	Expect(err).NotTo(HaveOccurred())
+	return &signedZone{origin: origin, key: key, private: private.(crypto.Signer)}
+}
+
+func (z *signedZone) signValid(inception, expiration time.Time, rrs ...dns.RR) []dns.RR {
+	hdr := rrs[0].Header()
+	sig := &dns.RRSIG{
+		Hdr:         dns.RR_Header{Name: hdr.Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: hdr.Ttl},
+		TypeCovered: hdr.Rrtype,
+		Algorithm:   z.key.Algorithm,
+		Labels:      uint8(dns.CountLabel(hdr.Name)),
+		OrigTtl:     hdr.Ttl,
+		Inception:   uint32(inception.Unix()),
+		Expiration:  uint32(expiration.Unix()),
+		KeyTag:      z.key.KeyTag(),
+		SignerName:  z.origin,
+	}
+	Expect(sig.Sign(z.private, rrs)).To(Succeed())
+	return append(rrs, sig)
+}
+
+func (z *signedZone) sign(rrs ...dns.RR) []dns.RR {
+	return z.signValid(time.Now().Add(-time.Hour), time.Now().Add(time.Hour), rrs...)
+}
+
+func (z *signedZone) ds() *dns.DS {
+	return z.key.ToDS(dns.SHA256)
+}
+
+func testRR(s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	Expect(err).NotTo(HaveOccurred())
+	return rr
+}
+
+var _ = Describe("Validator", func() {
+	var (
+		validator     *resolver.Validator
+		fakeExchanger *fakes.Exchanger
+		example       *signedZone
+		secure        *signedZone
+		upstream      map[string]*dns.Msg
+		response      *dns.Msg
+		question      dns.Question
+	)
+
+	respond := func(name string, qtype uint16, rcode int, answer []dns.RR, authority []dns.RR) *dns.Msg {
+		m := &dns.Msg{}
+		m.SetQuestion(name, qtype)
+		m.Response = true
+		m.Rcode = rcode
+		m.Answer = answer
+		m.Ns = authority
+		upstream[name+"|"+dns.TypeToString[qtype]] = m
+		return m
+	}
+
+	BeforeEach(func() {
+		example = newSignedZone("example.")
+		secure = newSignedZone("secure.example.")
+		upstream = map[string]*dns.Msg{}
+
+		respond("example.", dns.TypeDNSKEY, dns.RcodeSuccess, example.sign(example.key), nil)
+		respond("secure.example.", dns.TypeDS, dns.RcodeSuccess, example.sign(secure.ds()), nil)
+		respond("secure.example.", dns.TypeDNSKEY, dns.RcodeSuccess, secure.sign(secure.key), nil)
+		respond("insecure.example.", dns.TypeDS, dns.RcodeSuccess, nil,
+			example.sign(testRR("insecure.example. 60 IN NSEC zzz.example. NS RRSIG NSEC")))
+
+		fakeExchanger = &fakes.Exchanger{}
+		fakeExchanger.ExchangeStub = func(m *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+			q := m.Question[0]
+			if resp, ok := upstream[q.Name+"|"+dns.TypeToString[q.Qtype]]; ok {
+				return resp, time.Millisecond, nil
+			}
+			resp := &dns.Msg{}
+			resp.SetRcode(m, dns.RcodeNameError)
+			return resp, time.Millisecond, nil
+		}
+
+		validator = &resolver.Validator{
+			Logger:       lagertest.NewTestLogger("test"),
+			Exchanger:    fakeExchanger,
+			TrustAnchors: []*dns.DS{example.ds()},
+		}
+
+		question = dns.Question{Name: "www.example.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+		response = respond("www.example.", dns.TypeA, dns.RcodeSuccess,
+			example.sign(testRR("www.example. 60 IN A 10.0.0.1")), nil)
+	})
+
+	It("accepts an answer signed by the trust anchor's zone", func() {
+		isSecure, err := validator.Validate(question, response, "1.2.3.4:53")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(isSecure).To(BeTrue())
+
+		q, server := fakeExchanger.ExchangeArgsForCall(0)
+		Expect(server).To(Equal("1.2.3.4:53"))
+		Expect(q.Question[0].Qtype).To(Equal(dns.TypeDNSKEY))
+		Expect(q.IsEdns0().Do()).To(BeTrue())
+	})
+
+	It("caches validated keys", func() {
+		validator.Validate(question, response, "1.2.3.4:53")
+		validator.Validate(question, response, "1.2.3.4:53")
+
+		Expect(fakeExchanger.ExchangeCallCount()).To(Equal(1))
+	})
+
+	It("lists and flushes validated keys by zone", func() {
+		validator.Validate(question, response, "1.2.3.4:53")
+
+		entries := validator.Entries()
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Key).To(Equal("example."))
+
+		Expect(validator.Flush("example")).To(Equal(1))
+		validator.Validate(question, response, "1.2.3.4:53")
+		Expect(fakeExchanger.ExchangeCallCount()).To(Equal(2))
+	})
+
+	It("follows secure delegations through their DS records", func() {
+		question.Name = "app.secure.example."
+		response = respond("app.secure.example.", dns.TypeA, dns.RcodeSuccess,
+			secure.sign(testRR("app.secure.example. 60 IN A 10.0.0.2")), nil)
+
+		isSecure, err := validator.Validate(question, response, "1.2.3.4:53")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(isSecure).To(BeTrue())
+	})
+
+	It("rejects answers that were tampered with", func() {
+		response.Answer[0].(*dns.A).A = net.ParseIP("10.9.9.9")
+
+		_, err := validator.Validate(question, response, "1.2.3.4:53")
+		Expect(err).To(MatchError(ContainSubstring("no valid signature for www.example. A")))
+	})
+
+	It("rejects expired signatures", func() {
+		response.Answer = example.signValid(time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour),
+			testRR("www.example. 60 IN A 10.0.0.1"))
+
+		_, err := validator.Validate(question, response, "1.2.3.4:53")
+		Expect(err).To(MatchError(ContainSubstring("outside its validity period")))
+	})
+
+	It("rejects unsigned answers in a signed zone", func() {
+		response.Answer = []dns.RR{testRR("www.example. 60 IN A 10.0.0.1")}
+
+		_, err := validator.Validate(question, response, "1.2.3.4:53")
+		Expect(err).To(MatchError(ContainSubstring("missing signature for www.example. A")))
+	})
+
+	It("rejects keys that do not match the trust anchor", func() {
+		validator.TrustAnchors = []*dns.DS{newSignedZone("example.").ds()}
+
+		_, err := validator.Validate(question, response, "1.2.3.4:53")
+		Expect(err).To(MatchError(ContainSubstring("no DNSKEY for example. matches its DS records")))
+	})
+
+	It("treats unsigned answers below an unsigned delegation as insecure", func() {
+		question.Name = "app.insecure.example."
+		response = respond("app.insecure.example.", dns.TypeA, dns.RcodeSuccess,
+			[]dns.RR{testRR("app.insecure.example. 60 IN A 10.0.0.3")}, nil)
+
+		isSecure, err := validator.Validate(question, response, "1.2.3.4:53")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(isSecure).To(BeFalse())
+	})
+
+	It("remembers unsigned delegations for the TTL of their proof", func() {
+		question.Name = "app.insecure.example."
+		response = respond("app.insecure.example.", dns.TypeA, dns.RcodeSuccess,
+			[]dns.RR{testRR("app.insecure.example. 60 IN A 10.0.0.3")}, nil)
+
+		validator.Validate(question, response, "1.2.3.4:53")
+		calls := fakeExchanger.ExchangeCallCount()
+
+		isSecure, err := validator.Validate(question, response, "1.2.3.4:53")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(isSecure).To(BeFalse())
+		Expect(fakeExchanger.ExchangeCallCount()).To(Equal(calls))
+
+		Expect(validator.Entries()).To(ContainElement(resolver.CacheEntry{
+			Key:     "insecure.example.",
+			Value:   "insecure",
+			Expires: validator.Entries()[1].Expires,
+		}))
+		Expect(validator.Flush("insecure.example")).To(Equal(1))
+		validator.Validate(question, response, "1.2.3.4:53")
+		Expect(fakeExchanger.ExchangeCallCount()).To(Equal(calls + 1))
+	})
+
+	It("treats unsigned answers below an opt-out NSEC3 span as insecure", func() {
+		encloser := dns.HashName("example.", dns.SHA1, 0, "")
+		respond("optout.example.", dns.TypeDS, dns.RcodeSuccess, nil, append(
+			example.sign(testRR(encloser+".example. 60 IN NSEC3 1 0 0 - "+encloser+" NS SOA RRSIG DNSKEY NSEC3PARAM")),
+			example.sign(testRR(strings.Repeat("0", 32)+".example. 60 IN NSEC3 1 1 0 - "+strings.Repeat("V", 32)+" A RRSIG"))...,
+		))
+
+		question.Name = "app.optout.example."
+		response = respond("app.optout.example.", dns.TypeA, dns.RcodeSuccess,
+			[]dns.RR{testRR("app.optout.example. 60 IN A 10.0.0.5")}, nil)
+
+		isSecure, err := validator.Validate(question, response, "1.2.3.4:53")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(isSecure).To(BeFalse())
+	})
+
+	It("requires the opt-out flag on the NSEC3 covering the delegation", func() {
+		encloser := dns.HashName("example.", dns.SHA1, 0, "")
+		respond("optout.example.", dns.TypeDS, dns.RcodeSuccess, nil, append(
+			example.sign(testRR(encloser+".example. 60 IN NSEC3 1 0 0 - "+encloser+" NS SOA RRSIG DNSKEY NSEC3PARAM")),
+			example.sign(testRR(strings.Repeat("0", 32)+".example. 60 IN NSEC3 1 0 0 - "+strings.Repeat("V", 32)+" A RRSIG"))...,
+		))
+
+		question.Name = "app.optout.example."
+		response = respond("app.optout.example.", dns.TypeA, dns.RcodeSuccess,
+			[]dns.RR{testRR("app.optout.example. 60 IN A 10.0.0.5")}, nil)
+
+		_, err := validator.Validate(question, response, "1.2.3.4:53")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("treats names outside every trust anchor as insecure", func() {
+		question.Name = "cloudfoundry.org."
+		response = respond("cloudfoundry.org.", dns.TypeA, dns.RcodeSuccess,
+			[]dns.RR{testRR("cloudfoundry.org. 60 IN A 10.0.0.4")}, nil)
+
+		isSecure, err := validator.Validate(question, response, "1.2.3.4:53")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(isSecure).To(BeFalse())
+		Expect(fakeExchanger.ExchangeCallCount()).To(Equal(0))
+	})
+
+	Context("when the name does not exist", func() {
+		BeforeEach(func() {
+			question.Name = "missing.example."
+			response = respond("missing.example.", dns.TypeA, dns.RcodeNameError, nil, append(
+				example.sign(testRR("insecure.example. 60 IN NSEC www.example. NS RRSIG NSEC")),
+				example.sign(testRR("example. 60 IN NSEC a.example. NS SOA RRSIG NSEC DNSKEY"))...,
+			))
+		})
+
+		It("accepts signed NSECs covering the name and the wildcard", func() {
+			isSecure, err := validator.Validate(question, response, "1.2.3.4:53")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(isSecure).To(BeTrue())
+		})
+
+		It("rejects an NSEC covering the name without proof that no wildcard exists", func() {
+			response.Ns = example.sign(testRR("insecure.example. 60 IN NSEC www.example. NS RRSIG NSEC"))
+
+			_, err := validator.Validate(question, response, "1.2.3.4:53")
+			Expect(err).To(MatchError(ContainSubstring("no proof of nonexistence for missing.example.")))
+		})
+
+		It("accepts NSEC3 records proving the closest encloser, the next closer name and the wildcard", func() {
+			encloser := dns.HashName("example.", dns.SHA1, 0, "")
+			response.Ns = append(
+				example.sign(testRR(encloser+".example. 60 IN NSEC3 1 0 0 - "+encloser+" NS SOA RRSIG DNSKEY NSEC3PARAM")),
+				example.sign(testRR(strings.Repeat("0", 32)+".example. 60 IN NSEC3 1 0 0 - "+strings.Repeat("V", 32)+" A RRSIG"))...,
+			)
+
+			isSecure, err := validator.Validate(question, response, "1.2.3.4:53")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(isSecure).To(BeTrue())
+		})
+
+		It("rejects NSEC3 records that do not prove the closest encloser", func() {
+			response.Ns = example.sign(testRR(strings.Repeat("0", 32) + ".example. 60 IN NSEC3 1 0 0 - " + strings.Repeat("V", 32) + " A RRSIG"))
+
+			_, err := validator.Validate(question, response, "1.2.3.4:53")
+			Expect(err).To(MatchError(ContainSubstring("no proof of nonexistence for missing.example.")))
+		})
+
+		It("rejects NSEC records that do not cover the name", func() {
+			response.Ns = example.sign(testRR("www.example. 60 IN NSEC zzz.example. A RRSIG NSEC"))
+
+			_, err := validator.Validate(question, response, "1.2.3.4:53")
+			Expect(err).To(MatchError(ContainSubstring("no proof of nonexistence for missing.example.")))
+		})
+	})
+
+	Context("when the answer is expanded from a wildcard", func() {
+		BeforeEach(func() {
+			expanded := example.sign(testRR("*.example. 60 IN A 10.0.0.9"))
+			for _, rr := range expanded {
+				rr.Header().Name = "www.example."
+			}
+			response.Answer = expanded
+		})
+
+		It("accepts it with an NSEC proving the name itself does not exist", func() {
+			response.Ns = example.sign(testRR("vvv.example. 60 IN NSEC xxx.example. A RRSIG NSEC"))
+
+			isSecure, err := validator.Validate(question, response, "1.2.3.4:53")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(isSecure).To(BeTrue())
+		})
+
+		It("accepts it with an NSEC3 covering the next closer name", func() {
+			response.Ns = example.sign(testRR(strings.Repeat("0", 32) + ".example. 60 IN NSEC3 1 0 0 - " + strings.Repeat("V", 32) + " A RRSIG"))
+
+			isSecure, err := validator.Validate(question, response, "1.2.3.4:53")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(isSecure).To(BeTrue())
+		})
+
+		It("rejects it without proof that the name does not exist", func() {
+			_, err := validator.Validate(question, response, "1.2.3.4:53")
+			Expect(err).To(MatchError(ContainSubstring("no proof that www.example. does not exist")))
+		})
+
+		It("rejects an NSEC that does not cover the name", func() {
+			response.Ns = example.sign(testRR("xxx.example. 60 IN NSEC zzz.example. A RRSIG NSEC"))
+
+			_, err := validator.Validate(question, response, "1.2.3.4:53")
+			Expect(err).To(MatchError(ContainSubstring("no proof that www.example. does not exist")))
+		})
+	})
+
+	Context("when the name has no records of the requested type", func() {
+		BeforeEach(func() {
+			question.Qtype = dns.TypeTXT
+			response = respond("www.example.", dns.TypeTXT, dns.RcodeSuccess, nil,
+				example.sign(testRR("www.example. 60 IN NSEC zzz.example. A RRSIG NSEC")))
+		})
+
+		It("accepts a signed NSEC at the name without the type", func() {
+			isSecure, err := validator.Validate(question, response, "1.2.3.4:53")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(isSecure).To(BeTrue())
+		})
+
+		It("rejects an NSEC that lists the type", func() {
+			question.Qtype = dns.TypeA
+
+			_, err := validator.Validate(question, response, "1.2.3.4:53")
+			Expect(err).To(MatchError(ContainSubstring("no proof of nonexistence")))
+		})
+	})
+
+	Context("when the upstream cannot be reached for keys", func() {
+		BeforeEach(func() {
+			fakeExchanger.ExchangeStub = nil
+			fakeExchanger.ExchangeReturns(nil, 0, errors.New("potato"))
+		})
+
+		It("returns an error", func() {
+			_, err := validator.Validate(question, response, "1.2.3.4:53")
+			Expect(err).To(MatchError(ContainSubstring("potato")))
+		})
+	})
+
+	Describe("LoadTrustAnchors", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "anchors")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("reads DS and DNSKEY records", func() {
+			path := filepath.Join(dir, "anchors")
+			contents := example.ds().String() + "\n" + secure.key.String() + "\n"
+			Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+
+			anchors, err := resolver.LoadTrustAnchors(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(anchors).To(HaveLen(2))
+			Expect(strings.ToLower(anchors[0].Digest)).To(Equal(strings.ToLower(example.ds().Digest)))
+			Expect(strings.ToLower(anchors[1].Digest)).To(Equal(strings.ToLower(secure.ds().Digest)))
+		})
+
+		It("fails when the file has no anchors", func() {
+			path := filepath.Join(dir, "anchors")
+			Expect(ioutil.WriteFile(path, []byte("example. 60 IN A 10.0.0.1\n"), 0600)).To(Succeed())
+
+			_, err := resolver.LoadTrustAnchors(path)
+			Expect(err).To(MatchError(ContainSubstring("no DS or DNSKEY records")))
+		})
+	})
+})
@@ -0,0 +1,68 @@
+package resolver
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+)
+
+// watchedFile remembers the modification time and size of the version of
+// a file that was last loaded, so that it is only reloaded when either
+// changes.
+type watchedFile struct {
+	mutex   sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+// loaded records info as the version that was loaded.
+func (f *watchedFile) loaded(info os.FileInfo) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+}
+
+func (f *watchedFile) changed(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return !info.ModTime().Equal(f.modTime) || info.Size() != f.size, nil
+}
+
+// poll calls load every interval in which the file at path changed, until
+// it is signalled.  Failures are logged as "<event>-stat-failed" and
+// "<event>-reload-failed".
+func (f *watchedFile) poll(logger lager.Logger, event, path string, interval time.Duration, load func() error, signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	close(ready)
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-ticker.C:
+			changed, err := f.changed(path)
+			if err != nil {
+				logger.Error(event+"-stat-failed", err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			if err := load(); err != nil {
+				logger.Error(event+"-reload-failed", err)
+			}
+		}
+	}
+}
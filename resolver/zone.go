@@ -0,0 +1,213 @@
+package resolver
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager"
+)
+
+type Zone struct {
+	Logger lager.Logger
+	Origin string
+	Path   string
+
+	mutex sync.RWMutex
+	data  *zoneData
+}
+
+type zoneData struct {
+	origin  string
+	soa     *dns.SOA
+	records map[string][]dns.RR
+}
+
+// Load parses the master file at Path.  If the file is invalid the
+// previously loaded data continues to be served.
+func (z *Zone) Load() error {
+	f, err := os.Open(z.Path)
+	if err != nil {
+		return fmt.Errorf("open zone %s: %s", z.Origin, err)
+	}
+	defer f.Close()
+
+	origin := strings.ToLower(dns.Fqdn(z.Origin))
+	data := &zoneData{
+		origin:  origin,
+		records: map[string][]dns.RR{},
+	}
+
+	for token := range dns.ParseZone(f, origin, z.Path) {
+		if token.Error != nil {
+			return fmt.Errorf("parse zone %s: %s", z.Origin, token.Error)
+		}
+
+		name := strings.ToLower(token.RR.Header().Name)
+		if !dns.IsSubDomain(origin, name) {
+			return fmt.Errorf("parse zone %s: %s is out of zone", z.Origin, token.RR.Header().Name)
+		}
+		if soa, ok := token.RR.(*dns.SOA); ok && name == origin {
+			data.soa = soa
+		}
+		data.records[name] = append(data.records[name], token.RR)
+	}
+
+	if data.soa == nil {
+		return fmt.Errorf("parse zone %s: missing SOA record", z.Origin)
+	}
+
+	z.mutex.Lock()
+	z.data = data
+	z.mutex.Unlock()
+
+	z.Logger.Info("zone-loaded", lager.Data{"origin": origin, "serial": data.soa.Serial})
+
+	return nil
+}
+
+func (z *Zone) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
+	z.mutex.RLock()
+	data := z.data
+	z.mutex.RUnlock()
+
+	m := &dns.Msg{}
+	if data == nil {
+		m.SetRcode(request, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+		return
+	}
+
+	m.SetReply(request)
+	data.answer(m, request.Question[0])
+
+	z.Logger.Info("zone-response", lager.Data{
+		"name":   request.Question[0].Name,
+		"rcode":  dns.RcodeToString[m.Rcode],
+		"answer": m.Answer,
+	})
+
+	w.WriteMsg(m)
+}
+
+func (d *zoneData) answer(m *dns.Msg, question dns.Question) {
+	name := strings.ToLower(question.Name)
+
+	for i := 0; i < maxCNAMEChain; i++ {
+		if cut := d.delegation(name, question.Qtype); cut != nil {
+			if len(m.Answer) == 0 {
+				m.Authoritative = false
+			}
+			m.Ns = append(m.Ns, cut...)
+			m.Extra = append(m.Extra, d.glue(cut)...)
+			return
+		}
+
+		m.Authoritative = true
+
+		rrs, exists := d.records[name]
+		if !exists && d.emptyNonTerminal(name) {
+			m.Ns = append(m.Ns, d.negativeSOA())
+			return
+		}
+
+		if !exists {
+			rrs, exists = d.wildcard(name)
+		}
+
+		// The rcode is that of the last name in a CNAME chain (RFC 6604).
+		if !exists {
+			m.Rcode = dns.RcodeNameError
+			m.Ns = append(m.Ns, d.negativeSOA())
+			return
+		}
+
+		cname, answers := matchRecords(rrs, question.Name, question.Qtype)
+		if cname == nil {
+			if len(answers) == 0 {
+				m.Ns = append(m.Ns, d.negativeSOA())
+			}
+			m.Answer = append(m.Answer, answers...)
+			return
+		}
+
+		m.Answer = append(m.Answer, cname)
+
+		target := strings.ToLower(cname.Target)
+		if !dns.IsSubDomain(d.origin, target) {
+			return
+		}
+		question.Name = cname.Target
+		name = target
+	}
+}
+
+// delegation returns the NS records of the closest zone cut at or above name,
+// if there is one below the origin.  DS queries at the cut itself are
+// answered by the parent.
+func (d *zoneData) delegation(name string, qtype uint16) []dns.RR {
+	labels := dns.SplitDomainName(name)
+	for i := len(labels) - dns.CountLabel(d.origin) - 1; i >= 0; i-- {
+		candidate := dns.Fqdn(strings.Join(labels[i:], "."))
+		if candidate == name && qtype == dns.TypeDS {
+			continue
+		}
+
+		ns := []dns.RR{}
+		for _, rr := range d.records[candidate] {
+			if rr.Header().Rrtype == dns.TypeNS {
+				ns = append(ns, rr)
+			}
+		}
+		if len(ns) > 0 {
+			return ns
+		}
+	}
+	return nil
+}
+
+func (d *zoneData) glue(ns []dns.RR) []dns.RR {
+	glue := []dns.RR{}
+	for _, rr := range ns {
+		target := strings.ToLower(rr.(*dns.NS).Ns)
+		for _, g := range d.records[target] {
+			if t := g.Header().Rrtype; t == dns.TypeA || t == dns.TypeAAAA {
+				glue = append(glue, g)
+			}
+		}
+	}
+	return glue
+}
+
+// wildcard looks for a wildcard at the closest encloser of name.
+func (d *zoneData) wildcard(name string) ([]dns.RR, bool) {
+	labels := dns.SplitDomainName(name)
+	for i := 1; i <= len(labels)-dns.CountLabel(d.origin); i++ {
+		encloser := dns.Fqdn(strings.Join(labels[i:], "."))
+		if _, ok := d.records[encloser]; ok || d.emptyNonTerminal(encloser) || encloser == d.origin {
+			rrs, ok := d.records["*."+encloser]
+			return rrs, ok
+		}
+	}
+	return nil, false
+}
+
+func (d *zoneData) emptyNonTerminal(name string) bool {
+	for owner := range d.records {
+		if owner != name && dns.IsSubDomain(name, owner) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *zoneData) negativeSOA() dns.RR {
+	soa := *d.soa
+	soa.Hdr.Ttl = d.soa.Minttl
+	if d.soa.Hdr.Ttl < soa.Hdr.Ttl {
+		soa.Hdr.Ttl = d.soa.Hdr.Ttl
+	}
+	return &soa
+}
@@ -0,0 +1,178 @@
+package resolver_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const testZone = `$TTL 300
+@        IN SOA ns1.cell.internal. admin.cell.internal. 2016041901 3600 600 86400 60
+@        IN NS  ns1.cell.internal.
+ns1      IN A   10.0.0.53
+db       IN A   10.0.0.5
+db       IN A   10.0.0.6
+www      IN CNAME db
+ext      IN CNAME www.example.com.
+gone     IN CNAME missing
+*.apps   IN A   10.0.0.80
+a.b      IN TXT "deep"
+sub      IN NS  ns.sub.cell.internal.
+ns.sub   IN A   10.0.1.53
+sub      IN DS  12345 8 2 4A4B5C6D7E8F9A0B1C2D3E4F5A6B7C8D9E0F1A2B3C4D5E6F7A8B9C0D1E2F3A4B
+`
+
+var _ = Describe("Zone", func() {
+	var (
+		zone           *resolver.Zone
+		responseWriter *fakes.ResponseWriter
+		tempDir        string
+		zonePath       string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "zone")
+		Expect(err).NotTo(HaveOccurred())
+		zonePath = filepath.Join(tempDir, "cell.internal.zone")
+		Expect(ioutil.WriteFile(zonePath, []byte(testZone), 0644)).To(Succeed())
+
+		zone = &resolver.Zone{
+			Logger: lagertest.NewTestLogger("test"),
+			Origin: "cell.internal",
+			Path:   zonePath,
+		}
+		Expect(zone.Load()).To(Succeed())
+
+		responseWriter = &fakes.ResponseWriter{}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	query := func(name string, qtype uint16) *dns.Msg {
+		request := &dns.Msg{}
+		request.SetQuestion(name, qtype)
+		zone.ServeDNS(responseWriter, request)
+		Expect(responseWriter.WriteMsgCallCount()).To(Equal(1))
+		return responseWriter.WriteMsgArgsForCall(0)
+	}
+
+	It("answers authoritatively with the matching records", func() {
+		resp := query("db.cell.internal.", dns.TypeA)
+
+		Expect(resp.Authoritative).To(BeTrue())
+		Expect(resp.Rcode).To(Equal(dns.RcodeSuccess))
+		Expect(resp.Answer).To(HaveLen(2))
+		Expect(resp.Answer[0].(*dns.A).A.String()).To(Equal("10.0.0.5"))
+	})
+
+	It("answers the apex SOA and NS records", func() {
+		Expect(query("cell.internal.", dns.TypeSOA).Answer[0].(*dns.SOA).Serial).To(Equal(uint32(2016041901)))
+	})
+
+	It("follows CNAMEs within the zone", func() {
+		resp := query("www.cell.internal.", dns.TypeA)
+
+		Expect(resp.Answer).To(HaveLen(3))
+		Expect(resp.Answer[0].(*dns.CNAME).Target).To(Equal("db.cell.internal."))
+		Expect(resp.Answer[1].Header().Name).To(Equal("db.cell.internal."))
+	})
+
+	It("answers NXDOMAIN with the CNAME when the target does not exist", func() {
+		resp := query("gone.cell.internal.", dns.TypeA)
+
+		Expect(resp.Rcode).To(Equal(dns.RcodeNameError))
+		Expect(resp.Answer).To(HaveLen(1))
+		Expect(resp.Answer[0].(*dns.CNAME).Target).To(Equal("missing.cell.internal."))
+		Expect(resp.Ns[0]).To(BeAssignableToTypeOf(&dns.SOA{}))
+	})
+
+	It("answers only the CNAME when the target is outside the zone", func() {
+		resp := query("ext.cell.internal.", dns.TypeA)
+
+		Expect(resp.Answer).To(HaveLen(1))
+		Expect(resp.Authoritative).To(BeTrue())
+	})
+
+	It("answers NODATA with the SOA when the name has no records of the type", func() {
+		resp := query("db.cell.internal.", dns.TypeMX)
+
+		Expect(resp.Rcode).To(Equal(dns.RcodeSuccess))
+		Expect(resp.Answer).To(BeEmpty())
+		Expect(resp.Ns).To(HaveLen(1))
+		Expect(resp.Ns[0].(*dns.SOA).Hdr.Ttl).To(Equal(uint32(60)))
+	})
+
+	It("answers NODATA for empty non-terminals", func() {
+		resp := query("b.cell.internal.", dns.TypeA)
+
+		Expect(resp.Rcode).To(Equal(dns.RcodeSuccess))
+		Expect(resp.Answer).To(BeEmpty())
+	})
+
+	It("answers NXDOMAIN with the SOA for names that do not exist", func() {
+		resp := query("missing.cell.internal.", dns.TypeA)
+
+		Expect(resp.Authoritative).To(BeTrue())
+		Expect(resp.Rcode).To(Equal(dns.RcodeNameError))
+		Expect(resp.Ns[0]).To(BeAssignableToTypeOf(&dns.SOA{}))
+	})
+
+	It("synthesizes answers from wildcards", func() {
+		resp := query("my-app.apps.cell.internal.", dns.TypeA)
+
+		Expect(resp.Answer).To(HaveLen(1))
+		Expect(resp.Answer[0].Header().Name).To(Equal("my-app.apps.cell.internal."))
+		Expect(resp.Answer[0].(*dns.A).A.String()).To(Equal("10.0.0.80"))
+	})
+
+	It("refers queries below a delegation to the child's name servers", func() {
+		resp := query("host.sub.cell.internal.", dns.TypeA)
+
+		Expect(resp.Authoritative).To(BeFalse())
+		Expect(resp.Rcode).To(Equal(dns.RcodeSuccess))
+		Expect(resp.Answer).To(BeEmpty())
+		Expect(resp.Ns).To(HaveLen(1))
+		Expect(resp.Ns[0].(*dns.NS).Ns).To(Equal("ns.sub.cell.internal."))
+		Expect(resp.Extra).To(HaveLen(1))
+		Expect(resp.Extra[0].(*dns.A).A.String()).To(Equal("10.0.1.53"))
+	})
+
+	It("answers DS queries at the delegation from the parent", func() {
+		resp := query("sub.cell.internal.", dns.TypeDS)
+
+		Expect(resp.Authoritative).To(BeTrue())
+		Expect(resp.Answer).To(HaveLen(1))
+	})
+
+	Describe("Load", func() {
+		It("keeps serving the previous zone when the new one is invalid", func() {
+			Expect(ioutil.WriteFile(zonePath, []byte("this is not a zone\n"), 0644)).To(Succeed())
+
+			Expect(zone.Load()).To(MatchError(ContainSubstring("parse zone cell.internal")))
+			Expect(query("db.cell.internal.", dns.TypeA).Answer).To(HaveLen(2))
+		})
+
+		It("requires an SOA record", func() {
+			Expect(ioutil.WriteFile(zonePath, []byte("db 300 IN A 10.0.0.5\n"), 0644)).To(Succeed())
+
+			Expect(zone.Load()).To(MatchError("parse zone cell.internal: missing SOA record"))
+		})
+
+		It("rejects records outside of the zone", func() {
+			Expect(ioutil.WriteFile(zonePath, []byte(testZone+"www.example.com. 300 IN A 1.2.3.4\n"), 0644)).To(Succeed())
+
+			Expect(zone.Load()).To(MatchError("parse zone cell.internal: www.example.com. is out of zone"))
+		})
+	})
+})
@@ -0,0 +1,232 @@
+package resolver
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager"
+)
+
+const transferChunkSize = 200
+
+var errZoneNotLoaded = errors.New("overlay zone not loaded")
+
+// ZoneTransfer serves transfers and the apex of Zone, which Run rebuilds
+// every Interval from the instances of Source, the records in Registry and
+// the alias CNAMEs in Aliases.  Each listing of the instances is also
+// handed to ChangeLog, if set, and HealthChecker, if set, forgets the
+// instances it no longer has.
+type ZoneTransfer struct {
+	Logger          lager.Logger
+	Source          RecordSource
+	Registry        *Registry
+	Aliases         *AliasStore
+	ChangeLog       *ChangeLog
+	HealthChecker   *HealthChecker
+	Zone            *OverlayZone
+	Interval        time.Duration
+	AllowedNetworks []*net.IPNet
+	RequireTSIG     bool
+	Next            dns.Handler
+}
+
+func (t *ZoneTransfer) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
+	question := request.Question[0]
+	if request.Opcode != dns.OpcodeQuery || strings.ToLower(question.Name) != t.Zone.origin() {
+		t.Next.ServeDNS(w, request)
+		return
+	}
+
+	switch question.Qtype {
+	case dns.TypeAXFR, dns.TypeIXFR:
+		t.transfer(w, request)
+	case dns.TypeSOA, dns.TypeNS:
+		t.apex(w, request)
+	default:
+		t.Next.ServeDNS(w, request)
+	}
+}
+
+// Refresh rebuilds the zone.  When the source fails the zone keeps the
+// records it had.
+func (t *ZoneTransfer) Refresh() error {
+	instances, err := t.Source.AllInstances()
+	if err != nil {
+		return err
+	}
+	t.Zone.Update(instances, t.records()...)
+
+	if t.HealthChecker != nil {
+		t.HealthChecker.Retain(instances)
+	}
+	if t.ChangeLog != nil {
+		if err := t.ChangeLog.Record(instances); err != nil {
+			t.Logger.Error("change-log-failed", err)
+		}
+	}
+	return nil
+}
+
+// records returns the registered records and alias CNAMEs in the zone.
+func (t *ZoneTransfer) records() []dns.RR {
+	origin := t.Zone.origin()
+
+	rrs := []dns.RR{}
+	if t.Registry != nil {
+		for _, rr := range t.Registry.Records() {
+			if dns.IsSubDomain(origin, strings.ToLower(rr.Header().Name)) {
+				rrs = append(rrs, rr)
+			}
+		}
+	}
+	if t.Aliases != nil {
+		for alias, target := range t.Aliases.List() {
+			rrs = append(rrs, &dns.CNAME{
+				Hdr: dns.RR_Header{
+					Name:   alias + "." + origin,
+					Rrtype: dns.TypeCNAME,
+					Class:  dns.ClassINET,
+					Ttl:    uint32(t.Zone.TTL),
+				},
+				Target: target + "." + origin,
+			})
+		}
+	}
+	return rrs
+}
+
+func (t *ZoneTransfer) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+
+	close(ready)
+
+	for {
+		if err := t.Refresh(); err != nil {
+			t.Logger.Error("ducati-client-error", err)
+		}
+
+		select {
+		case <-signals:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Records lists the records of the zone as they are now, from a listing
+// of Source made for the call rather than the last rebuild.
+func (t *ZoneTransfer) Records() ([]dns.RR, error) {
+	instances, err := t.Source.AllInstances()
+	if err != nil {
+		return nil, err
+	}
+	return t.Zone.RecordsFor(instances, t.records()...), nil
+}
+
+func (t *ZoneTransfer) apex(w dns.ResponseWriter, request *dns.Msg) {
+	m := &dns.Msg{}
+	m.SetReply(request)
+	m.Authoritative = true
+	if request.Question[0].Qtype == dns.TypeSOA {
+		m.Answer = []dns.RR{t.Zone.SOA()}
+	} else {
+		m.Answer = []dns.RR{t.Zone.NS()}
+	}
+	w.WriteMsg(m)
+}
+
+func (t *ZoneTransfer) transfer(w dns.ResponseWriter, request *dns.Msg) {
+	qtype := dns.TypeToString[request.Question[0].Qtype]
+	client := remoteIP(w)
+	logger := t.Logger.Session("zone-transfer", lager.Data{"client": client, "qtype": qtype})
+
+	if !t.allowed(w, client) {
+		logger.Info("transfer-refused")
+		t.reject(w, request, dns.RcodeRefused)
+		return
+	}
+
+	if t.RequireTSIG && (request.IsTsig() == nil || w.TsigStatus() != nil) {
+		logger.Info("transfer-not-authorized")
+		t.reject(w, request, dns.RcodeNotAuth)
+		return
+	}
+
+	if !t.Zone.Loaded() {
+		logger.Error("transfer-failed", errZoneNotLoaded)
+		t.reject(w, request, dns.RcodeServerFailure)
+		return
+	}
+
+	var rrs []dns.RR
+	if request.Question[0].Qtype == dns.TypeIXFR {
+		var ok bool
+		if serial, found := ixfrSerial(request); found {
+			rrs, ok = t.Zone.IXFR(serial)
+		}
+		if !ok {
+			rrs = t.Zone.AXFR()
+		}
+	} else {
+		rrs = t.Zone.AXFR()
+	}
+
+	for len(rrs) > 0 {
+		n := transferChunkSize
+		if n > len(rrs) {
+			n = len(rrs)
+		}
+
+		m := &dns.Msg{}
+		m.SetReply(request)
+		m.Authoritative = true
+		m.Answer = rrs[:n]
+		signReply(w, request, m)
+
+		if err := w.WriteMsg(m); err != nil {
+			logger.Error("write-failed", err)
+			return
+		}
+		rrs = rrs[n:]
+	}
+
+	logger.Info("transfer-complete", lager.Data{"serial": t.Zone.SOA().Serial})
+}
+
+func (t *ZoneTransfer) allowed(w dns.ResponseWriter, client string) bool {
+	if _, ok := w.RemoteAddr().(*net.UDPAddr); ok {
+		return false
+	}
+
+	ip := net.ParseIP(client)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range t.AllowedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *ZoneTransfer) reject(w dns.ResponseWriter, request *dns.Msg, rcode int) {
+	m := &dns.Msg{}
+	m.SetRcode(request, rcode)
+	w.WriteMsg(m)
+}
+
+func ixfrSerial(request *dns.Msg) (uint32, bool) {
+	for _, rr := range request.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial, true
+		}
+	}
+	return 0, false
+}
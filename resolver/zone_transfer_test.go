@@ -0,0 +1,315 @@
+package resolver_test
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("ZoneTransfer", func() {
+	var (
+		zoneTransfer   *resolver.ZoneTransfer
+		zone           *resolver.OverlayZone
+		fakeSource     *fakes.RecordSource
+		nextHandler    *fakes.Handler
+		responseWriter *fakes.ResponseWriter
+		request        *dns.Msg
+		fakeLogger     *lagertest.TestLogger
+	)
+
+	BeforeEach(func() {
+		fakeLogger = lagertest.NewTestLogger("test")
+		fakeSource = &fakes.RecordSource{}
+		fakeSource.AllInstancesReturns([]resolver.Instance{
+			{IP: "10.0.0.1", App: "app-a"},
+			{IP: "10.0.0.2", App: "app-b"},
+		}, nil)
+		nextHandler = &fakes.Handler{}
+		zone = resolver.NewOverlayZone("potato", 42)
+
+		_, network, err := net.ParseCIDR("10.255.0.0/16")
+		Expect(err).NotTo(HaveOccurred())
+
+		zoneTransfer = &resolver.ZoneTransfer{
+			Logger:          fakeLogger,
+			Source:          fakeSource,
+			Zone:            zone,
+			AllowedNetworks: []*net.IPNet{network},
+			Next:            nextHandler,
+		}
+
+		responseWriter = &fakes.ResponseWriter{}
+		responseWriter.RemoteAddrReturns(&net.TCPAddr{IP: net.ParseIP("10.255.1.1"), Port: 5300})
+
+		request = &dns.Msg{}
+		request.SetAxfr("potato.")
+	})
+
+	JustBeforeEach(func() {
+		zoneTransfer.Refresh()
+	})
+
+	It("transfers the overlay zone", func() {
+		zoneTransfer.ServeDNS(responseWriter, request)
+
+		Expect(fakeSource.AllInstancesCallCount()).To(Equal(1))
+		Expect(responseWriter.WriteMsgCallCount()).To(Equal(1))
+
+		resp := responseWriter.WriteMsgArgsForCall(0)
+		Expect(resp.Authoritative).To(BeTrue())
+		Expect(resp.Answer).To(Equal(zone.AXFR()))
+		Expect(resp.Answer).To(HaveLen(5))
+	})
+
+	It("lists the overlay records as they are now, not as last refreshed", func() {
+		fakeSource.AllInstancesReturns([]resolver.Instance{{IP: "10.0.0.3", App: "app-c"}}, nil)
+
+		records, err := zoneTransfer.Records()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(Equal([]dns.RR{testRR("app-c.potato. 42 IN A 10.0.0.3")}))
+		Expect(fakeSource.AllInstancesCallCount()).To(Equal(2))
+		Expect(zone.Records()).To(HaveLen(2))
+	})
+
+	It("keeps the records it had when a refresh fails", func() {
+		fakeSource.AllInstancesReturns(nil, errors.New("potato"))
+		Expect(zoneTransfer.Refresh()).To(MatchError("potato"))
+
+		Expect(zone.Records()).To(HaveLen(2))
+	})
+
+	Context("when a change log is given", func() {
+		var history *resolver.ChangeHistory
+
+		BeforeEach(func() {
+			history = &resolver.ChangeHistory{}
+			zoneTransfer.ChangeLog = &resolver.ChangeLog{Logger: fakeLogger, Suffix: "potato", History: history}
+		})
+
+		It("hands it every listing the rebuild makes", func() {
+			fakeSource.AllInstancesReturns([]resolver.Instance{{IP: "10.0.0.1", App: "app-a"}}, nil)
+			Expect(zoneTransfer.Refresh()).To(Succeed())
+
+			changes := history.Changes()
+			Expect(changes).To(HaveLen(1))
+			Expect(changes[0].Type).To(Equal(resolver.ChangeRemoved))
+			Expect(changes[0].Instance.IP).To(Equal("10.0.0.2"))
+			Expect(fakeSource.AllInstancesCallCount()).To(Equal(2))
+		})
+	})
+
+	Context("when registered records and aliases are given", func() {
+		BeforeEach(func() {
+			zoneTransfer.Registry = &resolver.Registry{}
+			zoneTransfer.Registry.Add(testRR("db.potato. 60 IN TXT \"primary\""), time.Hour)
+			zoneTransfer.Registry.Add(testRR("db.example.com. 60 IN TXT \"elsewhere\""), time.Hour)
+
+			zoneTransfer.Aliases = &resolver.AliasStore{}
+			zoneTransfer.Aliases.Set("www", "app-a")
+		})
+
+		It("includes those in the zone", func() {
+			records, err := zoneTransfer.Records()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(HaveLen(4))
+			Expect(records).To(ContainElement(testRR("www.potato. 42 IN CNAME app-a.potato.")))
+			Expect(records).To(ContainElement(testRR("db.potato. 60 IN TXT \"primary\"")))
+
+			Expect(zone.Types("www.potato.")).To(Equal([]uint16{dns.TypeCNAME}))
+		})
+	})
+
+	Context("when running", func() {
+		var process ifrit.Process
+
+		BeforeEach(func() {
+			zoneTransfer.Interval = 10 * time.Millisecond
+		})
+
+		JustBeforeEach(func() {
+			listed, _ := fakeSource.AllInstances()
+			listings := 0
+			fakeSource.AllInstancesStub = func() ([]resolver.Instance, error) {
+				listings++
+				if listings == 1 {
+					return listed, nil
+				}
+				return []resolver.Instance{{IP: "10.0.0.3", App: "app-c"}}, nil
+			}
+
+			process = ifrit.Invoke(zoneTransfer)
+		})
+
+		AfterEach(func() {
+			ginkgomon.Interrupt(process)
+		})
+
+		It("refreshes the zone every interval", func() {
+			Eventually(func() []uint16 {
+				return zone.Types("app-c.potato.")
+			}).Should(Equal([]uint16{dns.TypeA}))
+		})
+	})
+
+	It("logs the transfer", func() {
+		zoneTransfer.ServeDNS(responseWriter, request)
+
+		Expect(fakeLogger).To(gbytes.Say("zone-transfer.transfer-complete"))
+	})
+
+	It("splits large zones across several messages", func() {
+		containers := []resolver.Instance{}
+		for i := 0; i < 250; i++ {
+			containers = append(containers, resolver.Instance{IP: net.IPv4(10, 1, byte(i/256), byte(i%256)).String(), App: "big-app"})
+		}
+		fakeSource.AllInstancesReturns(containers, nil)
+		Expect(zoneTransfer.Refresh()).To(Succeed())
+
+		zoneTransfer.ServeDNS(responseWriter, request)
+
+		Expect(responseWriter.WriteMsgCallCount()).To(Equal(2))
+		Expect(responseWriter.WriteMsgArgsForCall(0).Answer).To(HaveLen(200))
+		Expect(responseWriter.WriteMsgArgsForCall(1).Answer).To(HaveLen(53))
+	})
+
+	Context("when the client asks for an incremental transfer", func() {
+		var initialSerial uint32
+
+		BeforeEach(func() {
+			initialSerial = zone.Update([]resolver.Instance{{IP: "10.0.0.1", App: "app-a"}})
+
+			request = &dns.Msg{}
+			request.SetIxfr("potato.", initialSerial, "ns.potato.", "hostmaster.potato.")
+		})
+
+		It("sends the changes since the client's serial", func() {
+			zoneTransfer.ServeDNS(responseWriter, request)
+
+			answer := responseWriter.WriteMsgArgsForCall(0).Answer
+			expected, ok := zone.IXFR(initialSerial)
+			Expect(ok).To(BeTrue())
+			Expect(answer).To(Equal(expected))
+			Expect(answer[len(answer)-2].(*dns.A).A.String()).To(Equal("10.0.0.2"))
+		})
+
+		Context("when the history does not reach the client's serial", func() {
+			BeforeEach(func() {
+				request.SetIxfr("potato.", initialSerial-100, "ns.potato.", "hostmaster.potato.")
+			})
+
+			It("falls back to a full transfer", func() {
+				zoneTransfer.ServeDNS(responseWriter, request)
+
+				Expect(responseWriter.WriteMsgArgsForCall(0).Answer).To(Equal(zone.AXFR()))
+			})
+		})
+	})
+
+	Context("when the client is not in an allowed network", func() {
+		BeforeEach(func() {
+			responseWriter.RemoteAddrReturns(&net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 5300})
+		})
+
+		It("refuses the transfer", func() {
+			zoneTransfer.ServeDNS(responseWriter, request)
+
+			Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeRefused))
+		})
+	})
+
+	Context("when the transfer is requested over UDP", func() {
+		BeforeEach(func() {
+			responseWriter.RemoteAddrReturns(&net.UDPAddr{IP: net.ParseIP("10.255.1.1"), Port: 5300})
+		})
+
+		It("refuses the transfer", func() {
+			zoneTransfer.ServeDNS(responseWriter, request)
+
+			Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeRefused))
+		})
+	})
+
+	Context("when TSIG is required", func() {
+		BeforeEach(func() {
+			zoneTransfer.RequireTSIG = true
+		})
+
+		It("rejects unsigned requests", func() {
+			zoneTransfer.ServeDNS(responseWriter, request)
+
+			Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeNotAuth))
+		})
+
+		It("rejects requests whose signature does not verify", func() {
+			request.SetTsig("transfer-key.", dns.HmacSHA256, 300, 0)
+			responseWriter.TsigStatusReturns(dns.ErrSig)
+
+			zoneTransfer.ServeDNS(responseWriter, request)
+
+			Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeNotAuth))
+		})
+
+		It("signs the transfer when the request is signed", func() {
+			request.SetTsig("transfer-key.", dns.HmacSHA256, 300, 0)
+
+			zoneTransfer.ServeDNS(responseWriter, request)
+
+			resp := responseWriter.WriteMsgArgsForCall(0)
+			Expect(resp.Rcode).To(Equal(dns.RcodeSuccess))
+			Expect(resp.IsTsig()).NotTo(BeNil())
+			Expect(resp.IsTsig().Hdr.Name).To(Equal("transfer-key."))
+		})
+	})
+
+	Context("when the zone has never been refreshed", func() {
+		BeforeEach(func() {
+			fakeSource.AllInstancesReturns(nil, errors.New("potato"))
+		})
+
+		It("responds with SERVFAIL", func() {
+			zoneTransfer.ServeDNS(responseWriter, request)
+
+			Expect(responseWriter.WriteMsgArgsForCall(0).Rcode).To(Equal(dns.RcodeServerFailure))
+		})
+
+		It("fails to list the records", func() {
+			_, err := zoneTransfer.Records()
+			Expect(err).To(MatchError("potato"))
+		})
+	})
+
+	It("answers SOA queries at the apex", func() {
+		request.SetQuestion("potato.", dns.TypeSOA)
+		zoneTransfer.ServeDNS(responseWriter, request)
+
+		resp := responseWriter.WriteMsgArgsForCall(0)
+		Expect(resp.Authoritative).To(BeTrue())
+		Expect(resp.Answer).To(Equal([]dns.RR{zone.SOA()}))
+	})
+
+	It("passes other opcodes at the apex to the next handler", func() {
+		request.SetUpdate("potato.")
+		zoneTransfer.ServeDNS(responseWriter, request)
+
+		Expect(nextHandler.ServeDNSCallCount()).To(Equal(1))
+	})
+
+	It("passes other queries to the next handler", func() {
+		request.SetQuestion("app-a.potato.", dns.TypeA)
+		zoneTransfer.ServeDNS(responseWriter, request)
+
+		Expect(nextHandler.ServeDNSCallCount()).To(Equal(1))
+		Expect(responseWriter.WriteMsgCallCount()).To(Equal(0))
+	})
+})
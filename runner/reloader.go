@@ -0,0 +1,38 @@
+package runner
+
+import (
+	"os"
+
+	"github.com/pivotal-golang/lager"
+)
+
+//go:generate counterfeiter -o ../fakes/loader.go --fake-name Loader . Loader
+type Loader interface {
+	Load() error
+}
+
+type Reloader struct {
+	Logger  lager.Logger
+	Reloads <-chan os.Signal
+	Loaders []Loader
+}
+
+func (r *Reloader) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+
+	for {
+		select {
+		case <-signals:
+			return nil
+
+		case sig := <-r.Reloads:
+			r.Logger.Info("reloading", lager.Data{"signal": sig.String()})
+			for _, l := range r.Loaders {
+				if err := l.Load(); err != nil {
+					r.Logger.Error("reload-failed", err)
+				}
+			}
+			r.Logger.Info("reload-complete")
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package runner_test
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/runner"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Reloader", func() {
+	var (
+		reloader   *runner.Reloader
+		reloads    chan os.Signal
+		loader1    *fakes.Loader
+		loader2    *fakes.Loader
+		fakeLogger *lagertest.TestLogger
+		process    ifrit.Process
+	)
+
+	BeforeEach(func() {
+		reloads = make(chan os.Signal, 1)
+		loader1 = &fakes.Loader{}
+		loader2 = &fakes.Loader{}
+		fakeLogger = lagertest.NewTestLogger("test")
+		reloader = &runner.Reloader{
+			Logger:  fakeLogger,
+			Reloads: reloads,
+			Loaders: []runner.Loader{loader1, loader2},
+		}
+		process = ifrit.Invoke(reloader)
+	})
+
+	AfterEach(func() {
+		ginkgomon.Kill(process)
+	})
+
+	It("does not load anything until a reload is requested", func() {
+		Consistently(loader1.LoadCallCount).Should(Equal(0))
+	})
+
+	It("reloads every loader when a reload is requested", func() {
+		reloads <- syscall.SIGHUP
+
+		Eventually(loader1.LoadCallCount).Should(Equal(1))
+		Eventually(loader2.LoadCallCount).Should(Equal(1))
+		Eventually(fakeLogger).Should(gbytes.Say("reloading.*hangup"))
+	})
+
+	Context("when a loader fails", func() {
+		BeforeEach(func() {
+			loader1.LoadReturns(errors.New("bad zone"))
+		})
+
+		It("logs the error and continues with the other loaders", func() {
+			reloads <- syscall.SIGHUP
+
+			Eventually(loader2.LoadCallCount).Should(Equal(1))
+			Expect(fakeLogger).To(gbytes.Say("reload-failed.*bad zone"))
+		})
+	})
+
+	It("exits cleanly when signaled", func() {
+		ginkgomon.Interrupt(process)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+	})
+})
@@ -1,13 +1,13 @@
 package runner
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"os"
+	"sync"
 
-	"github.com/cloudfoundry-incubator/ducati-dns/resolver"
 	"github.com/miekg/dns"
-	"github.com/pivotal-golang/lager"
 )
 
 //go:generate counterfeiter -o ../fakes/dns_server.go --fake-name DNSServer . dnsServer
@@ -16,41 +16,50 @@ type dnsServer interface {
 	Shutdown() error
 }
 
+// Runner serves DNS until signaled.  It becomes ready only once the server
+// has bound its listener, which it reports by closing Started.
 type Runner struct {
 	DNSServer dnsServer
+	Started   chan struct{}
+
+	mutex   sync.Mutex
+	bound   bool
+	stopped error
+}
+
+func newRunner(server *dns.Server) *Runner {
+	started := make(chan struct{})
+	var once sync.Once
+	server.NotifyStartedFunc = func() {
+		once.Do(func() { close(started) })
+	}
+	return &Runner{DNSServer: server, Started: started}
 }
 
 func New(
-	logger lager.Logger,
-	config resolver.Config,
-	externalDNSServer string,
+	handler dns.Handler,
 	listener net.PacketConn,
 	decorateWriter dns.DecorateWriter,
+	tsigSecrets map[string]string,
 ) *Runner {
-	forwardingResolver := &resolver.ForwardingResolver{
-		Logger:    logger.Session("forwarding-resolver"),
-		Exchanger: &dns.Client{Net: "udp"},
-		Server:    externalDNSServer,
-	}
-
-	httpResolver := resolver.NewHTTPResolver(logger, config)
-
-	resolverMuxer := &resolver.Muxer{
-		Logger:               logger,
-		Suffix:               config.DucatiSuffix,
-		SuffixPresentHandler: httpResolver,
-		DefaultHandler:       forwardingResolver,
-	}
-
-	dnsRunner := &Runner{
-		DNSServer: &dns.Server{
-			PacketConn:     listener,
-			Handler:        resolverMuxer,
-			DecorateWriter: decorateWriter,
-		},
-	}
+	return newRunner(&dns.Server{
+		PacketConn:     listener,
+		Handler:        handler,
+		DecorateWriter: decorateWriter,
+		TsigSecret:     tsigSecrets,
+	})
+}
 
-	return dnsRunner
+func NewTCP(
+	handler dns.Handler,
+	listener net.Listener,
+	tsigSecrets map[string]string,
+) *Runner {
+	return newRunner(&dns.Server{
+		Listener:   listener,
+		Handler:    handler,
+		TsigSecret: tsigSecrets,
+	})
 }
 
 func (r *Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
@@ -59,18 +68,61 @@ func (r *Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 		errCh <- r.DNSServer.ActivateAndServe()
 	}()
 
-	close(ready)
-
+	started := r.Started
 	for {
 		select {
+		case <-started:
+			started = nil
+			r.setState(true, nil)
+			close(ready)
+
 		case err := <-errCh:
 			if err != nil {
-				return fmt.Errorf("activate and serve: %s", err)
+				err = fmt.Errorf("activate and serve: %s", err)
 			}
-			return nil
+			r.setState(false, stoppedError(err))
+			return err
 
 		case <-signals:
+			r.setState(false, errors.New("shut down"))
 			return r.DNSServer.Shutdown()
 		}
 	}
 }
+
+// Alive returns an error once the server loop has exited.
+func (r *Runner) Alive() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.stopped
+}
+
+// Listening returns an error unless the server has bound its listener and
+// is still serving.
+func (r *Runner) Listening() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.stopped != nil {
+		return r.stopped
+	}
+	if !r.bound {
+		return errors.New("not listening yet")
+	}
+	return nil
+}
+
+func (r *Runner) setState(bound bool, stopped error) {
+	r.mutex.Lock()
+	r.bound = bound
+	r.stopped = stopped
+	r.mutex.Unlock()
+}
+
+func stoppedError(err error) error {
+	if err != nil {
+		return err
+	}
+	return errors.New("server exited")
+}
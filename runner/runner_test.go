@@ -16,13 +16,20 @@ var _ = Describe("Runner", func() {
 	var (
 		r         *runner.Runner
 		dnsServer *fakes.DNSServer
+		started   chan struct{}
 		process   ifrit.Process
 	)
 
 	BeforeEach(func() {
+		started = make(chan struct{})
 		dnsServer = &fakes.DNSServer{}
+		dnsServer.ActivateAndServeStub = func() error {
+			close(started)
+			select {}
+		}
 		r = &runner.Runner{
 			DNSServer: dnsServer,
+			Started:   started,
 		}
 	})
 
@@ -40,6 +47,7 @@ var _ = Describe("Runner", func() {
 	It("shuts down when signaled", func() {
 		done := make(chan struct{}, 1)
 		dnsServer.ActivateAndServeStub = func() error {
+			close(started)
 			<-done
 			return nil
 		}
@@ -57,6 +65,24 @@ var _ = Describe("Runner", func() {
 		Expect(dnsServer.ShutdownCallCount()).To(Equal(1))
 	})
 
+	It("is not ready until the server has bound its listener", func() {
+		bind := make(chan struct{})
+		dnsServer.ActivateAndServeStub = func() error {
+			<-bind
+			close(started)
+			select {}
+		}
+
+		process = ifrit.Background(r)
+		Consistently(process.Ready()).ShouldNot(BeClosed())
+		Expect(r.Listening()).To(MatchError("not listening yet"))
+		Expect(r.Alive()).To(Succeed())
+
+		close(bind)
+		Eventually(process.Ready()).Should(BeClosed())
+		Expect(r.Listening()).To(Succeed())
+	})
+
 	Context("when ActivateAndServe fails", func() {
 		BeforeEach(func() {
 			dnsServer.ActivateAndServeReturns(errors.New("welp"))
@@ -66,5 +92,13 @@ var _ = Describe("Runner", func() {
 			process = ifrit.Background(r)
 			Eventually(process.Wait()).Should(Receive(MatchError("activate and serve: welp")))
 		})
+
+		It("reports that it is no longer alive", func() {
+			process = ifrit.Background(r)
+			Eventually(process.Wait()).Should(Receive())
+
+			Expect(r.Alive()).To(MatchError("activate and serve: welp"))
+			Expect(r.Listening()).To(MatchError("activate and serve: welp"))
+		})
 	})
 })
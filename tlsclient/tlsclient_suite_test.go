@@ -0,0 +1,13 @@
+package tlsclient_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTLSClient(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TLS Client Suite")
+}
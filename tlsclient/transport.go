@@ -0,0 +1,191 @@
+package tlsclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+)
+
+const defaultCheckInterval = 5 * time.Second
+
+type Config struct {
+	CACert     string `yaml:"ca_cert"`
+	ClientCert string `yaml:"client_cert"`
+	ClientKey  string `yaml:"client_key"`
+	ServerName string `yaml:"server_name"`
+}
+
+func (c Config) Enabled() bool {
+	return c.CACert != "" || c.ClientCert != "" || c.ClientKey != "" || c.ServerName != ""
+}
+
+func (c Config) files() []string {
+	files := []string{}
+	for _, path := range []string{c.CACert, c.ClientCert, c.ClientKey} {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// Transport is an http.RoundTripper whose TLS settings are read from PEM
+// files.  The files are checked for changes at most once per CheckInterval,
+// and a new underlying transport is built when any of them changed, so
+// rotated certificates are picked up without a restart.
+type Transport struct {
+	Logger        lager.Logger
+	Config        Config
+	CheckInterval time.Duration
+
+	mutex     sync.Mutex
+	transport *http.Transport
+	versions  map[string]fileVersion
+	checked   time.Time
+}
+
+// Load reads the files and builds the underlying transport.
+func (t *Transport) Load() error {
+	versions, err := t.stat()
+	if err != nil {
+		return err
+	}
+
+	tlsConfig, err := t.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	t.mutex.Lock()
+	previous := t.transport
+	t.transport = &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	t.versions = versions
+	t.checked = time.Now()
+	t.mutex.Unlock()
+
+	if previous != nil {
+		previous.CloseIdleConnections()
+	}
+
+	return nil
+}
+
+func (t *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	t.reloadIfChanged()
+
+	t.mutex.Lock()
+	transport := t.transport
+	t.mutex.Unlock()
+
+	if transport == nil {
+		return nil, fmt.Errorf("tls transport not loaded")
+	}
+	return transport.RoundTrip(request)
+}
+
+// reloadIfChanged rebuilds the transport when a file changed.  If the new
+// files cannot be used, for example while a certificate has been replaced
+// but its key not yet, the previous transport is kept and the files are
+// checked again on a later request.
+func (t *Transport) reloadIfChanged() {
+	interval := t.CheckInterval
+	if interval == 0 {
+		interval = defaultCheckInterval
+	}
+
+	t.mutex.Lock()
+	due := time.Since(t.checked) >= interval
+	if due {
+		t.checked = time.Now()
+	}
+	previous := t.versions
+	t.mutex.Unlock()
+
+	if !due {
+		return
+	}
+
+	versions, err := t.stat()
+	if err != nil {
+		t.Logger.Error("tls-reload-failed", err)
+		return
+	}
+	if sameVersions(previous, versions) {
+		return
+	}
+
+	if err := t.Load(); err != nil {
+		t.Logger.Error("tls-reload-failed", err)
+		return
+	}
+	t.Logger.Info("tls-reloaded")
+}
+
+func (t *Transport) stat() (map[string]fileVersion, error) {
+	versions := map[string]fileVersion{}
+	for _, path := range t.Config.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("stat %s: %s", path, err)
+		}
+		versions[path] = fileVersion{modTime: info.ModTime(), size: info.Size()}
+	}
+	return versions, nil
+}
+
+func sameVersions(a, b map[string]fileVersion) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, version := range a {
+		if other, ok := b[path]; !ok || !other.modTime.Equal(version.modTime) || other.size != version.size {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *Transport) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: t.Config.ServerName,
+	}
+
+	if t.Config.CACert != "" {
+		pem, err := ioutil.ReadFile(t.Config.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("read ca cert: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("read ca cert: no certificates in %s", t.Config.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if t.Config.ClientCert != "" || t.Config.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(t.Config.ClientCert, t.Config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
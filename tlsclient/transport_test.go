@@ -0,0 +1,209 @@
+package tlsclient_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/tlsclient"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(name string) *authority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+
+	return &authority{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns a PEM certificate and key for name, signed by the authority.
+func (a *authority) issue(name string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	Expect(err).NotTo(HaveOccurred())
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+var _ = Describe("Transport", func() {
+	var (
+		dir        string
+		serverCA   *authority
+		clientCA   *authority
+		server     *httptest.Server
+		transport  *tlsclient.Transport
+		logger     *lagertest.TestLogger
+		writeFiles func(ca *authority, client *authority)
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "tlsclient")
+		Expect(err).NotTo(HaveOccurred())
+
+		serverCA = newAuthority("server-ca")
+		clientCA = newAuthority("client-ca")
+
+		certPEM, keyPEM := serverCA.issue("daemon.service.cf.internal", x509.ExtKeyUsageServerAuth)
+		serverCert, err := tls.X509KeyPair(certPEM, keyPEM)
+		Expect(err).NotTo(HaveOccurred())
+
+		clientCAs := x509.NewCertPool()
+		clientCAs.AddCert(clientCA.cert)
+
+		server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+		server.TLS = &tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    clientCAs,
+		}
+		server.StartTLS()
+
+		writeFiles = func(ca *authority, client *authority) {
+			certPEM, keyPEM := client.issue("ducati-dns", x509.ExtKeyUsageClientAuth)
+			Expect(ioutil.WriteFile(filepath.Join(dir, "ca.pem"), ca.pem, 0600)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "client.pem"), certPEM, 0600)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "client.key"), keyPEM, 0600)).To(Succeed())
+		}
+		writeFiles(serverCA, clientCA)
+
+		logger = lagertest.NewTestLogger("test")
+		transport = &tlsclient.Transport{
+			Logger: logger,
+			Config: tlsclient.Config{
+				CACert:     filepath.Join(dir, "ca.pem"),
+				ClientCert: filepath.Join(dir, "client.pem"),
+				ClientKey:  filepath.Join(dir, "client.key"),
+				ServerName: "daemon.service.cf.internal",
+			},
+			CheckInterval: time.Millisecond,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+
+	// touch moves the modification times forward, so that rewritten files
+	// are seen as changed regardless of the file system's time resolution.
+	touch := func(names ...string) {
+		future := time.Now().Add(time.Minute)
+		for _, name := range names {
+			Expect(os.Chtimes(filepath.Join(dir, name), future, future)).To(Succeed())
+		}
+		time.Sleep(2 * transport.CheckInterval)
+	}
+
+	get := func() error {
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL + "/containers")
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	It("presents the client certificate and verifies the server", func() {
+		Expect(transport.Load()).To(Succeed())
+		Expect(get()).To(Succeed())
+	})
+
+	It("rejects a server certificate for another name", func() {
+		transport.Config.ServerName = "potato.example.com"
+		Expect(transport.Load()).To(Succeed())
+		Expect(get()).To(MatchError(ContainSubstring("potato.example.com")))
+	})
+
+	It("rejects a server signed by an unknown authority", func() {
+		writeFiles(newAuthority("other-ca"), clientCA)
+		Expect(transport.Load()).To(Succeed())
+		Expect(get()).To(MatchError(ContainSubstring("unknown authority")))
+	})
+
+	It("picks up rotated certificates", func() {
+		writeFiles(serverCA, newAuthority("stale-ca"))
+		Expect(transport.Load()).To(Succeed())
+		Expect(get()).To(HaveOccurred())
+
+		writeFiles(serverCA, clientCA)
+		touch("ca.pem", "client.pem", "client.key")
+
+		Expect(get()).To(Succeed())
+		Expect(logger).To(gbytes.Say("tls-reloaded"))
+	})
+
+	It("keeps the previous settings when the new files are unusable", func() {
+		Expect(transport.Load()).To(Succeed())
+
+		Expect(ioutil.WriteFile(filepath.Join(dir, "client.key"), []byte("garbage"), 0600)).To(Succeed())
+		touch("client.key")
+
+		Expect(get()).To(Succeed())
+		Expect(logger).To(gbytes.Say("tls-reload-failed"))
+	})
+
+	It("fails to load when a file is missing", func() {
+		transport.Config.ClientKey = filepath.Join(dir, "missing.key")
+		Expect(transport.Load()).To(MatchError(ContainSubstring("missing.key")))
+	})
+})
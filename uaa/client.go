@@ -0,0 +1,175 @@
+package uaa
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+)
+
+const (
+	defaultRefreshMargin = 30 * time.Second
+	defaultTimeout       = 10 * time.Second
+)
+
+type Config struct {
+	URL          string `yaml:"url"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Client fetches access tokens from UAA with the client credentials grant
+// and caches them until shortly before they expire.  Tokens due for refresh
+// are refreshed in the background while the cached one is still served.
+type Client struct {
+	Logger        lager.Logger
+	Config        Config
+	HTTPClient    *http.Client
+	RefreshMargin time.Duration
+
+	mutex     sync.Mutex
+	token     string
+	refreshAt time.Time
+	expires   time.Time
+	fetching  chan struct{}
+	fetchErr  error
+}
+
+// Token returns the cached token.  Once it is due for refresh a new one is
+// fetched in the background, and the cached token is returned until that
+// succeeds or the token expires.  Without a valid token, Token waits for
+// the fetch.  Concurrent callers share a single fetch.
+func (c *Client) Token() (string, error) {
+	c.mutex.Lock()
+	now := time.Now()
+	if c.token != "" && now.Before(c.expires) {
+		token := c.token
+		if !now.Before(c.refreshAt) {
+			c.refresh()
+		}
+		c.mutex.Unlock()
+		return token, nil
+	}
+	done := c.refresh()
+	c.mutex.Unlock()
+
+	<-done
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+	return "", fmt.Errorf("fetch token: %s", c.fetchErr)
+}
+
+// refresh starts fetching a token unless a fetch is already in flight, and
+// returns a channel that is closed when the fetch completes.  It must be
+// called with the mutex held.
+func (c *Client) refresh() <-chan struct{} {
+	if c.fetching != nil {
+		return c.fetching
+	}
+
+	done := make(chan struct{})
+	c.fetching = done
+	go func() {
+		started := time.Now()
+		token, lifetime, err := c.fetch()
+
+		c.mutex.Lock()
+		c.store(started, token, lifetime, err)
+		c.fetching = nil
+		c.mutex.Unlock()
+
+		close(done)
+	}()
+	return done
+}
+
+func (c *Client) store(started time.Time, token string, lifetime time.Duration, err error) {
+	c.fetchErr = err
+	if err != nil {
+		if c.token != "" && time.Now().Before(c.expires) {
+			c.Logger.Error("token-refresh-failed", err, lager.Data{"expires": c.expires.String()})
+		}
+		return
+	}
+
+	margin := c.RefreshMargin
+	if margin == 0 {
+		margin = defaultRefreshMargin
+	}
+	if margin > lifetime/2 {
+		margin = lifetime / 2
+	}
+
+	c.token = token
+	c.expires = started.Add(lifetime)
+	c.refreshAt = c.expires.Add(-margin)
+	c.Logger.Info("token-fetched", lager.Data{"expires_in": lifetime.String()})
+}
+
+// Invalidate drops token if it is the cached one, so that the next call to
+// Token fetches a new one.
+func (c *Client) Invalidate(token string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+func (c *Client) fetch() (string, time.Duration, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"response_type": {"token"},
+	}
+	request, err := http.NewRequest("POST", strings.TrimSuffix(c.Config.URL, "/")+"/oauth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(c.Config.ClientID), url.QueryEscape(c.Config.ClientSecret))
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultTimeout}
+	}
+
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("unexpected status code: expected 200 but got %d", resp.StatusCode)
+	}
+
+	var body tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", 0, fmt.Errorf("decode token: %s", err)
+	}
+	if body.AccessToken == "" {
+		return "", 0, fmt.Errorf("response has no access token")
+	}
+	if body.TokenType != "" && !strings.EqualFold(body.TokenType, "bearer") {
+		return "", 0, fmt.Errorf("unsupported token type %q", body.TokenType)
+	}
+
+	return body.AccessToken, time.Duration(body.ExpiresIn) * time.Second, nil
+}
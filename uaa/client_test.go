@@ -0,0 +1,137 @@
+package uaa_test
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/uaa"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		uaaServer *fakes.UAAServer
+		logger    *lagertest.TestLogger
+		client    *uaa.Client
+	)
+
+	BeforeEach(func() {
+		uaaServer = fakes.NewUAAServer("ducati_dns", "some-secret")
+		logger = lagertest.NewTestLogger("test")
+		client = &uaa.Client{
+			Logger: logger,
+			Config: uaa.Config{
+				URL:          uaaServer.URL,
+				ClientID:     "ducati_dns",
+				ClientSecret: "some-secret",
+			},
+		}
+	})
+
+	AfterEach(func() {
+		uaaServer.Close()
+	})
+
+	It("fetches a token with the client credentials grant", func() {
+		token, err := client.Token()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token).To(Equal("token-1"))
+		Expect(logger).To(gbytes.Say("token-fetched.*1h0m0s"))
+	})
+
+	It("caches the token", func() {
+		client.Token()
+		token, err := client.Token()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token).To(Equal("token-1"))
+		Expect(uaaServer.TokenRequests()).To(Equal(1))
+	})
+
+	It("refreshes the token before it expires", func() {
+		uaaServer.SetExpiresIn(2)
+		client.RefreshMargin = 1500 * time.Millisecond
+
+		client.Token()
+		time.Sleep(1100 * time.Millisecond)
+
+		token, err := client.Token()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token).To(Equal("token-1"))
+
+		Eventually(client.Token).Should(Equal("token-2"))
+	})
+
+	It("fetches a single token for concurrent callers", func() {
+		tokens := make(chan string, 5)
+		for i := 0; i < 5; i++ {
+			go func() {
+				defer GinkgoRecover()
+				token, err := client.Token()
+				Expect(err).NotTo(HaveOccurred())
+				tokens <- token
+			}()
+		}
+
+		for i := 0; i < 5; i++ {
+			Eventually(tokens).Should(Receive(Equal("token-1")))
+		}
+		Expect(uaaServer.TokenRequests()).To(Equal(1))
+	})
+
+	It("fetches a new token once the current one is invalidated", func() {
+		client.Token()
+		client.Invalidate("some-other-token")
+		client.Token()
+		Expect(uaaServer.TokenRequests()).To(Equal(1))
+
+		client.Invalidate("token-1")
+		token, _ := client.Token()
+		Expect(token).To(Equal("token-2"))
+	})
+
+	Context("when the refresh fails while the token is still valid", func() {
+		It("keeps using the cached token", func() {
+			uaaServer.SetExpiresIn(2)
+			client.RefreshMargin = 1500 * time.Millisecond
+			client.Token()
+
+			time.Sleep(1100 * time.Millisecond)
+			client.Config.ClientSecret = "wrong"
+
+			token, err := client.Token()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(Equal("token-1"))
+			Eventually(logger).Should(gbytes.Say("token-refresh-failed"))
+
+			token, err = client.Token()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(Equal("token-1"))
+		})
+	})
+
+	Context("when the credentials are rejected", func() {
+		BeforeEach(func() {
+			client.Config.ClientSecret = "wrong"
+		})
+
+		It("returns an error", func() {
+			_, err := client.Token()
+			Expect(err).To(MatchError("fetch token: unexpected status code: expected 200 but got 401"))
+		})
+	})
+
+	Context("when UAA cannot be reached", func() {
+		BeforeEach(func() {
+			uaaServer.Close()
+		})
+
+		It("returns an error", func() {
+			_, err := client.Token()
+			Expect(err).To(MatchError(ContainSubstring("fetch token")))
+		})
+	})
+})
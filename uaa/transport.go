@@ -0,0 +1,62 @@
+package uaa
+
+import (
+	"net/http"
+)
+
+// Transport adds a UAA bearer token to each request.  When the server
+// rejects a token with 401 Unauthorized, the token is dropped and the
+// request retried once with a fresh one.
+type Transport struct {
+	Tokens *Client
+	Base   http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	token, err := t.Tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base().RoundTrip(authorize(request, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if request.Body != nil && request.GetBody == nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	t.Tokens.Invalidate(token)
+	if token, err = t.Tokens.Token(); err != nil {
+		return nil, err
+	}
+
+	retry := authorize(request, token)
+	if request.Body != nil {
+		if retry.Body, err = request.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return t.base().RoundTrip(retry)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// authorize returns a copy of request carrying token, since a RoundTripper
+// must not modify the request it is given.
+func authorize(request *http.Request, token string) *http.Request {
+	authorized := new(http.Request)
+	*authorized = *request
+	authorized.Header = http.Header{}
+	for key, values := range request.Header {
+		authorized.Header[key] = values
+	}
+	authorized.Header.Set("Authorization", "Bearer "+token)
+	return authorized
+}
@@ -0,0 +1,104 @@
+package uaa_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/ducati-dns/fakes"
+	"github.com/cloudfoundry-incubator/ducati-dns/uaa"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transport", func() {
+	var (
+		uaaServer *fakes.UAAServer
+		apiServer *httptest.Server
+		requests  int
+		rejectAll bool
+		client    *http.Client
+	)
+
+	BeforeEach(func() {
+		requests = 0
+		rejectAll = false
+		uaaServer = fakes.NewUAAServer("ducati_dns", "some-secret")
+		apiServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if rejectAll || !uaaServer.Authorized(r) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		client = &http.Client{Transport: &uaa.Transport{
+			Tokens: &uaa.Client{
+				Logger: lagertest.NewTestLogger("test"),
+				Config: uaa.Config{
+					URL:          uaaServer.URL,
+					ClientID:     "ducati_dns",
+					ClientSecret: "some-secret",
+				},
+			},
+		}}
+	})
+
+	AfterEach(func() {
+		apiServer.Close()
+		uaaServer.Close()
+	})
+
+	It("sends the token as a bearer token", func() {
+		resp, err := client.Get(apiServer.URL + "/containers")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	})
+
+	It("does not modify the caller's request", func() {
+		request, err := http.NewRequest("GET", apiServer.URL+"/containers", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		client.Do(request)
+		Expect(request.Header.Get("Authorization")).To(BeEmpty())
+	})
+
+	Context("when the token is rejected", func() {
+		BeforeEach(func() {
+			client.Get(apiServer.URL + "/containers")
+			uaaServer.RevokeAll()
+			requests = 0
+		})
+
+		It("retries once with a new token", func() {
+			resp, err := client.Post(apiServer.URL+"/containers", "application/json", strings.NewReader("{}"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(requests).To(Equal(2))
+			Expect(uaaServer.TokenRequests()).To(Equal(2))
+		})
+
+		It("returns the second 401 without retrying again", func() {
+			rejectAll = true
+			resp, err := client.Get(apiServer.URL + "/containers")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			Expect(requests).To(Equal(2))
+		})
+	})
+
+	Context("when no token can be fetched", func() {
+		BeforeEach(func() {
+			uaaServer.ClientSecret = "rotated"
+		})
+
+		It("fails without contacting the server", func() {
+			_, err := client.Get(apiServer.URL + "/containers")
+			Expect(err).To(MatchError(ContainSubstring("fetch token")))
+			Expect(requests).To(Equal(0))
+		})
+	})
+})